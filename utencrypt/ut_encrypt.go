@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/curve25519"
 )
 
@@ -119,6 +120,43 @@ func XORKeyStream(key PresharedKey, nonce []byte, src []byte) ([]byte, error) {
 	return dst, nil
 }
 
+// Seal ...
+// @Description: 用 privateKey 与对端公钥的共享密钥加密，输出 nonce + 密文，对端用 Keyring.Open 解密
+// @param privateKey 本端私钥
+// @param publicKey 对端公钥
+// @param plaintext
+// @param additionalData 参与认证但不加密的数据，可为 nil
+// @return []byte
+// @return error
+func Seal(privateKey PrivateKey, publicKey PublicKey, plaintext, additionalData []byte) ([]byte, error) {
+	secret, err := privateKey.SharedSecret(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openSealed 用共享密钥解密 Seal 的输出
+func openSealed(secret PresharedKey, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("sealed message too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
 // GenerateSpaPubKeyMD5 spa pubkey to md5 key
 func GenerateSpaPubKeyMD5(pubKey string) (string, error) {
 	// NewKey ...
@@ -0,0 +1,230 @@
+package utencrypt
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// FingerprintVersion 公钥指纹版本
+type FingerprintVersion uint8
+
+const (
+	// FingerprintMD5Legacy 与 GenerateSpaPubKeyMD5 相同的旧格式，仅用于兼容
+	FingerprintMD5Legacy FingerprintVersion = iota
+	// FingerprintSHA256 公钥的 SHA-256 摘要
+	FingerprintSHA256
+	// FingerprintBLAKE2s 公钥的 BLAKE2s-256 摘要
+	FingerprintBLAKE2s
+)
+
+// String 版本名称，同时也是文本格式的前缀
+func (v FingerprintVersion) String() string {
+	switch v {
+	case FingerprintMD5Legacy:
+		return "md5"
+	case FingerprintSHA256:
+		return "sha256"
+	case FingerprintBLAKE2s:
+		return "blake2s"
+	default:
+		return fmt.Sprintf("FingerprintVersion(%d)", uint8(v))
+	}
+}
+
+// FingerprintEncoding 指纹的文本编码方式
+type FingerprintEncoding uint8
+
+const (
+	// FingerprintHex 小写十六进制
+	FingerprintHex FingerprintEncoding = iota
+	// FingerprintBase32 无填充的 RFC 4648 base32
+	FingerprintBase32
+	// FingerprintEmoji 截断后的 emoji 序列，用于人工比对
+	FingerprintEmoji
+	// FingerprintWords 截断后的单词序列，用于人工比对
+	FingerprintWords
+)
+
+// humanFingerprintSymbols emoji、单词编码的符号个数，每个符号 6 bit
+const humanFingerprintSymbols = 8
+
+var fingerprintBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// fingerprintEmoji 与 fingerprintWords 一一对应，共 64 个
+var fingerprintEmoji = [64]string{
+	"🐶", "🐱", "🦁", "🐎", "🦄", "🐷", "🐘", "🐰",
+	"🐼", "🐓", "🐧", "🐢", "🐟", "🐙", "🦋", "🌷",
+	"🌳", "🌵", "🍄", "🌏", "🌙", "☁️", "🔥", "🍌",
+	"🍎", "🍓", "🌽", "🍕", "🎂", "❤️", "😀", "🤖",
+	"🎩", "👓", "🔧", "🎅", "👍", "☂️", "⌛", "⏰",
+	"🎁", "💡", "📕", "✏️", "📎", "✂️", "🔒", "🔑",
+	"🔨", "☎️", "🏁", "🚂", "🚲", "✈️", "🚀", "🏆",
+	"⚽", "🎸", "🎺", "🔔", "⚓", "🎧", "📁", "📌",
+}
+
+var fingerprintWords = [64]string{
+	"dog", "cat", "lion", "horse", "unicorn", "pig", "elephant", "rabbit",
+	"panda", "rooster", "penguin", "turtle", "fish", "octopus", "butterfly", "flower",
+	"tree", "cactus", "mushroom", "globe", "moon", "cloud", "fire", "banana",
+	"apple", "strawberry", "corn", "pizza", "cake", "heart", "smiley", "robot",
+	"hat", "glasses", "spanner", "santa", "thumbs", "umbrella", "hourglass", "clock",
+	"gift", "bulb", "book", "pencil", "paperclip", "scissors", "lock", "key",
+	"hammer", "telephone", "flag", "train", "bicycle", "aeroplane", "rocket", "trophy",
+	"ball", "guitar", "trumpet", "bell", "anchor", "headphones", "folder", "pin",
+}
+
+// Fingerprint ...
+// @Description: 带版本的公钥指纹
+// 文本格式为 "<version>:<hex|base32>"，旧格式 md5 不带前缀，与 GenerateSpaPubKeyMD5 输出一致
+type Fingerprint struct {
+	Version FingerprintVersion
+	Digest  []byte
+}
+
+// NewFingerprint 计算公钥指定版本的指纹
+func NewFingerprint(publicKey PublicKey, version FingerprintVersion) (Fingerprint, error) {
+	switch version {
+	case FingerprintMD5Legacy:
+		sum := md5.Sum(publicKey[:])
+		// 与 GenerateSpaPubKeyMD5 一致：取 md5 hex 的 [8:24]，其 ASCII 字节即为摘要
+		return Fingerprint{Version: version, Digest: []byte(hex.EncodeToString(sum[:])[8:24])}, nil
+	case FingerprintSHA256:
+		sum := sha256.Sum256(publicKey[:])
+		return Fingerprint{Version: version, Digest: sum[:]}, nil
+	case FingerprintBLAKE2s:
+		sum := blake2s.Sum256(publicKey[:])
+		return Fingerprint{Version: version, Digest: sum[:]}, nil
+	default:
+		return Fingerprint{}, fmt.Errorf("NewFingerprint: unknown version %d", version)
+	}
+}
+
+// String 默认文本格式，新版本使用 hex 编码
+func (f Fingerprint) String() string {
+	return f.Encode(FingerprintHex)
+}
+
+// Encode 按指定编码输出指纹
+// hex、base32 带版本前缀，可以被 ParseFingerprint 解析；emoji、单词只用于人工比对
+func (f Fingerprint) Encode(encoding FingerprintEncoding) string {
+	switch encoding {
+	case FingerprintEmoji:
+		return strings.Join(f.symbols(fingerprintEmoji[:]), " ")
+	case FingerprintWords:
+		return strings.Join(f.symbols(fingerprintWords[:]), "-")
+	}
+
+	var body string
+	if encoding == FingerprintBase32 {
+		body = fingerprintBase32.EncodeToString(f.Digest)
+	} else {
+		body = hex.EncodeToString(f.Digest)
+	}
+	if f.Version == FingerprintMD5Legacy {
+		return body
+	}
+	return f.Version.String() + ":" + body
+}
+
+// Emoji emoji 序列，用于人工比对
+func (f Fingerprint) Emoji() string {
+	return f.Encode(FingerprintEmoji)
+}
+
+// Words 单词序列，用于人工比对
+func (f Fingerprint) Words() string {
+	return f.Encode(FingerprintWords)
+}
+
+// symbols 摘要前 humanFingerprintSymbols*6 bit 按 6 bit 一组映射到符号表
+func (f Fingerprint) symbols(table []string) []string {
+	res := make([]string, 0, humanFingerprintSymbols)
+	for i := 0; i < humanFingerprintSymbols; i++ {
+		bit := i * 6
+		if bit/8 >= len(f.Digest) {
+			break
+		}
+		v := uint16(f.Digest[bit/8]) << 8
+		if bit/8+1 < len(f.Digest) {
+			v |= uint16(f.Digest[bit/8+1])
+		}
+		res = append(res, table[(v>>(10-bit%8))&0x3f])
+	}
+	return res
+}
+
+// Equal 常数时间比较两个指纹
+func (f Fingerprint) Equal(o Fingerprint) bool {
+	return f.Version == o.Version && subtle.ConstantTimeCompare(f.Digest, o.Digest) == 1
+}
+
+// Match 公钥是否与指纹一致
+func (f Fingerprint) Match(publicKey PublicKey) bool {
+	other, err := NewFingerprint(publicKey, f.Version)
+	if err != nil {
+		return false
+	}
+	return f.Equal(other)
+}
+
+// ParseFingerprint ...
+// @Description: 解析 hex 或 base32 文本格式的指纹，根据前缀识别版本，无前缀按旧 md5 格式解析
+// @param s
+// @return Fingerprint
+// @return error
+func ParseFingerprint(s string) (Fingerprint, error) {
+	s = strings.TrimSpace(s)
+	version := FingerprintMD5Legacy
+	body := s
+	if prefix, rest, ok := strings.Cut(s, ":"); ok {
+		switch strings.ToLower(prefix) {
+		case FingerprintSHA256.String():
+			version = FingerprintSHA256
+		case FingerprintBLAKE2s.String():
+			version = FingerprintBLAKE2s
+		case FingerprintMD5Legacy.String():
+		default:
+			return Fingerprint{}, fmt.Errorf("ParseFingerprint: unknown version %q", prefix)
+		}
+		body = rest
+	}
+
+	size := 32
+	if version == FingerprintMD5Legacy {
+		size = 16
+	}
+	var digest []byte
+	var err error
+	switch len(body) {
+	case hex.EncodedLen(size):
+		digest, err = hex.DecodeString(strings.ToLower(body))
+	case fingerprintBase32.EncodedLen(size):
+		digest, err = fingerprintBase32.DecodeString(strings.ToUpper(body))
+	default:
+		return Fingerprint{}, fmt.Errorf("ParseFingerprint: %s fingerprint has invalid length %d", version, len(body))
+	}
+	if err != nil {
+		return Fingerprint{}, fmt.Errorf("ParseFingerprint: %s fingerprint: %v", version, err)
+	}
+	return Fingerprint{Version: version, Digest: digest}, nil
+}
+
+// GenerateSpaPubKeyFingerprint base64 公钥生成指定版本的指纹文本，GenerateSpaPubKeyMD5 的新版本
+func GenerateSpaPubKeyFingerprint(pubKey string, version FingerprintVersion, encoding FingerprintEncoding) (string, error) {
+	publicKey, err := LoadExactBase64[PublicKey](pubKey)
+	if err != nil {
+		return "", err
+	}
+	f, err := NewFingerprint(publicKey, version)
+	if err != nil {
+		return "", err
+	}
+	return f.Encode(encoding), nil
+}
@@ -0,0 +1,152 @@
+package utencrypt
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// fingerprintKey 0x00..0x1f 组成的公钥，下面的摘要由独立实现计算
+const fingerprintKey = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+
+var fingerprintVectors = []struct {
+	version     FingerprintVersion
+	hex, base32 string
+}{
+	{FingerprintMD5Legacy, "37333763656333313561346134643161", "G4ZTOY3FMMZTCNLBGRQTIZBRME"},
+	{FingerprintSHA256,
+		"sha256:630dcd2966c4336691125448bbb25b4ff412a49c732db2c8abc1b8581bd710dd",
+		"sha256:MMG42KLGYQZWNEISKRELXMS3J72BFJE4OMW3FSFLYG4FQG6XCDOQ"},
+	{FingerprintBLAKE2s,
+		"blake2s:05825607d7fdf2d82ef4c3c8c2aea961ad98d60edff7d018983e21204c0d93d1",
+		"blake2s:AWBFMB6X7XZNQLXUYPEMFLVJMGWZRVQO3735AGEYHYQSATANSPIQ"},
+}
+
+// symbolBits emoji、单词序列还原为摘要前 6*n bit，符号不在表中时返回 false
+func symbolBits(symbols []string, table []string) (uint64, bool) {
+	var v uint64
+	for _, s := range symbols {
+		i := 0
+		for i < len(table) && table[i] != s {
+			i++
+		}
+		if i == len(table) {
+			return 0, false
+		}
+		v = v<<6 | uint64(i)
+	}
+	return v, true
+}
+
+// TestFingerprintRoundTrip 每个版本的 hex、base32 都能解析回同一指纹，emoji、单词能还原摘要前 48 bit
+func TestFingerprintRoundTrip(t *testing.T) {
+	publicKey, err := LoadExactBase64[PublicKey](fingerprintKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range fingerprintVectors {
+		f, err := NewFingerprint(publicKey, tt.version)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.version, err)
+		}
+		if got := f.Encode(FingerprintHex); got != tt.hex || f.String() != tt.hex {
+			t.Errorf("%s: hex = %s, want %s", tt.version, got, tt.hex)
+		}
+		if got := f.Encode(FingerprintBase32); got != tt.base32 {
+			t.Errorf("%s: base32 = %s, want %s", tt.version, got, tt.base32)
+		}
+
+		for _, text := range []string{tt.hex, tt.base32, strings.ToUpper(tt.hex), strings.ToLower(tt.base32), " " + tt.hex + "\n"} {
+			parsed, err := ParseFingerprint(text)
+			if err != nil || !parsed.Equal(f) || !parsed.Match(publicKey) {
+				t.Errorf("%s: ParseFingerprint(%q) = %v, %v", tt.version, text, parsed, err)
+			}
+		}
+
+		emoji := strings.Split(f.Emoji(), " ")
+		words := strings.Split(f.Words(), "-")
+		if len(emoji) != humanFingerprintSymbols || len(words) != humanFingerprintSymbols {
+			t.Fatalf("%s: %d emoji, %d words, want %d", tt.version, len(emoji), len(words), humanFingerprintSymbols)
+		}
+		emojiBits, ok1 := symbolBits(emoji, fingerprintEmoji[:])
+		wordBits, ok2 := symbolBits(words, fingerprintWords[:])
+		var want uint64
+		for _, b := range f.Digest[:6] {
+			want = want<<8 | uint64(b)
+		}
+		if !ok1 || !ok2 || emojiBits != want || wordBits != want {
+			t.Errorf("%s: emoji %x, words %x, want digest prefix %x", tt.version, emojiBits, wordBits, want)
+		}
+		for _, text := range []string{f.Emoji(), f.Words()} {
+			if _, err := ParseFingerprint(text); err == nil {
+				t.Errorf("%s: ParseFingerprint(%q) accepted a human-readable fingerprint", tt.version, text)
+			}
+		}
+	}
+
+	sha, _ := NewFingerprint(publicKey, FingerprintSHA256)
+	if got := sha.Words(); got != "apple-hammer-trophy-octopus-penguin-fire-pizza-unicorn" {
+		t.Errorf("sha256 words = %s", got)
+	}
+}
+
+// TestFingerprintLegacyMD5 旧格式与 GenerateSpaPubKeyMD5 的输出一致
+func TestFingerprintLegacyMD5(t *testing.T) {
+	keys := []string{fingerprintKey}
+	for i := 0; i < 4; i++ {
+		priv := mustKey(t)
+		pub := priv.GetPublicKey()
+		keys = append(keys, pub.Base64())
+	}
+	for _, pubKey := range keys {
+		legacy, err := GenerateSpaPubKeyMD5(pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := GenerateSpaPubKeyFingerprint(pubKey, FingerprintMD5Legacy, FingerprintHex)
+		if err != nil || got != legacy {
+			t.Errorf("GenerateSpaPubKeyFingerprint = %s, %v, want %s", got, err, legacy)
+		}
+		f, err := ParseFingerprint(legacy)
+		if err != nil || f.Version != FingerprintMD5Legacy {
+			t.Fatalf("ParseFingerprint(%s) = %v, %v", legacy, f, err)
+		}
+		prefixed, err := ParseFingerprint("md5:" + legacy)
+		if err != nil || !prefixed.Equal(f) {
+			t.Errorf("ParseFingerprint(md5:%s) = %v, %v", legacy, prefixed, err)
+		}
+		publicKey, _ := LoadExactBase64[PublicKey](pubKey)
+		if !f.Match(publicKey) {
+			t.Errorf("legacy fingerprint %s does not match its key", legacy)
+		}
+	}
+}
+
+// TestParseFingerprintInvalid 未知版本、长度或字符错误
+func TestParseFingerprintInvalid(t *testing.T) {
+	sha := fingerprintVectors[1].hex
+	for _, s := range []string{
+		"",
+		"sha1:" + strings.TrimPrefix(sha, "sha256:"),
+		"blake2s:" + fingerprintVectors[0].hex, // md5 的长度
+		sha[:len(sha)-2],
+		sha[:len(sha)-1] + "g",
+		"sha256:" + strings.Repeat("!", 52),
+		hex.EncodeToString(make([]byte, 32)), // 无前缀按 md5 解析，长度不对
+	} {
+		if f, err := ParseFingerprint(s); err == nil {
+			t.Errorf("ParseFingerprint(%q) = %v, want error", s, f)
+		}
+	}
+
+	publicKey, _ := LoadExactBase64[PublicKey](fingerprintKey)
+	sha256FP, _ := ParseFingerprint(sha)
+	blake, _ := ParseFingerprint(fingerprintVectors[2].hex)
+	other := mustKey(t)
+	if sha256FP.Equal(blake) || !sha256FP.Match(publicKey) || sha256FP.Match(other.GetPublicKey()) {
+		t.Error("fingerprints of different versions or keys compare equal")
+	}
+	if _, err := NewFingerprint(publicKey, FingerprintVersion(9)); err == nil {
+		t.Error("NewFingerprint(unknown version): want error")
+	}
+}
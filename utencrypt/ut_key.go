@@ -1,23 +1,166 @@
 package utencrypt
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 
 	"github.com/smallnest/rpcx/log"
 )
 
-// IsPubKeyValid 验证公私钥是否合法，主要用于l3
-func IsPubKeyValid(pubKey string) bool {
-	// 解码 Base64 公钥
-	decodedKey, err := base64.StdEncoding.DecodeString(pubKey)
-	if err != nil {
-		log.Errorf("DecodeString %+v, err %+v", pubKey, err)
+// KeyErrorReason 公钥校验失败的原因
+type KeyErrorReason uint8
+
+const (
+	// KeyErrorEncoding hex/base64 解码失败
+	KeyErrorEncoding KeyErrorReason = iota + 1
+	// KeyErrorLength 解码后长度不是 KeySize
+	KeyErrorLength
+	// KeyErrorZero 全零公钥
+	KeyErrorZero
+	// KeyErrorLowOrder 小阶点，共享密钥可预测或为全零
+	KeyErrorLowOrder
+	// KeyErrorNonCanonical 非规范编码：最高位为 1 或数值 >= 2^255-19
+	KeyErrorNonCanonical
+)
+
+// String ...
+func (r KeyErrorReason) String() string {
+	switch r {
+	case KeyErrorEncoding:
+		return "invalid encoding"
+	case KeyErrorLength:
+		return "invalid length"
+	case KeyErrorZero:
+		return "all-zero key"
+	case KeyErrorLowOrder:
+		return "low-order point"
+	case KeyErrorNonCanonical:
+		return "non-canonical encoding"
+	default:
+		return fmt.Sprintf("KeyErrorReason(%d)", uint8(r))
+	}
+}
+
+// KeyError ...
+// @Description: 公钥校验失败，可以用 errors.As 取出 Reason
+type KeyError struct {
+	Reason KeyErrorReason
+	Err    error
+}
+
+// Error ...
+func (e *KeyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid public key: %s: %v", e.Reason, e.Err)
+	}
+	return "invalid public key: " + e.Reason.String()
+}
+
+// Unwrap ...
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// lowOrderPoints X25519 输入中阶为 1、2、4、8 的 u 坐标（小端序，与 libsodium 的列表一致），
+// 包括曲线和二次扭曲上的点；规范编码之外的等价形式 p、p+1 等由 KeyErrorNonCanonical 拦截
+var lowOrderPoints = [][KeySize]byte{
+	// 0：点 (0, 0)，阶为 2；无穷远点（阶为 1）也编码为 0
+	{},
+	// 1：阶为 4
+	{0x01},
+	// 阶为 8
+	{0xe0, 0xeb, 0x7a, 0x7c, 0x3b, 0x41, 0xb8, 0xae, 0x16, 0x56, 0xe3, 0xfa, 0xf1, 0x9f, 0xc4, 0x6a,
+		0xda, 0x09, 0x8d, 0xeb, 0x9c, 0x32, 0xb1, 0xfd, 0x86, 0x62, 0x05, 0x16, 0x5f, 0x49, 0xb8, 0x00},
+	// 阶为 8
+	{0x5f, 0x9c, 0x95, 0xbc, 0xa3, 0x50, 0x8c, 0x24, 0xb1, 0xd0, 0xb1, 0x55, 0x9c, 0x83, 0xef, 0x5b,
+		0x04, 0x44, 0x5c, 0xc4, 0x58, 0x1c, 0x8e, 0x86, 0xd8, 0x22, 0x4e, 0xdd, 0xd0, 0x9f, 0x11, 0x57},
+	// p-1：二次扭曲上的点，阶为 4
+	{0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+}
+
+// isCanonical 最高位为 0 且数值小于 p = 2^255-19
+func (key *PublicKey) isCanonical() bool {
+	if key[31]&0x80 != 0 {
 		return false
 	}
+	if key[31] != 0x7f {
+		return true
+	}
+	for i := 30; i > 0; i-- {
+		if key[i] != 0xff {
+			return true
+		}
+	}
+	return key[0] < 0xed
+}
+
+// Validate ...
+// @Description: 校验 X25519 公钥，拒绝全零、小阶点和非规范编码
+// @receiver key
+// @return error *KeyError
+func (key *PublicKey) Validate() error {
+	if *key == (PublicKey{}) {
+		return &KeyError{Reason: KeyErrorZero}
+	}
+	if !key.isCanonical() {
+		return &KeyError{Reason: KeyErrorNonCanonical}
+	}
+	for i := range lowOrderPoints {
+		if bytes.Equal(key[:], lowOrderPoints[i][:]) {
+			return &KeyError{Reason: KeyErrorLowOrder}
+		}
+	}
+	return nil
+}
 
-	// 验证解码后的公钥长度
-	if len(decodedKey) != 32 {
-		log.Errorf("len(decodedKey) %+v, err", pubKey)
+// LoadValidHex 解析十六进制公钥并做完整校验
+func LoadValidHex(src string) (PublicKey, error) {
+	slice, err := hex.DecodeString(src)
+	if err != nil {
+		return PublicKey{}, &KeyError{Reason: KeyErrorEncoding, Err: err}
+	}
+	return loadValid(slice)
+}
+
+// LoadValidBase64 解析 Base64 公钥并做完整校验
+func LoadValidBase64(src string) (PublicKey, error) {
+	slice, err := base64.StdEncoding.DecodeString(src)
+	if err != nil {
+		return PublicKey{}, &KeyError{Reason: KeyErrorEncoding, Err: err}
+	}
+	return loadValid(slice)
+}
+
+func loadValid(slice []byte) (PublicKey, error) {
+	if len(slice) != KeySize {
+		return PublicKey{}, &KeyError{Reason: KeyErrorLength, Err: fmt.Errorf("got %d bytes, want %d", len(slice), KeySize)}
+	}
+	key := *(*PublicKey)(slice)
+	if err := key.Validate(); err != nil {
+		return PublicKey{}, err
+	}
+	return key, nil
+}
+
+// ValidatePubKey 校验 Base64 公钥，返回 *KeyError 说明原因
+func ValidatePubKey(pubKey string) error {
+	_, err := LoadValidBase64(pubKey)
+	return err
+}
+
+// IsPubKeyValid 验证公私钥是否合法，主要用于l3
+func IsPubKeyValid(pubKey string) bool {
+	if err := ValidatePubKey(pubKey); err != nil {
+		var keyErr *KeyError
+		if errors.As(err, &keyErr) && keyErr.Reason == KeyErrorEncoding {
+			log.Errorf("DecodeString %+v, err %+v", pubKey, err)
+		} else {
+			log.Errorf("pubKey %+v, err %+v", pubKey, err)
+		}
 		return false
 	}
 	return true
@@ -0,0 +1,161 @@
+package utencrypt
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// fieldP 2^255-19
+var fieldP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// littleEndianKey 把不超过 256 位的整数编码为小端序公钥
+func littleEndianKey(n *big.Int) PublicKey {
+	var key PublicKey
+	b := n.Bytes()
+	for i := range b {
+		key[i] = b[len(b)-1-i]
+	}
+	return key
+}
+
+// keyErrorReason 取出 *KeyError 的 Reason，不是 *KeyError 时返回 0
+func keyErrorReason(err error) KeyErrorReason {
+	var keyErr *KeyError
+	if errors.As(err, &keyErr) {
+		return keyErr.Reason
+	}
+	return 0
+}
+
+// TestValidateLowOrder 每个小阶点与任意私钥的共享密钥都是全零，必须被拒绝
+func TestValidateLowOrder(t *testing.T) {
+	priv := mustKey(t)
+	for i, point := range lowOrderPoints {
+		key := PublicKey(point)
+		if _, err := curve25519.X25519(priv[:], key[:]); err == nil {
+			t.Errorf("point %d: X25519 succeeded, not a low-order point", i)
+		}
+		want := KeyErrorLowOrder
+		if key == (PublicKey{}) {
+			want = KeyErrorZero
+		}
+		if got := keyErrorReason(key.Validate()); got != want {
+			t.Errorf("point %d (%s): Validate reason = %v, want %v", i, key.Hex(), got, want)
+		}
+		if IsPubKeyValid(key.Base64()) {
+			t.Errorf("point %d: IsPubKeyValid = true", i)
+		}
+	}
+
+	valid := priv.GetPublicKey()
+	if err := valid.Validate(); err != nil || !IsPubKeyValid(valid.Base64()) {
+		t.Errorf("valid key rejected: %v", err)
+	}
+}
+
+// TestValidateNonCanonical 数值 >= p 或最高位为 1 的编码都视为非规范
+func TestValidateNonCanonical(t *testing.T) {
+	priv := mustKey(t)
+	valid := priv.GetPublicKey()
+	highBit := func(k PublicKey) PublicKey {
+		k[31] |= 0x80
+		return k
+	}
+	plus := func(k [KeySize]byte, n *big.Int) PublicKey {
+		key := PublicKey(k)
+		v := new(big.Int).SetBytes(reverseBytes(key[:]))
+		return littleEndianKey(v.Add(v, n))
+	}
+
+	tests := []struct {
+		name string
+		key  PublicKey
+		want KeyErrorReason
+	}{
+		{"p (=0)", littleEndianKey(fieldP), KeyErrorNonCanonical},
+		{"p+1 (=1)", littleEndianKey(new(big.Int).Add(fieldP, big.NewInt(1))), KeyErrorNonCanonical},
+		{"2^255-1", littleEndianKey(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))), KeyErrorNonCanonical},
+		{"order 8 + p", plus(lowOrderPoints[2], fieldP), KeyErrorNonCanonical},
+		{"valid key + p", plus(valid, fieldP), KeyErrorNonCanonical},
+		{"valid key, high bit", highBit(valid), KeyErrorNonCanonical},
+		{"order 8, high bit", highBit(lowOrderPoints[3]), KeyErrorNonCanonical},
+		{"0, high bit", highBit(PublicKey{}), KeyErrorNonCanonical},
+		{"p-1", littleEndianKey(new(big.Int).Sub(fieldP, big.NewInt(1))), KeyErrorLowOrder},
+		{"p-2", littleEndianKey(new(big.Int).Sub(fieldP, big.NewInt(2))), 0},
+		{"valid key", valid, 0},
+	}
+	for _, tt := range tests {
+		if got := keyErrorReason(tt.key.Validate()); got != tt.want {
+			t.Errorf("%s (%s): Validate reason = %v, want %v", tt.name, tt.key.Hex(), got, tt.want)
+		}
+		if IsPubKeyValid(tt.key.Base64()) != (tt.want == 0) {
+			t.Errorf("%s: IsPubKeyValid = %v", tt.name, tt.want != 0)
+		}
+	}
+}
+
+// reverseBytes 小端序与大端序互转
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[len(b)-1-i]
+	}
+	return out
+}
+
+// TestLoadValid 解析失败时返回带原因的 *KeyError
+func TestLoadValid(t *testing.T) {
+	priv := mustKey(t)
+	valid := priv.GetPublicKey()
+	lowOrder := PublicKey(lowOrderPoints[2])
+	nonCanonical := littleEndianKey(fieldP)
+
+	tests := []struct {
+		name string
+		raw  []byte // nil 时使用 text
+		text string
+		want KeyErrorReason
+	}{
+		{name: "valid", raw: valid[:]},
+		{name: "encoding", text: "not a key!", want: KeyErrorEncoding},
+		{name: "short", raw: valid[:31], want: KeyErrorLength},
+		{name: "long", raw: append(valid[:], 0), want: KeyErrorLength},
+		{name: "zero", raw: make([]byte, KeySize), want: KeyErrorZero},
+		{name: "low order", raw: lowOrder[:], want: KeyErrorLowOrder},
+		{name: "non-canonical", raw: nonCanonical[:], want: KeyErrorNonCanonical},
+	}
+	loaders := []struct {
+		name   string
+		encode func([]byte) string
+		load   func(string) (PublicKey, error)
+	}{
+		{"hex", hex.EncodeToString, LoadValidHex},
+		{"base64", base64.StdEncoding.EncodeToString, LoadValidBase64},
+	}
+	for _, l := range loaders {
+		for _, tt := range tests {
+			src := tt.text
+			if tt.raw != nil {
+				src = l.encode(tt.raw)
+			}
+			key, err := l.load(src)
+			if got := keyErrorReason(err); got != tt.want {
+				t.Errorf("%s %s: reason = %v (%v), want %v", l.name, tt.name, got, err, tt.want)
+			}
+			if tt.want == 0 && key != valid {
+				t.Errorf("%s %s: key = %s, want %s", l.name, tt.name, key.Hex(), valid.Hex())
+			}
+			if tt.want != 0 && key != (PublicKey{}) {
+				t.Errorf("%s %s: key = %s on error, want zero", l.name, tt.name, key.Hex())
+			}
+		}
+	}
+	if err := ValidatePubKey("AAAA"); keyErrorReason(err) != KeyErrorLength {
+		t.Errorf("ValidatePubKey short: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package utencrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fangzw1120/utils/utbase"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// WrappedKeyPEMType 加密密钥文件的 PEM 类型
+const WrappedKeyPEMType = "UTENCRYPT WRAPPED KEY"
+
+// wrappedKeyVersion 当前加密密钥文件格式版本
+const wrappedKeyVersion = 1
+
+// KeyKDF 包装密钥的派生方式
+type KeyKDF string
+
+const (
+	// KDFNone 明文存储，内容与 utbase.KeyFileWrite 一致
+	KDFNone KeyKDF = ""
+	// KDFScrypt 口令经 scrypt 派生
+	KDFScrypt KeyKDF = "scrypt"
+	// KDFArgon2id 口令经 argon2id 派生
+	KDFArgon2id KeyKDF = "argon2id"
+	// KDFMachine 本机 machine-id 经 HKDF 派生，文件拷贝到其他机器后无法解开
+	KDFMachine KeyKDF = "machine"
+)
+
+var (
+	// ErrPassphraseRequired 文件使用口令加密，但没有提供口令
+	ErrPassphraseRequired = errors.New("wrapped key: passphrase required")
+	// ErrUnwrapFailed 口令错误、机器不匹配或文件被篡改
+	ErrUnwrapFailed = errors.New("wrapped key: unwrap failed")
+)
+
+// machineIDFiles 机器绑定密钥的来源，按顺序读取第一个存在的文件
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+const (
+	wrapSaltSize = 16
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+// IsWrappedKey 内容是否为加密密钥文件
+func IsWrappedKey(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("-----BEGIN "+WrappedKeyPEMType+"-----"))
+}
+
+// WrapKey ...
+// @Description: 加密密钥内容（base64 私钥或密钥环），输出 PEM 格式的自描述文件
+// @param plaintext
+// @param kdf KDFScrypt、KDFArgon2id 需要 passphrase，KDFMachine 忽略 passphrase
+// @param passphrase
+// @return []byte
+// @return error
+func WrapKey(plaintext []byte, kdf KeyKDF, passphrase []byte) ([]byte, error) {
+	headers := map[string]string{
+		"Version": strconv.Itoa(wrappedKeyVersion),
+		"KDF":     string(kdf),
+		"Cipher":  "xchacha20poly1305",
+	}
+	switch kdf {
+	case KDFScrypt:
+		headers["KDF-Params"] = fmt.Sprintf("N=%d,r=%d,p=%d", scryptN, scryptR, scryptP)
+	case KDFArgon2id:
+		headers["KDF-Params"] = fmt.Sprintf("t=%d,m=%d,p=%d", argon2Time, argon2Memory, argon2Threads)
+	case KDFMachine:
+	default:
+		return nil, fmt.Errorf("WrapKey: unsupported kdf %q", kdf)
+	}
+
+	salt := make([]byte, wrapSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	headers["Salt"] = base64.StdEncoding.EncodeToString(salt)
+	headers["Nonce"] = base64.StdEncoding.EncodeToString(nonce)
+
+	kek, err := deriveWrapKey(headers, salt, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return nil, err
+	}
+	block := &pem.Block{
+		Type:    WrappedKeyPEMType,
+		Headers: headers,
+		Bytes:   aead.Seal(nil, nonce, plaintext, wrapAdditionalData(headers)),
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// UnwrapKey 解密 WrapKey 的输出，KDF 从文件头识别
+func UnwrapKey(content []byte, passphrase []byte) ([]byte, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(content))
+	if block == nil || block.Type != WrappedKeyPEMType {
+		return nil, errors.New("UnwrapKey: not a wrapped key file")
+	}
+	version, err := strconv.Atoi(block.Headers["Version"])
+	if err != nil || version != wrappedKeyVersion {
+		return nil, fmt.Errorf("UnwrapKey: unsupported version %q", block.Headers["Version"])
+	}
+	if cipher := block.Headers["Cipher"]; cipher != "xchacha20poly1305" {
+		return nil, fmt.Errorf("UnwrapKey: unsupported cipher %q", cipher)
+	}
+	salt, err := base64.StdEncoding.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) != wrapSaltSize {
+		return nil, errors.New("UnwrapKey: invalid salt")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil || len(nonce) != chacha20poly1305.NonceSizeX {
+		return nil, errors.New("UnwrapKey: invalid nonce")
+	}
+
+	kek, err := deriveWrapKey(block.Headers, salt, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, block.Bytes, wrapAdditionalData(block.Headers))
+	if err != nil {
+		return nil, ErrUnwrapFailed
+	}
+	return plaintext, nil
+}
+
+// wrapAdditionalData 文件头参与认证，防止篡改 KDF 参数
+func wrapAdditionalData(headers map[string]string) []byte {
+	return []byte(strings.Join([]string{
+		WrappedKeyPEMType, headers["Version"], headers["KDF"], headers["KDF-Params"], headers["Cipher"], headers["Salt"],
+	}, "\n"))
+}
+
+// deriveWrapKey 根据文件头派生包装密钥
+func deriveWrapKey(headers map[string]string, salt []byte, passphrase []byte) ([]byte, error) {
+	kdf := KeyKDF(headers["KDF"])
+	switch kdf {
+	case KDFScrypt, KDFArgon2id:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+	case KDFMachine:
+		machineID, err := readMachineID()
+		if err != nil {
+			return nil, err
+		}
+		kek := make([]byte, chacha20poly1305.KeySize)
+		r := hkdf.New(sha256.New, machineID, salt, []byte("utencrypt machine-bound key v1"))
+		if _, err = io.ReadFull(r, kek); err != nil {
+			return nil, err
+		}
+		return kek, nil
+	default:
+		return nil, fmt.Errorf("wrapped key: unsupported kdf %q", kdf)
+	}
+
+	params, err := parseKDFParams(headers["KDF-Params"])
+	if err != nil {
+		return nil, err
+	}
+	if kdf == KDFScrypt {
+		n, r, p := params["N"], params["r"], params["p"]
+		if n < 2 || n > 1<<20 || n&(n-1) != 0 || r < 1 || r > 32 || p < 1 || p > 16 {
+			return nil, errors.New("wrapped key: scrypt params out of range")
+		}
+		return scrypt.Key(passphrase, salt, n, r, p, chacha20poly1305.KeySize)
+	}
+	t, m, p := params["t"], params["m"], params["p"]
+	if t < 1 || t > 16 || m < 8*1024 || m > 1024*1024 || p < 1 || p > 255 {
+		return nil, errors.New("wrapped key: argon2id params out of range")
+	}
+	return argon2.IDKey(passphrase, salt, uint32(t), uint32(m), uint8(p), chacha20poly1305.KeySize), nil
+}
+
+// parseKDFParams "N=32768,r=8,p=1" to map
+func parseKDFParams(s string) (map[string]int, error) {
+	params := make(map[string]int)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("wrapped key: invalid kdf params %q", s)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("wrapped key: invalid kdf params %q", s)
+		}
+		params[k] = n
+	}
+	return params, nil
+}
+
+// readMachineID 读取本机 machine-id
+func readMachineID() ([]byte, error) {
+	for _, fileName := range machineIDFiles {
+		id, err := os.ReadFile(fileName)
+		if err != nil {
+			continue
+		}
+		id = bytes.TrimSpace(id)
+		if len(id) > 0 {
+			return id, nil
+		}
+	}
+	return nil, errors.New("wrapped key: machine id not found")
+}
+
+// LoadKeyFile 读取密钥文件，自动识别明文和加密格式
+func LoadKeyFile(fileName string, passphrase []byte) (string, error) {
+	content, err := utbase.KeyFileRead(fileName)
+	if err != nil {
+		return "", err
+	}
+	if !IsWrappedKey([]byte(content)) {
+		return content, nil
+	}
+	plaintext, err := UnwrapKey([]byte(content), passphrase)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SaveKeyFile 写入密钥文件，kdf 为 KDFNone 时明文写入；文件权限为 0600，先写临时文件再 rename，不会留下写了一半的密钥
+func SaveKeyFile(fileName string, content string, kdf KeyKDF, passphrase []byte) error {
+	if kdf == KDFNone {
+		return writeKeyFile(fileName, []byte(content))
+	}
+	wrapped, err := WrapKey([]byte(content), kdf, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(fileName, wrapped)
+}
+
+// writeKeyFile 在同一目录写 0600 的临时文件，落盘后 rename 为 fileName
+func writeKeyFile(fileName string, data []byte) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	// CreateTemp 已是 0600，这里再设一次，不受 umask 和平台差异影响
+	if err = f.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// PrivateKeyFileRead 读取私钥文件，自动识别明文和加密格式
+func PrivateKeyFileRead(fileName string, passphrase []byte) (PrivateKey, error) {
+	content, err := LoadKeyFile(fileName, passphrase)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return LoadExactBase64[PrivateKey](strings.TrimSpace(content))
+}
+
+// PrivateKeyFileWrite 私钥写入文件，kdf 为 KDFNone 时内容与 Base64 + utbase.KeyFileWrite 一致，权限为 0600
+func PrivateKeyFileWrite(fileName string, key PrivateKey, kdf KeyKDF, passphrase []byte) error {
+	return SaveKeyFile(fileName, key.Base64(), kdf, passphrase)
+}
+
+// WrappedKeyringFileRead 读取密钥环文件，自动识别明文和加密格式
+func WrappedKeyringFileRead(fileName string, passphrase []byte) (*Keyring, error) {
+	content, err := LoadKeyFile(fileName, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{}
+	if err = k.UnmarshalText([]byte(content)); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// WrappedKeyringFileWrite 密钥环写入文件，kdf 为 KDFNone 时与 KeyringFileWrite 一致
+func WrappedKeyringFileWrite(fileName string, k *Keyring, kdf KeyKDF, passphrase []byte) error {
+	content, err := k.MarshalText()
+	if err != nil {
+		return err
+	}
+	return SaveKeyFile(fileName, string(content), kdf, passphrase)
+}
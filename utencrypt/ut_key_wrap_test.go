@@ -0,0 +1,191 @@
+package utencrypt
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useMachineID 测试期间用临时文件作为 machine-id
+func useMachineID(t *testing.T, id string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "machine-id")
+	if err := os.WriteFile(fileName, []byte(id+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := machineIDFiles
+	machineIDFiles = []string{fileName}
+	t.Cleanup(func() { machineIDFiles = old })
+	return fileName
+}
+
+// rewrapHeaders 修改 PEM 头后重新编码，密文不变
+func rewrapHeaders(t *testing.T, wrapped []byte, edit func(map[string]string)) []byte {
+	t.Helper()
+	block, _ := pem.Decode(wrapped)
+	if block == nil {
+		t.Fatal("not a PEM block")
+	}
+	edit(block.Headers)
+	return pem.EncodeToMemory(block)
+}
+
+// TestWrapKeyRoundTrip 每种 KDF 都能解开自己的输出，文件头记录 KDF 参数
+func TestWrapKeyRoundTrip(t *testing.T) {
+	useMachineID(t, "0123456789abcdef0123456789abcdef")
+	plaintext := []byte("cGxhaW50ZXh0IHByaXZhdGUga2V5IGZvciB0ZXN0cw==")
+	passphrase := []byte("correct horse battery staple")
+
+	tests := []struct {
+		kdf    KeyKDF
+		params string
+	}{
+		{KDFScrypt, "N=32768,r=8,p=1"},
+		{KDFArgon2id, "t=1,m=65536,p=4"},
+		{KDFMachine, ""},
+	}
+	for _, tt := range tests {
+		wrapped, err := WrapKey(plaintext, tt.kdf, passphrase)
+		if err != nil {
+			t.Fatalf("%s: WrapKey: %v", tt.kdf, err)
+		}
+		if !IsWrappedKey(wrapped) || bytes.Contains(wrapped, plaintext) {
+			t.Errorf("%s: output is not a wrapped key or leaks plaintext", tt.kdf)
+		}
+		block, _ := pem.Decode(wrapped)
+		if block.Headers["KDF"] != string(tt.kdf) || block.Headers["KDF-Params"] != tt.params {
+			t.Errorf("%s: headers %v", tt.kdf, block.Headers)
+		}
+		got, err := UnwrapKey(wrapped, passphrase)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: UnwrapKey = %q, %v", tt.kdf, got, err)
+		}
+	}
+
+	if _, err := WrapKey(plaintext, KDFNone, nil); err == nil {
+		t.Error("WrapKey(KDFNone): want error")
+	}
+	if _, err := WrapKey(plaintext, "pbkdf2", passphrase); err == nil {
+		t.Error("WrapKey(pbkdf2): want error")
+	}
+}
+
+// TestUnwrapKeyFailures 口令错误、机器不匹配、文件头或密文被篡改都无法解开
+func TestUnwrapKeyFailures(t *testing.T) {
+	machineID := useMachineID(t, "0123456789abcdef0123456789abcdef")
+	plaintext := []byte("secret")
+	passphrase := []byte("passphrase")
+	wrapped, err := WrapKey(plaintext, KDFScrypt, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = UnwrapKey(wrapped, []byte("wrong")); !errors.Is(err, ErrUnwrapFailed) {
+		t.Errorf("wrong passphrase: err = %v, want ErrUnwrapFailed", err)
+	}
+	if _, err = UnwrapKey(wrapped, nil); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("no passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+
+	// 文件头参与认证：降低 KDF 强度或替换 salt 都会导致解密失败
+	headerTests := []struct {
+		name string
+		edit func(map[string]string)
+		want error
+	}{
+		{"weaker scrypt params", func(h map[string]string) { h["KDF-Params"] = "N=16384,r=8,p=1" }, ErrUnwrapFailed},
+		{"extra params", func(h map[string]string) { h["KDF-Params"] += ",x=1" }, ErrUnwrapFailed},
+		{"salt", func(h map[string]string) { h["Salt"] = "AAAAAAAAAAAAAAAAAAAAAA==" }, ErrUnwrapFailed},
+		{"nonce", func(h map[string]string) { h["Nonce"] = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA" }, ErrUnwrapFailed},
+		{"out of range params", func(h map[string]string) { h["KDF-Params"] = "N=3,r=8,p=1" }, nil},
+		{"kdf", func(h map[string]string) { h["KDF"] = "pbkdf2" }, nil},
+		{"cipher", func(h map[string]string) { h["Cipher"] = "aes256gcm" }, nil},
+		{"version", func(h map[string]string) { h["Version"] = "2" }, nil},
+	}
+	for _, tt := range headerTests {
+		got, err := UnwrapKey(rewrapHeaders(t, wrapped, tt.edit), passphrase)
+		if err == nil {
+			t.Errorf("%s: UnwrapKey = %q, want error", tt.name, got)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	block, _ := pem.Decode(wrapped)
+	for _, i := range []int{0, len(block.Bytes) / 2, len(block.Bytes) - 1} {
+		tampered := *block
+		tampered.Bytes = bytes.Clone(block.Bytes)
+		tampered.Bytes[i] ^= 0x01
+		if _, err = UnwrapKey(pem.EncodeToMemory(&tampered), passphrase); !errors.Is(err, ErrUnwrapFailed) {
+			t.Errorf("ciphertext byte %d flipped: err = %v, want ErrUnwrapFailed", i, err)
+		}
+	}
+
+	// 机器绑定的文件拷贝到 machine-id 不同的机器后无法解开
+	bound, err := WrapKey(plaintext, KDFMachine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(machineID, []byte("fedcba9876543210fedcba9876543210\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = UnwrapKey(bound, nil); !errors.Is(err, ErrUnwrapFailed) {
+		t.Errorf("other machine: err = %v, want ErrUnwrapFailed", err)
+	}
+}
+
+// TestKeyFileWrapped 私钥和密钥环文件加密写入后能按口令读回，明文文件仍可直接读取
+func TestKeyFileWrapped(t *testing.T) {
+	dir := t.TempDir()
+	passphrase := []byte("passphrase")
+	key := mustKey(t)
+
+	fileName := filepath.Join(dir, "private.key")
+	if err := PrivateKeyFileWrite(fileName, key, KDFArgon2id, passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(fileName); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("key file mode = %v, %v, want 0600", fi.Mode().Perm(), err)
+	}
+	if got, err := PrivateKeyFileRead(fileName, passphrase); err != nil || got != key {
+		t.Errorf("PrivateKeyFileRead: %v", err)
+	}
+	if _, err := PrivateKeyFileRead(fileName, []byte("wrong")); !errors.Is(err, ErrUnwrapFailed) {
+		t.Errorf("PrivateKeyFileRead wrong passphrase: err = %v", err)
+	}
+
+	fileName = filepath.Join(dir, "plain.key")
+	if err := PrivateKeyFileWrite(fileName, key, KDFNone, nil); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(fileName); string(content) != key.Base64() {
+		t.Errorf("plain key file = %q, want base64 key", content)
+	}
+	if got, err := PrivateKeyFileRead(fileName, nil); err != nil || got != key {
+		t.Errorf("PrivateKeyFileRead plain: %v", err)
+	}
+
+	k := NewKeyring(key, time.Hour)
+	fileName = filepath.Join(dir, "keyring")
+	if err := WrappedKeyringFileWrite(fileName, k, KDFScrypt, passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := KeyringFileRead(fileName); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("KeyringFileRead wrapped without passphrase: err = %v", err)
+	}
+	got, err := WrappedKeyringFileRead(fileName, passphrase)
+	if err != nil || got.Current().Key != key || got.Overlap() != time.Hour {
+		t.Errorf("WrappedKeyringFileRead: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("dir has %d entries, want 3 without temp files", len(entries))
+	}
+}
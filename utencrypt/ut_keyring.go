@@ -0,0 +1,310 @@
+package utencrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoAcceptableKey 密钥环中没有任何私钥能通过校验
+var ErrNoAcceptableKey = errors.New("keyring: no acceptable key")
+
+// KeyringEntry ...
+// @Description: 密钥环中的一个私钥及其生效时间
+type KeyringEntry struct {
+	Key         PrivateKey
+	ActivatedAt time.Time
+}
+
+// PublicKey 对应的公钥
+func (e *KeyringEntry) PublicKey() PublicKey {
+	return e.Key.GetPublicKey()
+}
+
+// Keyring ...
+// @Description: 网关身份密钥环，保存 previous、current、next 三个私钥
+// next 到达生效时间后自动提升为 current，旧的 current 变为 previous，
+// previous 在 overlap 窗口内仍可用于解密，保证轮换期间不中断会话
+type Keyring struct {
+	mu       sync.RWMutex
+	previous *KeyringEntry
+	current  KeyringEntry
+	next     *KeyringEntry
+	overlap  time.Duration
+}
+
+// NewKeyring 使用当前私钥创建密钥环，overlap 为旧私钥在轮换后继续可用的时长
+func NewKeyring(current PrivateKey, overlap time.Duration) *Keyring {
+	return &Keyring{
+		current: KeyringEntry{Key: current, ActivatedAt: time.Now()},
+		overlap: overlap,
+	}
+}
+
+// Overlap 旧私钥在轮换后继续可用的时长
+func (k *Keyring) Overlap() time.Duration {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.overlap
+}
+
+// Current 当前生效的私钥，next 到期时会先完成轮换
+func (k *Keyring) Current() KeyringEntry {
+	return k.CurrentAt(time.Now())
+}
+
+// CurrentAt 指定时间点生效的私钥
+func (k *Keyring) CurrentAt(now time.Time) KeyringEntry {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.promote(now)
+	return k.current
+}
+
+// Previous 上一个私钥，不存在时返回 false
+func (k *Keyring) Previous() (KeyringEntry, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.promote(time.Now())
+	if k.previous == nil {
+		return KeyringEntry{}, false
+	}
+	return *k.previous, true
+}
+
+// Next 待生效的私钥，不存在时返回 false
+func (k *Keyring) Next() (KeyringEntry, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.promote(time.Now())
+	if k.next == nil {
+		return KeyringEntry{}, false
+	}
+	return *k.next, true
+}
+
+// Stage 预置下一个私钥，在 activateAt 时自动成为 current
+// 预置之后即可把新公钥下发给对端，对端提前切换也能解密
+func (k *Keyring) Stage(next PrivateKey, activateAt time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.promote(time.Now())
+	if !activateAt.After(k.current.ActivatedAt) {
+		return errors.New("keyring: next key must activate after current key")
+	}
+	k.next = &KeyringEntry{Key: next, ActivatedAt: activateAt}
+	return nil
+}
+
+// Rotate 立即把 next 提升为 current，没有 next 时生成一个新私钥
+func (k *Keyring) Rotate() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	k.promote(now)
+	if k.next == nil {
+		key, err := New()
+		if err != nil {
+			return err
+		}
+		k.next = &KeyringEntry{Key: key}
+	}
+	k.next.ActivatedAt = now
+	k.promote(now)
+	return nil
+}
+
+// promote next 到期则轮换，previous 过了 overlap 窗口则丢弃，调用方需持有写锁
+func (k *Keyring) promote(now time.Time) {
+	if k.next != nil && !now.Before(k.next.ActivatedAt) {
+		prev := k.current
+		k.previous = &prev
+		k.current = *k.next
+		k.next = nil
+	}
+	if k.previous != nil && !now.Before(k.current.ActivatedAt.Add(k.overlap)) {
+		k.previous = nil
+	}
+}
+
+// AcceptableKeys 当前可用于解密的私钥，顺序为 current、next、previous
+func (k *Keyring) AcceptableKeys() []PrivateKey {
+	return k.AcceptableKeysAt(time.Now())
+}
+
+// AcceptableKeysAt 指定时间点可用于解密的私钥
+func (k *Keyring) AcceptableKeysAt(now time.Time) []PrivateKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.promote(now)
+	keys := make([]PrivateKey, 0, 3)
+	keys = append(keys, k.current.Key)
+	if k.next != nil {
+		keys = append(keys, k.next.Key)
+	}
+	if k.previous != nil {
+		keys = append(keys, k.previous.Key)
+	}
+	return keys
+}
+
+// SharedSecret 使用 current 私钥计算共享密钥，用于加密
+func (k *Keyring) SharedSecret(publicKey PublicKey) (PresharedKey, error) {
+	current := k.Current()
+	return current.Key.SharedSecret(publicKey)
+}
+
+// TrySharedSecret ...
+// @Description: 依次使用可用私钥计算共享密钥并交给 try 校验（如解密并验证），
+// 返回第一个校验通过的共享密钥和对应私钥的公钥
+// @receiver k
+// @param publicKey 对端公钥
+// @param try 校验函数，返回 nil 表示该共享密钥可用
+// @return PresharedKey
+// @return PublicKey
+// @return error
+func (k *Keyring) TrySharedSecret(publicKey PublicKey, try func(PresharedKey) error) (PresharedKey, PublicKey, error) {
+	var lastErr error
+	for _, key := range k.AcceptableKeys() {
+		secret, err := key.SharedSecret(publicKey)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err = try(secret); err != nil {
+			lastErr = err
+			continue
+		}
+		return secret, key.GetPublicKey(), nil
+	}
+	if lastErr != nil {
+		return PresharedKey{}, PublicKey{}, errors.Join(ErrNoAcceptableKey, lastErr)
+	}
+	return PresharedKey{}, PublicKey{}, ErrNoAcceptableKey
+}
+
+// Open ...
+// @Description: 解密对端用 Seal 发来的消息，依次尝试 current、next、previous，
+// 对端还在用旧公钥或已提前切到新公钥时都能解密
+// @receiver k
+// @param publicKey 对端公钥
+// @param sealed Seal 的输出
+// @param additionalData 与 Seal 时一致
+// @return []byte 明文
+// @return PublicKey 解密成功的私钥对应的公钥，可据此提示对端更新
+// @return error 没有私钥能解密时为 ErrNoAcceptableKey
+func (k *Keyring) Open(publicKey PublicKey, sealed, additionalData []byte) ([]byte, PublicKey, error) {
+	var plaintext []byte
+	_, used, err := k.TrySharedSecret(publicKey, func(secret PresharedKey) error {
+		var err error
+		plaintext, err = openSealed(secret, sealed, additionalData)
+		return err
+	})
+	if err != nil {
+		return nil, PublicKey{}, err
+	}
+	return plaintext, used, nil
+}
+
+// keyringFile 密钥环文件格式
+type keyringFile struct {
+	Overlap  string            `json:"overlap"`
+	Previous *keyringFileEntry `json:"previous,omitempty"`
+	Current  keyringFileEntry  `json:"current"`
+	Next     *keyringFileEntry `json:"next,omitempty"`
+}
+
+type keyringFileEntry struct {
+	Key         string    `json:"key"`
+	ActivatedAt time.Time `json:"activated_at"`
+}
+
+func toFileEntry(e *KeyringEntry) *keyringFileEntry {
+	if e == nil {
+		return nil
+	}
+	return &keyringFileEntry{Key: e.Key.Base64(), ActivatedAt: e.ActivatedAt}
+}
+
+func fromFileEntry(e *keyringFileEntry) (*KeyringEntry, error) {
+	if e == nil {
+		return nil, nil
+	}
+	key, err := LoadExactBase64[PrivateKey](e.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyringEntry{Key: key, ActivatedAt: e.ActivatedAt}, nil
+}
+
+// MarshalText 序列化密钥环
+func (k *Keyring) MarshalText() ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return json.Marshal(keyringFile{
+		Overlap:  k.overlap.String(),
+		Previous: toFileEntry(k.previous),
+		Current:  *toFileEntry(&k.current),
+		Next:     toFileEntry(k.next),
+	})
+}
+
+// UnmarshalText 反序列化密钥环，兼容只有一个 base64 私钥的旧格式
+func (k *Keyring) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if !strings.HasPrefix(s, "{") {
+		key, err := LoadExactBase64[PrivateKey](s)
+		if err != nil {
+			return err
+		}
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		k.previous, k.next = nil, nil
+		k.current = KeyringEntry{Key: key}
+		return nil
+	}
+
+	var f keyringFile
+	if err := json.Unmarshal([]byte(s), &f); err != nil {
+		return err
+	}
+	var overlap time.Duration
+	if f.Overlap != "" {
+		var err error
+		if overlap, err = time.ParseDuration(f.Overlap); err != nil {
+			return err
+		}
+	}
+	previous, err := fromFileEntry(f.Previous)
+	if err != nil {
+		return err
+	}
+	current, err := fromFileEntry(&f.Current)
+	if err != nil {
+		return err
+	}
+	next, err := fromFileEntry(f.Next)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.overlap = overlap
+	k.previous = previous
+	k.current = *current
+	k.next = next
+	return nil
+}
+
+// KeyringFileRead 从密钥文件读取密钥环，文件只有一个私钥时作为 current
+func KeyringFileRead(fileName string) (*Keyring, error) {
+	return WrappedKeyringFileRead(fileName, nil)
+}
+
+// KeyringFileWrite 密钥环写入密钥文件，文件权限为 0600，原子替换
+func KeyringFileWrite(fileName string, k *Keyring) error {
+	return WrappedKeyringFileWrite(fileName, k, KDFNone, nil)
+}
@@ -0,0 +1,178 @@
+package utencrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mustKey 生成私钥，失败时终止测试
+func mustKey(t testing.TB) PrivateKey {
+	t.Helper()
+	key, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// keysEqual 比较私钥列表
+func keysEqual(got, want []PrivateKey) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestKeyringPromote next 到达生效时间后成为 current，旧 current 在 overlap 窗口内作为 previous
+func TestKeyringPromote(t *testing.T) {
+	cur, next := mustKey(t), mustKey(t)
+	k := NewKeyring(cur, time.Hour)
+	start := k.CurrentAt(time.Now()).ActivatedAt
+	activate := start.Add(10 * time.Minute)
+	if err := k.Stage(next, activate); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Stage(next, start); err == nil {
+		t.Error("Stage before current activation: want error")
+	}
+	if err := k.Stage(next, activate); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []PrivateKey
+	}{
+		{"before activation", activate.Add(-time.Second), []PrivateKey{cur, next}},
+		{"at activation", activate, []PrivateKey{next, cur}},
+		{"inside overlap", activate.Add(time.Hour - time.Second), []PrivateKey{next, cur}},
+		{"overlap expired", activate.Add(time.Hour), []PrivateKey{next}},
+	}
+	for _, tt := range tests {
+		if got := k.AcceptableKeysAt(tt.at); !keysEqual(got, tt.want) {
+			t.Errorf("%s: AcceptableKeysAt = %d keys, want %d (current first)", tt.name, len(got), len(tt.want))
+		}
+	}
+	if got := k.CurrentAt(activate.Add(time.Hour)); got.Key != next || !got.ActivatedAt.Equal(activate) {
+		t.Errorf("current after promote = %v, want next activated at %v", got.ActivatedAt, activate)
+	}
+	if _, ok := k.Previous(); ok {
+		t.Error("previous still present after overlap expired")
+	}
+}
+
+// TestKeyringRotate Rotate 立即切换，没有 next 时生成新私钥
+func TestKeyringRotate(t *testing.T) {
+	cur := mustKey(t)
+	k := NewKeyring(cur, time.Minute)
+	if err := k.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if k.Current().Key == cur {
+		t.Fatal("Rotate did not replace current")
+	}
+	if prev, ok := k.Previous(); !ok || prev.Key != cur {
+		t.Errorf("Previous = %v, want old current", ok)
+	}
+	if _, ok := k.Next(); ok {
+		t.Error("Next present after Rotate")
+	}
+}
+
+// TestKeyringOpen 对端用新旧公钥加密的消息在轮换期间都能解开
+func TestKeyringOpen(t *testing.T) {
+	cur, next, peer := mustKey(t), mustKey(t), mustKey(t)
+	k := NewKeyring(cur, time.Hour)
+	if err := k.Stage(next, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("header")
+
+	// next 尚未生效，对端已提前切换到新公钥
+	for _, key := range []PrivateKey{cur, next} {
+		sealed, err := Seal(peer, key.GetPublicKey(), []byte("hello"), aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, used, err := k.Open(peer.GetPublicKey(), sealed, aad)
+		if err != nil || string(plain) != "hello" || used != key.GetPublicKey() {
+			t.Errorf("Open = %q, %v, used expected key %v", plain, err, used == key.GetPublicKey())
+		}
+		if _, _, err = k.Open(peer.GetPublicKey(), sealed, []byte("other")); !errors.Is(err, ErrNoAcceptableKey) {
+			t.Errorf("Open with wrong additional data: err = %v, want ErrNoAcceptableKey", err)
+		}
+	}
+
+	unknown := mustKey(t)
+	sealed, err := Seal(peer, unknown.GetPublicKey(), []byte("hello"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = k.Open(peer.GetPublicKey(), sealed, nil); !errors.Is(err, ErrNoAcceptableKey) {
+		t.Errorf("Open for unknown key: err = %v, want ErrNoAcceptableKey", err)
+	}
+	if _, _, err = k.Open(peer.GetPublicKey(), sealed[:10], nil); !errors.Is(err, ErrNoAcceptableKey) {
+		t.Errorf("Open truncated: err = %v, want ErrNoAcceptableKey", err)
+	}
+}
+
+// TestKeyringPersist 保存再读取后三个私钥、生效时间和 overlap 不变，文件权限为 0600
+func TestKeyringPersist(t *testing.T) {
+	k := NewKeyring(mustKey(t), 90*time.Minute)
+	if err := k.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	next := mustKey(t)
+	if err := k.Stage(next, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "keyring")
+	if err := KeyringFileWrite(fileName, k); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(fileName); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("keyring file mode = %v, %v, want 0600", fi.Mode().Perm(), err)
+	}
+	got, err := KeyringFileRead(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if !keysEqual(got.AcceptableKeysAt(now), k.AcceptableKeysAt(now)) || got.Overlap() != k.Overlap() {
+		t.Error("keyring changed after persist/load")
+	}
+	for _, pair := range [][2]KeyringEntry{
+		{got.CurrentAt(now), k.CurrentAt(now)},
+		{entryOf(got.Next()), entryOf(k.Next())},
+		{entryOf(got.Previous()), entryOf(k.Previous())},
+	} {
+		if pair[0].Key != pair[1].Key || !pair[0].ActivatedAt.Equal(pair[1].ActivatedAt) {
+			t.Errorf("entry = %v, want %v", pair[0].ActivatedAt, pair[1].ActivatedAt)
+		}
+	}
+
+	// 旧格式：文件只有一个 base64 私钥
+	key := mustKey(t)
+	if err = os.WriteFile(fileName, []byte(key.Base64()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = KeyringFileRead(fileName); err != nil || got.Current().Key != key {
+		t.Errorf("legacy keyring file: %v", err)
+	}
+}
+
+// entryOf Previous、Next 的结果，不存在时返回零值
+func entryOf(e KeyringEntry, _ bool) KeyringEntry {
+	return e
+}
@@ -0,0 +1,163 @@
+package utencrypt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mustSigningKey 生成签名私钥，失败时终止测试
+func mustSigningKey(t testing.TB) SigningPrivateKey {
+	t.Helper()
+	key, err := NewSigning()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// TestSigningKey RFC 8032 测试向量，错误的公钥或被改动的消息无法通过校验
+func TestSigningKey(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	var key SigningPrivateKey
+	copy(key[:], seed)
+	pub := key.GetPublicKey()
+	if pub.Hex() != "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a" {
+		t.Errorf("public key = %s", pub.Hex())
+	}
+	sig := key.Sign(nil)
+	wantSig := "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+	if hex.EncodeToString(sig) != wantSig || !pub.Verify(nil, sig) {
+		t.Errorf("signature = %x", sig)
+	}
+	sum := sha256.Sum256(pub[:])
+	if pub.KeyID() != hex.EncodeToString(sum[:8]) || len(pub.KeyID()) != 16 {
+		t.Errorf("KeyID = %s", pub.KeyID())
+	}
+
+	message := []byte("resource update")
+	sig = key.Sign(message)
+	other := mustSigningKey(t)
+	otherPub := other.GetPublicKey()
+	if !pub.Verify(message, sig) {
+		t.Error("valid signature rejected")
+	}
+	if otherPub.Verify(message, sig) {
+		t.Error("signature verified with another key")
+	}
+	if pub.Verify([]byte("resource updatE"), sig) {
+		t.Error("signature verified for a tampered message")
+	}
+	sig[0] ^= 1
+	if pub.Verify(message, sig) {
+		t.Error("tampered signature verified")
+	}
+	if otherPub.KeyID() == pub.KeyID() {
+		t.Error("different keys share a KeyID")
+	}
+}
+
+// TestEnvelopeExpiry 有效期和时钟误差的边界
+func TestEnvelopeExpiry(t *testing.T) {
+	key := mustSigningKey(t)
+	pub := key.GetPublicKey()
+	lookup := func(kid string) (SigningPublicKey, bool) { return pub, kid == pub.KeyID() }
+	t0 := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		now  time.Time
+		skew time.Duration
+		want error
+	}{
+		{"fresh", time.Minute, t0, 0, nil},
+		{"before expiry", time.Minute, t0.Add(59 * time.Second), 0, nil},
+		{"at expiry", time.Minute, t0.Add(time.Minute), 0, ErrEnvelopeExpired},
+		{"expired within skew", time.Minute, t0.Add(65 * time.Second), 10 * time.Second, nil},
+		{"expired beyond skew", time.Minute, t0.Add(71 * time.Second), 10 * time.Second, ErrEnvelopeExpired},
+		{"no expiry", 0, t0.Add(365 * 24 * time.Hour), 0, nil},
+		{"signed in the future", time.Minute, t0.Add(-30 * time.Second), 10 * time.Second, ErrEnvelopeNotYetValid},
+		{"future within skew", time.Minute, t0.Add(-30 * time.Second), time.Minute, nil},
+		{"future at skew", 0, t0.Add(-10 * time.Second), 10 * time.Second, nil},
+	}
+	for _, tt := range tests {
+		e := SignEnvelopeAt(key, []byte("payload"), tt.ttl, t0)
+		if err := e.VerifyWith(lookup, tt.skew, tt.now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifyWith = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestEnvelopeTamper 任何签名字段被改动都无法通过校验，KeyID 找不到时返回 ErrEnvelopeUnknownKey
+func TestEnvelopeTamper(t *testing.T) {
+	key := mustSigningKey(t)
+	pub := key.GetPublicKey()
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		tamper func(e *SignedEnvelope)
+		want   error
+	}{
+		{"payload", func(e *SignedEnvelope) { e.Payload[0] ^= 1 }, ErrEnvelopeSignature},
+		{"timestamp", func(e *SignedEnvelope) { e.Timestamp-- }, ErrEnvelopeSignature},
+		{"expiry", func(e *SignedEnvelope) { e.Expiry += 3600 }, ErrEnvelopeSignature},
+		{"remove expiry", func(e *SignedEnvelope) { e.Expiry = 0 }, ErrEnvelopeSignature},
+		{"signature", func(e *SignedEnvelope) { e.Signature[10] ^= 1 }, ErrEnvelopeSignature},
+		{"truncated signature", func(e *SignedEnvelope) { e.Signature = e.Signature[:32] }, ErrEnvelopeSignature},
+		{"key id", func(e *SignedEnvelope) { e.KeyID = "0000000000000000" }, ErrEnvelopeUnknownKey},
+	}
+	for _, tt := range tests {
+		e := SignEnvelope(key, []byte("payload"), time.Hour)
+		tt.tamper(e)
+		if err := e.Verify(pub, 0); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 另一把密钥的签名
+	other := mustSigningKey(t)
+	e := SignEnvelope(other, []byte("payload"), time.Hour)
+	if err := e.Verify(pub, 0); !errors.Is(err, ErrEnvelopeUnknownKey) {
+		t.Errorf("other key: Verify = %v, want ErrEnvelopeUnknownKey", err)
+	}
+	// lookup 对 KeyID 返回了错误的公钥
+	wrong := func(string) (SigningPublicKey, bool) { return pub, true }
+	if err := e.VerifyWith(wrong, 0, now); !errors.Is(err, ErrEnvelopeSignature) {
+		t.Errorf("wrong key for kid: VerifyWith = %v, want ErrEnvelopeSignature", err)
+	}
+}
+
+// TestEnvelopeRotation 按 KeyID 查找公钥，新旧密钥签名的信封都能校验，序列化后不变
+func TestEnvelopeRotation(t *testing.T) {
+	oldKey, newKey := mustSigningKey(t), mustSigningKey(t)
+	keys := map[string]SigningPublicKey{}
+	for _, k := range []SigningPrivateKey{oldKey, newKey} {
+		pub := k.GetPublicKey()
+		keys[pub.KeyID()] = pub
+	}
+	lookup := func(kid string) (SigningPublicKey, bool) {
+		pub, ok := keys[kid]
+		return pub, ok
+	}
+
+	for _, k := range []SigningPrivateKey{oldKey, newKey} {
+		data, err := SignEnvelope(k, []byte(`{"resource":1}`), time.Minute).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := UnmarshalSignedEnvelope(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = e.VerifyWith(lookup, time.Second, time.Now()); err != nil || string(e.Payload) != `{"resource":1}` {
+			t.Errorf("VerifyWith after round trip = %v, payload %q", err, e.Payload)
+		}
+	}
+	if _, err := UnmarshalSignedEnvelope([]byte("{")); err == nil {
+		t.Error("UnmarshalSignedEnvelope(invalid JSON): want error")
+	}
+}
@@ -0,0 +1,286 @@
+package utip
+
+import (
+	"net"
+)
+
+// AddrFrom4 [4]byte to IPv4 Addr
+func AddrFrom4(addr [4]byte) Addr {
+	return addrFrom4(addr)
+}
+
+// AddrFrom16 [16]byte to IPv6 Addr，IPv4-mapped 地址保持 IPv6 形式，需要时调用 Unmap
+func AddrFrom16(addr [16]byte) Addr {
+	return Addr{
+		addr: uint128{
+			beUint64(addr[:8]),
+			beUint64(addr[8:]),
+		},
+		bitLen: 128,
+	}
+}
+
+// AddrFromSlice 4 或 16 字节转 Addr，长度不对时返回 false
+func AddrFromSlice(slice []byte) (ip Addr, ok bool) {
+	switch len(slice) {
+	case 4:
+		return addrFrom4([4]byte(slice)), true
+	case 16:
+		return AddrFrom16([16]byte(slice)), true
+	}
+	return Addr{}, false
+}
+
+// AddrFromIP net.IP to Addr，IPv4 和 IPv4-mapped 都返回 IPv4 Addr
+func AddrFromIP(ip net.IP) (Addr, bool) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return addrFrom4([4]byte(ip4)), true
+	}
+	return AddrFromSlice(ip)
+}
+
+// IPv6Unspecified ::
+func IPv6Unspecified() Addr { return Addr{bitLen: 128} }
+
+// IPv4Unspecified 0.0.0.0
+func IPv4Unspecified() Addr { return AddrFrom4([4]byte{}) }
+
+// IsValid 是否是有效地址，零值 Addr{} 无效
+func (ip Addr) IsValid() bool { return ip.bitLen != 0 }
+
+// BitLen IPv4 为 32，IPv6 为 128，无效地址为 0
+func (ip Addr) BitLen() int { return int(ip.bitLen) }
+
+// Is4 是否 IPv4
+func (ip Addr) Is4() bool { return ip.bitLen == 32 }
+
+// Is6 是否 IPv6，包括 IPv4-mapped
+func (ip Addr) Is6() bool { return ip.bitLen == 128 }
+
+// Is4In6 是否是 ::ffff:a.b.c.d 形式的 IPv4-mapped IPv6 地址
+func (ip Addr) Is4In6() bool {
+	return ip.Is6() && ip.addr.hi == 0 && ip.addr.lo>>32 == 0xffff
+}
+
+// Unmap IPv4-mapped 地址转成 IPv4，其他地址原样返回
+func (ip Addr) Unmap() Addr {
+	if ip.Is4In6() {
+		ip.bitLen = 32
+		ip.zone = ""
+	}
+	return ip
+}
+
+// Zone IPv6 zone，如 fe80::1%eth0 中的 eth0
+func (ip Addr) Zone() string { return ip.zone }
+
+// WithZone 设置 IPv6 zone，IPv4 地址原样返回
+func (ip Addr) WithZone(zone string) Addr {
+	if !ip.Is6() {
+		return ip
+	}
+	ip.zone = zone
+	return ip
+}
+
+// As16 16 字节形式，IPv4 返回 IPv4-mapped 形式
+func (ip Addr) As16() (a16 [16]byte) {
+	bePutUint64(a16[:8], ip.addr.hi)
+	bePutUint64(a16[8:], ip.addr.lo)
+	return a16
+}
+
+// AsSlice IPv4 返回 4 字节，IPv6 返回 16 字节，无效地址返回 nil
+func (ip Addr) AsSlice() []byte {
+	switch ip.bitLen {
+	case 32:
+		a4 := ip.As4()
+		return a4[:]
+	case 128:
+		a16 := ip.As16()
+		return a16[:]
+	}
+	return nil
+}
+
+// IP Addr to net.IP，丢弃 zone
+func (ip Addr) IP() net.IP {
+	if !ip.IsValid() {
+		return nil
+	}
+	a16 := ip.As16()
+	if ip.Is4() {
+		return net.IP(a16[:]).To4()
+	}
+	return a16[:]
+}
+
+// Compare 先比较地址族（无效 < IPv4 < IPv6），再比较地址和 zone，返回 -1、0、1
+func (ip Addr) Compare(ip2 Addr) int {
+	if ip.bitLen != ip2.bitLen {
+		if ip.bitLen < ip2.bitLen {
+			return -1
+		}
+		return 1
+	}
+	if c := ip.addr.cmp(ip2.addr); c != 0 {
+		return c
+	}
+	switch {
+	case ip.zone < ip2.zone:
+		return -1
+	case ip.zone > ip2.zone:
+		return 1
+	}
+	return 0
+}
+
+// Less ip < ip2
+func (ip Addr) Less(ip2 Addr) bool { return ip.Compare(ip2) == -1 }
+
+// Next 下一个地址，溢出时返回无效地址
+func (ip Addr) Next() Addr {
+	ip.addr = ip.addr.addOne()
+	if ip.Is4() {
+		if uint32(ip.addr.lo) == 0 {
+			return Addr{}
+		}
+	} else if ip.addr.isZero() {
+		return Addr{}
+	}
+	return ip
+}
+
+// Prev 上一个地址，溢出时返回无效地址
+func (ip Addr) Prev() Addr {
+	if ip.Is4() {
+		if uint32(ip.addr.lo) == 0 {
+			return Addr{}
+		}
+	} else if ip.addr.isZero() {
+		return Addr{}
+	}
+	ip.addr = ip.addr.subOne()
+	return ip
+}
+
+// String ...
+// @Description: IPv4 为点分十进制，IPv6 为 RFC 5952 规范格式，IPv4-mapped 为 ::ffff:a.b.c.d
+// @receiver ip
+// @return string
+func (ip Addr) String() string {
+	switch ip.bitLen {
+	case 0:
+		return "invalid IP"
+	case 32:
+		return ip.string4()
+	}
+	if ip.Is4In6() {
+		const max = len("::ffff:255.255.255.255%")
+		ret := make([]byte, 0, max+len(ip.zone))
+		ret = append(ret, "::ffff:"...)
+		ret = ip.appendTo4(ret)
+		if ip.zone != "" {
+			ret = append(ret, '%')
+			ret = append(ret, ip.zone...)
+		}
+		return string(ret)
+	}
+	const max = len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff%")
+	ret := make([]byte, 0, max+len(ip.zone))
+	ret = ip.appendTo6(ret)
+	return string(ret)
+}
+
+// AppendTo String 的 append 版本，用于避免分配
+func (ip Addr) AppendTo(b []byte) []byte {
+	switch ip.bitLen {
+	case 0:
+		return b
+	case 32:
+		return ip.appendTo4(b)
+	}
+	if ip.Is4In6() {
+		b = append(b, "::ffff:"...)
+		b = ip.appendTo4(b)
+		if ip.zone != "" {
+			b = append(b, '%')
+			b = append(b, ip.zone...)
+		}
+		return b
+	}
+	return ip.appendTo6(b)
+}
+
+// appendTo6 最长的连续全零段（至少 2 段）压缩成 ::，十六进制小写且不带前导 0
+func (ip Addr) appendTo6(ret []byte) []byte {
+	zeroStart, zeroEnd := uint8(255), uint8(255)
+	for i := uint8(0); i < 8; i++ {
+		j := i
+		for j < 8 && ip.v6u16(j) == 0 {
+			j++
+		}
+		if l := j - i; l >= 2 && l > zeroEnd-zeroStart {
+			zeroStart = i
+			zeroEnd = j
+		}
+	}
+
+	for i := uint8(0); i < 8; i++ {
+		if i == zeroStart {
+			ret = append(ret, ':', ':')
+			i = zeroEnd
+			if i >= 8 {
+				break
+			}
+		} else if i > 0 {
+			ret = append(ret, ':')
+		}
+		ret = appendHex(ret, ip.v6u16(i))
+	}
+
+	if ip.zone != "" {
+		ret = append(ret, '%')
+		ret = append(ret, ip.zone...)
+	}
+	return ret
+}
+
+// v6u16 第 i 个 16 位段
+func (ip Addr) v6u16(i uint8) uint16 {
+	if i < 4 {
+		return uint16(ip.addr.hi >> ((3 - i) * 16))
+	}
+	return uint16(ip.addr.lo >> ((7 - i) * 16))
+}
+
+func appendHex(b []byte, x uint16) []byte {
+	if x >= 0x1000 {
+		b = append(b, digits[x>>12])
+	}
+	if x >= 0x100 {
+		b = append(b, digits[x>>8&0xf])
+	}
+	if x >= 0x10 {
+		b = append(b, digits[x>>4&0xf])
+	}
+	return append(b, digits[x&0xf])
+}
+
+func beUint64(b []byte) uint64 {
+	_ = b[7] // bounds check hint to compiler
+	return uint64(b[7]) | uint64(b[6])<<8 | uint64(b[5])<<16 | uint64(b[4])<<24 |
+		uint64(b[3])<<32 | uint64(b[2])<<40 | uint64(b[1])<<48 | uint64(b[0])<<56
+}
+
+func bePutUint64(b []byte, v uint64) {
+	_ = b[7] // early bounds check to guarantee safety of writes below
+	b[0] = byte(v >> 56)
+	b[1] = byte(v >> 48)
+	b[2] = byte(v >> 40)
+	b[3] = byte(v >> 32)
+	b[4] = byte(v >> 24)
+	b[5] = byte(v >> 16)
+	b[6] = byte(v >> 8)
+	b[7] = byte(v)
+}
@@ -0,0 +1,166 @@
+package utip
+
+import (
+	"sort"
+)
+
+// counterKey 计数用的 key，比 Addr 少了 zone 字符串，也不需要 map[string]int 那样为每个 key 分配内存
+type counterKey struct {
+	addr uint128
+	bits uint8 // 0 表示 IPv4
+}
+
+// AddrCount 计数结果
+type AddrCount struct {
+	Prefix Prefix // 按地址计数时是 /32 或 /128
+	Count  uint64
+}
+
+// AddrCounter ...
+// @Description: 按地址或按网段（如 IPv4 /24、IPv6 /64）计数，配合 IPScanner 统计大日志，非并发安全
+type AddrCounter struct {
+	bits4  int
+	bits6  int
+	counts map[counterKey]uint64
+	total  uint64
+}
+
+// NewAddrCounter ...
+// @Description: 创建计数器，按地址计数传 32、128，按网段计数传 24、64
+// @param bits4 IPv4 聚合的前缀长度，超出 [0, 32] 时按 32
+// @param bits6 IPv6 聚合的前缀长度，超出 [0, 128] 时按 128
+// @return *AddrCounter
+func NewAddrCounter(bits4, bits6 int) *AddrCounter {
+	if bits4 < 0 || bits4 > 32 {
+		bits4 = 32
+	}
+	if bits6 < 0 || bits6 > 128 {
+		bits6 = 128
+	}
+	return &AddrCounter{bits4: bits4, bits6: bits6, counts: make(map[counterKey]uint64)}
+}
+
+// key 地址按计数器的前缀长度掩码
+func (c *AddrCounter) key(ip Addr) (counterKey, bool) {
+	switch {
+	case ip.Is4():
+		return counterKey{addr: ip.addr.and(mask6(96 + c.bits4))}, true
+	case ip.Is6():
+		return counterKey{addr: ip.addr.and(mask6(c.bits6)), bits: 128}, true
+	}
+	return counterKey{}, false
+}
+
+// Add 计数加一，无效地址忽略
+func (c *AddrCounter) Add(ip Addr) {
+	c.AddN(ip, 1)
+}
+
+// AddN 计数加 n
+func (c *AddrCounter) AddN(ip Addr, n uint64) {
+	if k, ok := c.key(ip); ok {
+		c.counts[k] += n
+		c.total += n
+	}
+}
+
+// Count 地址（所在网段）的计数
+func (c *AddrCounter) Count(ip Addr) uint64 {
+	k, ok := c.key(ip)
+	if !ok {
+		return 0
+	}
+	return c.counts[k]
+}
+
+// Len 不同地址（网段）的个数
+func (c *AddrCounter) Len() int {
+	return len(c.counts)
+}
+
+// Total 所有计数之和
+func (c *AddrCounter) Total() uint64 {
+	return c.total
+}
+
+// Reset 清空计数
+func (c *AddrCounter) Reset() {
+	clear(c.counts)
+	c.total = 0
+}
+
+// result key 转为结果
+func (c *AddrCounter) result(k counterKey, n uint64) AddrCount {
+	if k.bits == 0 {
+		return AddrCount{Prefix: PrefixFrom(Addr{addr: k.addr, bitLen: 32}, c.bits4), Count: n}
+	}
+	return AddrCount{Prefix: PrefixFrom(Addr{addr: k.addr, bitLen: 128}, c.bits6), Count: n}
+}
+
+// countLess a 排在 b 后面：计数小的、计数相同时地址大的
+func countLess(a, b AddrCount) bool {
+	if a.Count != b.Count {
+		return a.Count < b.Count
+	}
+	return b.Prefix.Compare(a.Prefix) < 0
+}
+
+// Top ...
+// @Description: 计数最多的 n 项，按计数从大到小、计数相同按地址排序；
+// 用大小为 n 的小顶堆，内存只和 n 相关
+// @param n n <= 0 时返回全部
+// @return []AddrCount
+func (c *AddrCounter) Top(n int) []AddrCount {
+	if n <= 0 || n > len(c.counts) {
+		n = len(c.counts)
+	}
+	if n == 0 {
+		return nil
+	}
+	h := make([]AddrCount, 0, n)
+	for k, cnt := range c.counts {
+		e := c.result(k, cnt)
+		if len(h) < n {
+			h = append(h, e)
+			siftUp(h, len(h)-1)
+			continue
+		}
+		if countLess(h[0], e) {
+			h[0] = e
+			siftDown(h, 0)
+		}
+	}
+	sort.Slice(h, func(i, j int) bool { return countLess(h[j], h[i]) })
+	return h
+}
+
+// siftUp 小顶堆上浮
+func siftUp(h []AddrCount, i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !countLess(h[i], h[p]) {
+			return
+		}
+		h[i], h[p] = h[p], h[i]
+		i = p
+	}
+}
+
+// siftDown 小顶堆下沉
+func siftDown(h []AddrCount, i int) {
+	for {
+		l := 2*i + 1
+		if l >= len(h) {
+			return
+		}
+		m := l
+		if r := l + 1; r < len(h) && countLess(h[r], h[l]) {
+			m = r
+		}
+		if !countLess(h[m], h[i]) {
+			return
+		}
+		h[i], h[m] = h[m], h[i]
+		i = m
+	}
+}
@@ -0,0 +1,74 @@
+package utip
+
+import (
+	"fmt"
+	"testing"
+)
+
+// countsString 便于比较的结果
+func countsString(res []AddrCount) string {
+	s := ""
+	for _, r := range res {
+		s += fmt.Sprintf("%s=%d ", r.Prefix, r.Count)
+	}
+	return s
+}
+
+// TestAddrCounterTop 按 /24、/64 聚合后取前 n 项，计数相同按地址排序
+func TestAddrCounterTop(t *testing.T) {
+	c := NewAddrCounter(24, 64)
+	add := func(s string, n uint64) { c.AddN(MustParseAddr(s), n) }
+	add("10.0.0.1", 3)
+	add("10.0.0.200", 2) // 与 10.0.0.1 同一个 /24
+	add("10.0.1.1", 5)
+	add("192.168.1.1", 1)
+	add("8.8.8.8", 5)
+	add("2001:db8::1", 4)
+	add("2001:db8::ffff:1", 3) // 与 2001:db8::1 同一个 /64
+	add("2001:db8:0:1::1", 6)
+	add("fe80::1%eth0", 1) // zone 不影响计数
+	add("fe80::2", 1)
+	c.Add(Addr{}) // 无效地址忽略
+
+	if c.Len() != 7 || c.Total() != 31 {
+		t.Fatalf("Len, Total = %d, %d, want 7, 31", c.Len(), c.Total())
+	}
+	if got := c.Count(MustParseAddr("10.0.0.99")); got != 5 {
+		t.Errorf("Count(10.0.0.99) = %d, want 5", got)
+	}
+	if got := c.Count(MustParseAddr("2001:db8::abcd")); got != 7 {
+		t.Errorf("Count(2001:db8::abcd) = %d, want 7", got)
+	}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "2001:db8::/64=7 "},
+		{3, "2001:db8::/64=7 2001:db8:0:1::/64=6 8.8.8.0/24=5 "},
+		{5, "2001:db8::/64=7 2001:db8:0:1::/64=6 8.8.8.0/24=5 10.0.0.0/24=5 10.0.1.0/24=5 "},
+		{0, "2001:db8::/64=7 2001:db8:0:1::/64=6 8.8.8.0/24=5 10.0.0.0/24=5 10.0.1.0/24=5 fe80::/64=2 192.168.1.0/24=1 "},
+	}
+	for _, tt := range tests {
+		if got := countsString(c.Top(tt.n)); got != tt.want {
+			t.Errorf("Top(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+
+	c.Reset()
+	if c.Len() != 0 || c.Total() != 0 || c.Top(3) != nil {
+		t.Errorf("after Reset: Len %d, Total %d, Top %v", c.Len(), c.Total(), c.Top(3))
+	}
+}
+
+// TestAddrCounterPerAddr 按地址计数
+func TestAddrCounterPerAddr(t *testing.T) {
+	c := NewAddrCounter(32, 128)
+	for _, s := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1", "2001:db8::1", "2001:db8::2", "2001:db8::1"} {
+		c.Add(MustParseAddr(s))
+	}
+	want := "10.0.0.1/32=2 2001:db8::1/128=2 10.0.0.2/32=1 2001:db8::2/128=1 "
+	if got := countsString(c.Top(0)); got != want {
+		t.Errorf("Top(0) = %s, want %s", got, want)
+	}
+}
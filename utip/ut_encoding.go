@@ -0,0 +1,297 @@
+package utip
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AddrKeyLen Addr.Key 的长度
+const AddrKeyLen = 16
+
+// PrefixKeyLen Prefix.Key 的长度
+const PrefixKeyLen = 17
+
+// Key ...
+// @Description: 定长 16 字节的 key，按字节比较的顺序与地址大小一致，适合作为数据库或 KV 存储的 key；
+// IPv4 编码为 ::ffff:a.b.c.d，因此与对应的 IPv4-mapped 地址相同，zone 被丢弃
+// @receiver ip
+// @return [AddrKeyLen]byte
+func (ip Addr) Key() [AddrKeyLen]byte {
+	return ip.As16()
+}
+
+// AddrFromKey Key 的逆操作，::ffff:a.b.c.d 还原为 IPv4
+func AddrFromKey(key []byte) (Addr, bool) {
+	if len(key) != AddrKeyLen {
+		return Addr{}, false
+	}
+	return AddrFrom16([AddrKeyLen]byte(key)).Unmap(), true
+}
+
+// Key ...
+// @Description: 定长 17 字节的 key：16 字节掩码后的地址 + 1 字节 IPv6 形式的前缀长度，
+// 按字节比较时同一地址短网段在前，IPv4 网段与对应的 ::ffff:0:0/96 子网段相同
+// @receiver p
+// @return [PrefixKeyLen]byte
+func (p Prefix) Key() (key [PrefixKeyLen]byte) {
+	if !p.IsValid() {
+		return key
+	}
+	a16 := p.Masked().ip.As16()
+	copy(key[:], a16[:])
+	key[AddrKeyLen] = p.offset() + p.bits
+	return key
+}
+
+// PrefixFromKey Key 的逆操作
+func PrefixFromKey(key []byte) (Prefix, bool) {
+	if len(key) != PrefixKeyLen || key[AddrKeyLen] > 128 {
+		return Prefix{}, false
+	}
+	ip := AddrFrom16([AddrKeyLen]byte(key[:AddrKeyLen]))
+	bits := int(key[AddrKeyLen])
+	if ip.Is4In6() && bits >= 96 {
+		return PrefixFrom(ip.Unmap(), bits-96), true
+	}
+	return PrefixFrom(ip, bits), true
+}
+
+// MarshalText 实现 encoding.TextMarshaler，无效地址编码为空字符串
+func (ip Addr) MarshalText() ([]byte, error) {
+	if !ip.IsValid() {
+		return []byte{}, nil
+	}
+	return ip.AppendTo(make([]byte, 0, len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")+1+len(ip.zone))), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler，空字符串解码为无效地址
+func (ip *Addr) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*ip = Addr{}
+		return nil
+	}
+	var err error
+	*ip, err = ParseAddr(string(text))
+	return err
+}
+
+// MarshalBinary ...
+// @Description: 实现 encoding.BinaryMarshaler，与 netip.Addr 的格式一致：
+// 无效地址为 0 字节，IPv4 为 4 字节，IPv6 为 16 字节 + zone
+// @receiver ip
+// @return []byte
+// @return error
+func (ip Addr) MarshalBinary() ([]byte, error) {
+	switch ip.bitLen {
+	case 0:
+		return []byte{}, nil
+	case 32:
+		a4 := ip.As4()
+		return a4[:], nil
+	}
+	b := make([]byte, 16, 16+len(ip.zone))
+	bePutUint64(b[:8], ip.addr.hi)
+	bePutUint64(b[8:], ip.addr.lo)
+	return append(b, ip.zone...), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (ip *Addr) UnmarshalBinary(b []byte) error {
+	switch {
+	case len(b) == 0:
+		*ip = Addr{}
+	case len(b) == 4:
+		*ip = addrFrom4([4]byte(b))
+	case len(b) == 16:
+		*ip = AddrFrom16([16]byte(b))
+	case len(b) > 16:
+		*ip = AddrFrom16([16]byte(b[:16])).WithZone(string(b[16:]))
+	default:
+		return fmt.Errorf("utip.Addr.UnmarshalBinary: unexpected length %d", len(b))
+	}
+	return nil
+}
+
+// MarshalJSON 编码为字符串，无效地址为 ""
+func (ip Addr) MarshalJSON() ([]byte, error) {
+	text, _ := ip.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON 接受字符串或 null
+func (ip *Addr) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*ip = Addr{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return ip.UnmarshalText([]byte(s))
+}
+
+// Value 实现 driver.Valuer，以文本形式存储，无效地址存为 NULL
+func (ip Addr) Value() (driver.Value, error) {
+	if !ip.IsValid() {
+		return nil, nil
+	}
+	return ip.String(), nil
+}
+
+// Scan ...
+// @Description: 实现 sql.Scanner，支持 NULL 和文本列，[]byte 也按文本解析，与 Value 对应；
+// BINARY(16)/BYTEA 等二进制列使用 AddrBinary
+// @receiver ip
+// @param src
+// @return error
+func (ip *Addr) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*ip = Addr{}
+		return nil
+	case string:
+		return ip.UnmarshalText([]byte(v))
+	case []byte:
+		return ip.UnmarshalText(v)
+	}
+	return fmt.Errorf("utip.Addr.Scan: unsupported type %T", src)
+}
+
+// AddrBinary ...
+// @Description: 以 MarshalBinary 格式存取二进制列的 Addr，如 BINARY(16)、VARBINARY、BYTEA：
+// db.Exec(q, utip.AddrBinary(ip))、row.Scan((*utip.AddrBinary)(&ip))
+type AddrBinary Addr
+
+// Value 实现 driver.Valuer，无效地址存为 NULL
+func (b AddrBinary) Value() (driver.Value, error) {
+	if !Addr(b).IsValid() {
+		return nil, nil
+	}
+	return Addr(b).MarshalBinary()
+}
+
+// Scan 实现 sql.Scanner，只接受 NULL 和二进制列
+func (b *AddrBinary) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*b = AddrBinary{}
+		return nil
+	case []byte:
+		return (*Addr)(b).UnmarshalBinary(v)
+	}
+	return fmt.Errorf("utip.AddrBinary.Scan: unsupported type %T", src)
+}
+
+// MarshalText 实现 encoding.TextMarshaler，无效网段编码为空字符串
+func (p Prefix) MarshalText() ([]byte, error) {
+	if !p.IsValid() {
+		return []byte{}, nil
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler，空字符串解码为无效网段
+func (p *Prefix) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*p = Prefix{}
+		return nil
+	}
+	var err error
+	*p, err = ParsePrefix(string(text))
+	return err
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler，地址的二进制格式 + 1 字节前缀长度
+func (p Prefix) MarshalBinary() ([]byte, error) {
+	b, err := p.ip.MarshalBinary()
+	if err != nil || !p.IsValid() {
+		return b, err
+	}
+	return append(b, p.bits), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (p *Prefix) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		*p = Prefix{}
+		return nil
+	}
+	var ip Addr
+	if err := ip.UnmarshalBinary(b[:len(b)-1]); err != nil {
+		return err
+	}
+	np := PrefixFrom(ip, int(b[len(b)-1]))
+	if !np.IsValid() {
+		return errors.New("utip.Prefix.UnmarshalBinary: invalid prefix")
+	}
+	*p = np
+	return nil
+}
+
+// MarshalJSON 编码为字符串，无效网段为 ""
+func (p Prefix) MarshalJSON() ([]byte, error) {
+	text, _ := p.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON 接受字符串或 null
+func (p *Prefix) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*p = Prefix{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return p.UnmarshalText([]byte(s))
+}
+
+// Value 实现 driver.Valuer，以文本形式存储，无效网段存为 NULL
+func (p Prefix) Value() (driver.Value, error) {
+	if !p.IsValid() {
+		return nil, nil
+	}
+	return p.String(), nil
+}
+
+// Scan 实现 sql.Scanner，支持 NULL 和文本列，[]byte 也按文本解析，与 Value 对应；二进制列使用 PrefixBinary
+func (p *Prefix) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = Prefix{}
+		return nil
+	case string:
+		return p.UnmarshalText([]byte(v))
+	case []byte:
+		return p.UnmarshalText(v)
+	}
+	return fmt.Errorf("utip.Prefix.Scan: unsupported type %T", src)
+}
+
+// PrefixBinary 以 MarshalBinary 格式存取二进制列的 Prefix，用法同 AddrBinary
+type PrefixBinary Prefix
+
+// Value 实现 driver.Valuer，无效网段存为 NULL
+func (b PrefixBinary) Value() (driver.Value, error) {
+	if !Prefix(b).IsValid() {
+		return nil, nil
+	}
+	return Prefix(b).MarshalBinary()
+}
+
+// Scan 实现 sql.Scanner，只接受 NULL 和二进制列
+func (b *PrefixBinary) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*b = PrefixBinary{}
+		return nil
+	case []byte:
+		return (*Prefix)(b).UnmarshalBinary(v)
+	}
+	return fmt.Errorf("utip.PrefixBinary.Scan: unsupported type %T", src)
+}
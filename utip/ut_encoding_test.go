@@ -0,0 +1,67 @@
+package utip
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+// TestAddrScanText []byte 按文本解析，包括与二进制长度相同的文本
+func TestAddrScanText(t *testing.T) {
+	for _, s := range []string{"1.2.3.4", "2001:db8::1:2:34", "fe80::1%eth0", "::1"} {
+		want := MustParseAddr(s)
+		v, err := want.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Addr
+		if err := got.Scan([]byte(v.(string))); err != nil || got != want {
+			t.Errorf("Scan([]byte(%q)) = %v, %v, want %v", v, got, err, want)
+		}
+	}
+	var ip Addr
+	if err := ip.Scan([]byte{1, 2, 3, 4}); err == nil {
+		t.Errorf("Scan(binary) = %v, want error", ip)
+	}
+}
+
+// TestBinaryScanner AddrBinary、PrefixBinary 与 MarshalBinary 格式往返
+func TestBinaryScanner(t *testing.T) {
+	for _, s := range []string{"1.2.3.4", "2001:db8::1:2:34", "fe80::1%eth0"} {
+		want := MustParseAddr(s)
+		v, err := AddrBinary(want).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Addr
+		if err := (*AddrBinary)(&got).Scan(v); err != nil || got != want {
+			t.Errorf("AddrBinary round trip %s = %v, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		want := MustParsePrefix(s)
+		v, err := PrefixBinary(want).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Prefix
+		if err := (*PrefixBinary)(&got).Scan(v); err != nil || got != want {
+			t.Errorf("PrefixBinary round trip %s = %v, %v", s, got, err)
+		}
+	}
+
+	var _ driver.Valuer = AddrBinary{}
+	if v, _ := (AddrBinary{}).Value(); v != nil {
+		t.Errorf("AddrBinary{}.Value() = %v, want nil", v)
+	}
+	ip := MustParseAddr("1.2.3.4")
+	if err := (*AddrBinary)(&ip).Scan(nil); err != nil || ip.IsValid() {
+		t.Errorf("Scan(nil) = %v, %v", ip, err)
+	}
+	if err := (*AddrBinary)(&ip).Scan("1.2.3.4"); err == nil {
+		t.Error("AddrBinary.Scan(string): want error")
+	}
+	var p Prefix
+	if err := p.Scan([]byte("10.0.0.0/8")); err != nil || p != MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("Prefix.Scan([]byte) = %v, %v", p, err)
+	}
+}
@@ -3,7 +3,6 @@ package utip
 import (
 	"encoding/binary"
 	"fmt"
-	"math/big"
 	"net"
 	"strconv"
 	"strings"
@@ -119,28 +118,31 @@ func IsSameIP(ip1, ip2 string) bool {
 	return ip1Addr.Equal(ip2Addr)
 }
 
-// Intersect 判断两个网段是否相交
+// Intersect 判断两个网段是否相交，nil 或无效网段返回 false
 func Intersect(n1, n2 *net.IPNet) bool {
-	return n2.Contains(n1.IP) || n1.Contains(n2.IP)
+	p1, ok1 := PrefixFromIPNet(n1)
+	p2, ok2 := PrefixFromIPNet(n2)
+	return ok1 && ok2 && p1.Overlaps(p2)
 }
 
-// ParseIPRedefine same function with net.ParseIP, but less memory
+// ParseIPRedefine same function with net.ParseIP, but less memory, return nil on bad input
 func ParseIPRedefine(ip string) net.IP {
-	tIP, _ := ParseIPv4(ip)
-	return net.IPv4(tIP.As4()[0], tIP.As4()[1], tIP.As4()[2], tIP.As4()[3])
+	tIP, err := ParseAddr(ip)
+	if err != nil || tIP.Zone() != "" {
+		return nil
+	}
+	a16 := tIP.As16()
+	return a16[:]
 }
 
 // IPToInt64 ipv4 to int64
 func IPToInt64(ip net.IP) int64 {
-	ret := big.NewInt(0)
-	ret.SetBytes(ip.To4())
-	return ret.Int64()
+	return int64(IPToUint32(ip))
 }
 
 // Int64ToIP int64 to ipv4
 func Int64ToIP(ip int64) net.IP {
-	newIPStr := fmt.Sprintf("%d.%d.%d.%d", byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip))
-	return net.ParseIP(newIPStr)
+	return Uint32ToIP(uint32(ip))
 }
 
 // MaskToIPv4 ipMask to 255.255.255.255
@@ -172,62 +174,40 @@ func IPv4ToMask(s string) net.IPMask {
 	return net.IPv4Mask(mask[0], mask[1], mask[2], mask[3])
 }
 
-// IsPublicIP ipv4地址是否是公网IP
+// IsPublicIP 地址是否是公网IP，按 IANA 特殊用途地址注册表判断全局可达的单播地址
 func IsPublicIP(ipCIDR string) (bool, error) {
 	IP, _, err := net.ParseCIDR(ipCIDR)
 	if err != nil {
 		return false, err
 	}
-	if IP.IsLoopback() || IP.IsLinkLocalMulticast() || IP.IsLinkLocalUnicast() {
-		return false, nil
-	}
-	if ip4 := IP.To4(); ip4 != nil {
-		switch true {
-		case ip4[0] == 10:
-			return false, nil
-		case ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
-			return false, nil
-		case ip4[0] == 192 && ip4[1] == 168:
-			return false, nil
-		default:
-			return true, nil
-		}
-	}
-	return false, nil
+	return ClassifyIP(IP).IsPublic(), nil
 }
 
-// GetBroadcastIP ipv4 获取该网段广播地址
+// GetBroadcastIP ipv4 获取该网段广播地址，IPv6 网段返回 nil
 func GetBroadcastIP(subnetCidr string) net.IP {
-	// 解析CIDR字符串
-	ip, ipnet, err := net.ParseCIDR(subnetCidr)
+	p, err := ParsePrefix(subnetCidr)
 	if err != nil {
 		log.Errorf("%+v", err)
 		return nil
 	}
-	ip = ip.To4()
-
-	// 计算广播地址
-	mask := ipnet.Mask
-	broadcast := make(net.IP, len(ip))
-	for i := 0; i < len(ip); i++ {
-		broadcast[i] = ip[i] | (^mask[i])
-	}
-	return broadcast
+	return p.Broadcast().IP()
 }
 
+// GetFirstIP ipv4 获取该网段第一个主机地址，即网络地址的下一个；/32 和 IPv6 网段返回 nil
 func GetFirstIP(subnetCidr string) net.IP {
-	// 解析CIDR字符串
-	ip, ipnet, err := net.ParseCIDR(subnetCidr)
+	p, err := ParsePrefix(subnetCidr)
 	if err != nil {
 		log.Errorf("%+v", err)
 		return nil
 	}
-	ip = ipnet.IP.To4()
-	if ip == nil {
+	if !p.Addr().Is4() {
 		return nil
 	}
-	ip[len(ip)-1] = 1
-	return ip
+	first := p.First().Next()
+	if !p.Contains(first) {
+		return nil
+	}
+	return first.IP()
 }
 
 // GetSpecialIPsV1 ...
@@ -363,20 +343,15 @@ func CidrToIPNet(cidr string) *net.IPNet {
 
 // IsSameSubnet 两个cidr字符串，代表两个网段，判断两个网段是否一致
 func IsSameSubnet(ip1, ip2 string) bool {
-	ip1Addr, ip1Net, err := net.ParseCIDR(ip1)
+	p1, err := ParsePrefix(ip1)
 	if err != nil {
 		return false
 	}
-
-	ip2Addr, ip2Net, err := net.ParseCIDR(ip2)
+	p2, err := ParsePrefix(ip2)
 	if err != nil {
 		return false
 	}
-
-	if ip1Net.Contains(ip2Addr) && ip2Net.Contains(ip1Addr) && ip1Net.Mask.String() == ip2Net.Mask.String() {
-		return true
-	}
-	return false
+	return p1.Masked() == p2.Masked()
 }
 
 // IPToUint32 类型转换
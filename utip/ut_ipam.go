@@ -0,0 +1,354 @@
+package utip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrIPAMExhausted 网段中没有可分配的地址
+	ErrIPAMExhausted = errors.New("ipam: address pool exhausted")
+	// ErrIPAMOutOfRange 地址不在网段中
+	ErrIPAMOutOfRange = errors.New("ipam: address out of range")
+	// ErrIPAMReserved 地址已被保留或是特殊地址
+	ErrIPAMReserved = errors.New("ipam: address reserved")
+	// ErrIPAMInUse 地址已被分配
+	ErrIPAMInUse = errors.New("ipam: address in use")
+	// ErrIPAMNotAllocated 地址未被分配
+	ErrIPAMNotAllocated = errors.New("ipam: address not allocated")
+)
+
+// IPAM ...
+// @Description: WireGuard 通道网段的地址分配器，已分配和保留的地址都以有序区间列表保存，
+// 内存只和区间个数相关，/8 或 IPv6 网段也可以使用，并发安全
+type IPAM struct {
+	mu          sync.Mutex
+	prefix      Prefix
+	skipSpecial bool
+	reserved    []Range
+	allocated   []Range
+	cursor      Addr // 不小于它的地址才可能空闲，只用于加速
+}
+
+// NewIPAM ...
+// @Description: 创建地址分配器
+// @param prefix 通道网段，如 172.21.0.1/16
+// @param skipSpecial 是否跳过 GetSpecialIPs 中的特殊地址：IPv4 末位为 0、1、255，IPv6 为网段的第 0、1 个地址
+// @return *IPAM
+// @return error
+func NewIPAM(prefix Prefix, skipSpecial bool) (*IPAM, error) {
+	if !prefix.IsValid() {
+		return nil, errors.New("ipam: invalid prefix")
+	}
+	prefix = prefix.Masked()
+	return &IPAM{
+		prefix:      prefix,
+		skipSpecial: skipSpecial,
+		cursor:      prefix.First(),
+	}, nil
+}
+
+// Prefix 分配器的网段
+func (m *IPAM) Prefix() Prefix {
+	return m.prefix
+}
+
+// isSpecial 是否是需要跳过的特殊地址
+func (m *IPAM) isSpecial(ip Addr) bool {
+	if !m.skipSpecial {
+		return false
+	}
+	if ip.Is4() {
+		last := uint8(ip.addr.lo)
+		return last == 0 || last == 1 || last == 255
+	}
+	first := m.prefix.First()
+	return ip == first || ip == first.Next()
+}
+
+// findRange rr 中包含 ip 的区间下标，不存在返回 -1
+func findRange(rr []Range, ip Addr) int {
+	i := sort.Search(len(rr), func(i int) bool {
+		return rr[i].to.Compare(ip) >= 0
+	})
+	if i < len(rr) && rr[i].from.Compare(ip) <= 0 {
+		return i
+	}
+	return -1
+}
+
+// insertAddr 把 ip 加入区间列表，与相邻区间合并
+func insertAddr(rr []Range, ip Addr) []Range {
+	// 第一个 from > ip 的区间
+	i := sort.Search(len(rr), func(i int) bool {
+		return ip.Less(rr[i].from)
+	})
+	left := i > 0 && rr[i-1].to.Next() == ip
+	right := i < len(rr) && ip.Next() == rr[i].from
+	switch {
+	case left && right:
+		rr[i-1].to = rr[i].to
+		return slices.Delete(rr, i, i+1)
+	case left:
+		rr[i-1].to = ip
+		return rr
+	case right:
+		rr[i].from = ip
+		return rr
+	}
+	return slices.Insert(rr, i, Range{from: ip, to: ip})
+}
+
+// removeAddr 从区间列表中删除 ip，必要时拆分区间
+func removeAddr(rr []Range, i int, ip Addr) []Range {
+	r := rr[i]
+	switch {
+	case r.from == r.to:
+		return slices.Delete(rr, i, i+1)
+	case ip == r.from:
+		rr[i].from = ip.Next()
+	case ip == r.to:
+		rr[i].to = ip.Prev()
+	default:
+		rr[i].to = ip.Prev()
+		return slices.Insert(rr, i+1, Range{from: ip.Next(), to: r.to})
+	}
+	return rr
+}
+
+// Allocate 分配网段中最小的空闲地址
+func (m *IPAM) Allocate() (Addr, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ip := m.cursor
+	for ip.IsValid() && m.prefix.Contains(ip) {
+		if m.isSpecial(ip) {
+			ip = ip.Next()
+			continue
+		}
+		if i := findRange(m.reserved, ip); i >= 0 {
+			ip = m.reserved[i].to.Next()
+			continue
+		}
+		if i := findRange(m.allocated, ip); i >= 0 {
+			ip = m.allocated[i].to.Next()
+			continue
+		}
+		m.allocated = insertAddr(m.allocated, ip)
+		m.cursor = ip.Next()
+		return ip, nil
+	}
+	m.cursor = ip
+	return Addr{}, ErrIPAMExhausted
+}
+
+// AllocateAddr 分配指定地址
+func (m *IPAM) AllocateAddr(ip Addr) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.prefix.Contains(ip) {
+		return fmt.Errorf("%w: %s not in %s", ErrIPAMOutOfRange, ip, m.prefix)
+	}
+	if m.isSpecial(ip) || findRange(m.reserved, ip) >= 0 {
+		return fmt.Errorf("%w: %s", ErrIPAMReserved, ip)
+	}
+	if findRange(m.allocated, ip) >= 0 {
+		return fmt.Errorf("%w: %s", ErrIPAMInUse, ip)
+	}
+	m.allocated = insertAddr(m.allocated, ip)
+	return nil
+}
+
+// Release 释放地址
+func (m *IPAM) Release(ip Addr) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := findRange(m.allocated, ip)
+	if i < 0 || ip.zone != "" {
+		return fmt.Errorf("%w: %s", ErrIPAMNotAllocated, ip)
+	}
+	m.allocated = removeAddr(m.allocated, i, ip)
+	if ip.Less(m.cursor) || !m.cursor.IsValid() {
+		m.cursor = ip
+	}
+	return nil
+}
+
+// Reserve 保留区间，之后不会被分配；区间必须在网段内，且不能与已分配地址重叠
+func (m *IPAM) Reserve(r Range) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !r.IsValid() || !m.prefix.Contains(r.from) || !m.prefix.Contains(r.to) {
+		return fmt.Errorf("%w: %s not in %s", ErrIPAMOutOfRange, r, m.prefix)
+	}
+	for _, a := range m.allocated {
+		if a.Overlaps(r) {
+			return fmt.Errorf("%w: %s overlaps allocated %s", ErrIPAMInUse, r, a)
+		}
+	}
+	m.reserved = mergeRanges(append(m.reserved, r))
+	return nil
+}
+
+// IsAllocated 地址是否已分配
+func (m *IPAM) IsAllocated(ip Addr) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ip.zone == "" && findRange(m.allocated, ip) >= 0
+}
+
+// Allocated 已分配的区间
+func (m *IPAM) Allocated() []Range {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.allocated)
+}
+
+// Reserved 保留的区间
+func (m *IPAM) Reserved() []Range {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.reserved)
+}
+
+// ipamState IPAM 的 JSON 格式
+type ipamState struct {
+	Prefix      string   `json:"prefix"`
+	SkipSpecial bool     `json:"skip_special"`
+	Reserved    []string `json:"reserved,omitempty"`
+	Allocated   []string `json:"allocated,omitempty"`
+}
+
+func rangesToStrings(rr []Range) []string {
+	res := make([]string, 0, len(rr))
+	for _, r := range rr {
+		res = append(res, r.String())
+	}
+	return res
+}
+
+// stringsToRanges 解析区间列表，区间之间重叠说明状态文件已损坏，返回错误；相邻的区间合并
+func stringsToRanges(ss []string) ([]Range, error) {
+	rr := make([]Range, 0, len(ss))
+	for _, s := range ss {
+		r, err := ParseRange(s)
+		if err != nil {
+			return nil, err
+		}
+		rr = append(rr, r)
+	}
+	slices.SortFunc(rr, func(a, b Range) int {
+		return a.from.Compare(b.from)
+	})
+	for i := 1; i < len(rr); i++ {
+		if rr[i-1].Overlaps(rr[i]) {
+			return nil, fmt.Errorf("ipam: %s overlaps %s", rr[i-1], rr[i])
+		}
+	}
+	return mergeRanges(rr), nil
+}
+
+// MarshalJSON ...
+func (m *IPAM) MarshalJSON() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return json.Marshal(ipamState{
+		Prefix:      m.prefix.String(),
+		SkipSpecial: m.skipSpecial,
+		Reserved:    rangesToStrings(m.reserved),
+		Allocated:   rangesToStrings(m.allocated),
+	})
+}
+
+// UnmarshalJSON 区间必须在网段内，已分配和保留的区间不能重叠
+func (m *IPAM) UnmarshalJSON(data []byte) error {
+	var st ipamState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	prefix, err := ParsePrefix(st.Prefix)
+	if err != nil {
+		return err
+	}
+	prefix = prefix.Masked()
+	reserved, err := stringsToRanges(st.Reserved)
+	if err != nil {
+		return err
+	}
+	allocated, err := stringsToRanges(st.Allocated)
+	if err != nil {
+		return err
+	}
+	for _, r := range append(slices.Clone(reserved), allocated...) {
+		if !prefix.Contains(r.from) || !prefix.Contains(r.to) {
+			return fmt.Errorf("%w: %s not in %s", ErrIPAMOutOfRange, r, prefix)
+		}
+	}
+	if both := intersectRanges(reserved, allocated); len(both) > 0 {
+		return fmt.Errorf("%w: %s is both reserved and allocated", ErrIPAMReserved, both[0])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prefix = prefix
+	m.skipSpecial = st.SkipSpecial
+	m.reserved = reserved
+	m.allocated = allocated
+	m.cursor = prefix.First()
+	return nil
+}
+
+// IPAMFileRead 从 JSON 文件恢复分配器
+func IPAMFileRead(fileName string) (*IPAM, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	m := &IPAM{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IPAMFileWrite 分配器状态写入 JSON 文件，权限为 0600，落盘后 rename，不会留下写了一半的文件
+func IPAMFileWrite(fileName string, m *IPAM) (err error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	if err = f.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
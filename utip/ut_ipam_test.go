@@ -0,0 +1,191 @@
+package utip
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// rangesString 便于比较的区间列表
+func rangesString(rr []Range) string {
+	s := ""
+	for _, r := range rr {
+		s += r.String() + " "
+	}
+	return s
+}
+
+// TestIPAMAllocate 分配最小的空闲地址，跳过特殊地址和保留区间，释放后可再次分配
+func TestIPAMAllocate(t *testing.T) {
+	m, err := NewIPAM(MustParsePrefix("10.0.0.1/29"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Prefix().String() != "10.0.0.0/29" {
+		t.Errorf("Prefix = %s, want 10.0.0.0/29", m.Prefix())
+	}
+	if err = m.Reserve(MustParseRange("10.0.0.5-10.0.0.6")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.7"} {
+		ip, err := m.Allocate()
+		if err != nil || ip.String() != want {
+			t.Fatalf("Allocate = %s, %v, want %s", ip, err, want)
+		}
+	}
+	if ip, err := m.Allocate(); !errors.Is(err, ErrIPAMExhausted) {
+		t.Fatalf("Allocate on full pool = %s, %v, want ErrIPAMExhausted", ip, err)
+	}
+	if got := rangesString(m.Allocated()); got != "10.0.0.2-10.0.0.4 10.0.0.7-10.0.0.7 " {
+		t.Errorf("Allocated = %s", got)
+	}
+
+	if err = m.Release(MustParseAddr("10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+	if m.IsAllocated(MustParseAddr("10.0.0.3")) {
+		t.Error("10.0.0.3 still allocated after Release")
+	}
+	if err = m.Release(MustParseAddr("10.0.0.3")); !errors.Is(err, ErrIPAMNotAllocated) {
+		t.Errorf("second Release: err = %v, want ErrIPAMNotAllocated", err)
+	}
+	if ip, err := m.Allocate(); err != nil || ip.String() != "10.0.0.3" {
+		t.Errorf("Allocate after Release = %s, %v, want 10.0.0.3", ip, err)
+	}
+
+	tests := []struct {
+		ip   string
+		want error
+	}{
+		{"10.0.0.5", ErrIPAMReserved},
+		{"10.0.0.1", ErrIPAMReserved}, // 特殊地址
+		{"10.0.0.2", ErrIPAMInUse},
+		{"10.0.0.8", ErrIPAMOutOfRange},
+		{"2001:db8::1", ErrIPAMOutOfRange},
+	}
+	for _, tt := range tests {
+		if err := m.AllocateAddr(MustParseAddr(tt.ip)); !errors.Is(err, tt.want) {
+			t.Errorf("AllocateAddr(%s): err = %v, want %v", tt.ip, err, tt.want)
+		}
+	}
+	if err = m.Reserve(MustParseRange("10.0.0.2-10.0.0.3")); !errors.Is(err, ErrIPAMInUse) {
+		t.Errorf("Reserve allocated: err = %v, want ErrIPAMInUse", err)
+	}
+	if err = m.Reserve(MustParseRange("10.0.0.6-10.0.0.9")); !errors.Is(err, ErrIPAMOutOfRange) {
+		t.Errorf("Reserve outside prefix: err = %v, want ErrIPAMOutOfRange", err)
+	}
+}
+
+// TestIPAMLarge IPv6 跳过网段的第 0、1 个地址，大网段只按区间个数占用内存
+func TestIPAMLarge(t *testing.T) {
+	m, err := NewIPAM(MustParsePrefix("2001:db8::/126"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"2001:db8::2", "2001:db8::3"} {
+		if ip, err := m.Allocate(); err != nil || ip.String() != want {
+			t.Fatalf("Allocate = %s, %v, want %s", ip, err, want)
+		}
+	}
+	if _, err = m.Allocate(); !errors.Is(err, ErrIPAMExhausted) {
+		t.Errorf("Allocate on full /126: err = %v", err)
+	}
+
+	m, err = NewIPAM(MustParsePrefix("10.0.0.0/8"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10000; i++ {
+		if _, err = m.Allocate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rangesString(m.Allocated()); got != "10.0.0.0-10.0.39.15 " {
+		t.Errorf("Allocated = %s, want one range", got)
+	}
+}
+
+// TestIPAMJSON 序列化后恢复的分配器状态一致，文件以 0600 原子写入
+func TestIPAMJSON(t *testing.T) {
+	m, err := NewIPAM(MustParsePrefix("172.21.0.0/24"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Reserve(MustParseRange("172.21.0.100-172.21.0.199")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err = m.Allocate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = m.Release(MustParseAddr("172.21.0.4")); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "ipam.json")
+	if err = IPAMFileWrite(fileName, m); err != nil {
+		t.Fatal(err)
+	}
+	if err = IPAMFileWrite(fileName, m); err != nil { // 覆盖已有文件
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(fileName); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("file mode = %v, %v, want 0600", fi.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(fileName)); len(entries) != 1 {
+		t.Errorf("dir has %d entries, want 1 without temp files", len(entries))
+	}
+
+	got, err := IPAMFileRead(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Prefix() != m.Prefix() || rangesString(got.Allocated()) != rangesString(m.Allocated()) ||
+		rangesString(got.Reserved()) != rangesString(m.Reserved()) {
+		t.Errorf("restored %s %s %s, want %s %s %s", got.Prefix(), got.Allocated(), got.Reserved(),
+			m.Prefix(), m.Allocated(), m.Reserved())
+	}
+	// 恢复后仍跳过特殊地址，先补上释放的空位
+	for _, want := range []string{"172.21.0.4", "172.21.0.7"} {
+		if ip, err := got.Allocate(); err != nil || ip.String() != want {
+			t.Errorf("Allocate after restore = %s, %v, want %s", ip, err, want)
+		}
+	}
+}
+
+// TestIPAMUnmarshalInvalid 区间超出网段、保留与已分配重叠或区间互相重叠时拒绝恢复
+func TestIPAMUnmarshalInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want error // nil 表示只要求出错
+	}{
+		{"allocated outside", `{"prefix":"10.0.0.0/29","allocated":["10.0.0.8"]}`, ErrIPAMOutOfRange},
+		{"reserved outside", `{"prefix":"10.0.0.0/29","reserved":["10.0.0.0/24"]}`, ErrIPAMOutOfRange},
+		{"other family", `{"prefix":"10.0.0.0/29","allocated":["::ffff:10.0.0.2"]}`, ErrIPAMOutOfRange},
+		{"reserved and allocated", `{"prefix":"10.0.0.0/29","reserved":["10.0.0.2-10.0.0.4"],"allocated":["10.0.0.4"]}`, ErrIPAMReserved},
+		{"overlapping allocated", `{"prefix":"10.0.0.0/29","allocated":["10.0.0.2-10.0.0.4","10.0.0.3"]}`, nil},
+		{"bad range", `{"prefix":"10.0.0.0/29","allocated":["10.0.0.x"]}`, nil},
+		{"bad prefix", `{"prefix":"10.0.0.0/33"}`, nil},
+	}
+	for _, tt := range tests {
+		m := &IPAM{}
+		err := json.Unmarshal([]byte(tt.json), m)
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 相邻的区间合并
+	m := &IPAM{}
+	if err := json.Unmarshal([]byte(`{"prefix":"10.0.0.0/29","allocated":["10.0.0.3","10.0.0.2"]}`), m); err != nil {
+		t.Fatal(err)
+	}
+	if got := rangesString(m.Allocated()); got != "10.0.0.2-10.0.0.3 " {
+		t.Errorf("Allocated = %s, want merged range", got)
+	}
+}
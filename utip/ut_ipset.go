@@ -0,0 +1,332 @@
+package utip
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// IPSet ...
+// @Description: 不可变的 IP 集合，内部为有序、不相交、不相邻的区间列表，
+// 查询为二分查找，集合运算为线性归并；通过 IPSetBuilder 或集合运算生成
+type IPSet struct {
+	rr []Range
+}
+
+// IPSetBuilder ...
+// @Description: IPSet 构造器，操作按调用顺序生效，零值可用，非并发安全
+type IPSetBuilder struct {
+	rr      []Range // 已规整的区间
+	pending []Range // 待合并的新增区间
+}
+
+// Add 添加地址
+func (b *IPSetBuilder) Add(ip Addr) {
+	b.AddRange(RangeFrom(ip, ip))
+}
+
+// AddPrefix 添加网段
+func (b *IPSetBuilder) AddPrefix(p Prefix) {
+	b.AddRange(RangeFromPrefix(p))
+}
+
+// AddRange 添加区间，无效区间忽略
+func (b *IPSetBuilder) AddRange(r Range) {
+	if !r.IsValid() {
+		return
+	}
+	b.pending = append(b.pending, r)
+}
+
+// AddSet 添加集合
+func (b *IPSetBuilder) AddSet(s *IPSet) {
+	if s == nil {
+		return
+	}
+	b.pending = append(b.pending, s.rr...)
+}
+
+// Remove 删除地址
+func (b *IPSetBuilder) Remove(ip Addr) {
+	b.RemoveRange(RangeFrom(ip, ip))
+}
+
+// RemovePrefix 删除网段
+func (b *IPSetBuilder) RemovePrefix(p Prefix) {
+	b.RemoveRange(RangeFromPrefix(p))
+}
+
+// RemoveRange 删除区间
+func (b *IPSetBuilder) RemoveRange(r Range) {
+	if !r.IsValid() {
+		return
+	}
+	b.normalize()
+	b.rr = subtractRanges(b.rr, []Range{r})
+}
+
+// RemoveSet 删除集合
+func (b *IPSetBuilder) RemoveSet(s *IPSet) {
+	if s == nil {
+		return
+	}
+	b.normalize()
+	b.rr = subtractRanges(b.rr, s.rr)
+}
+
+// Intersect 只保留同时在 s 中的地址
+func (b *IPSetBuilder) Intersect(s *IPSet) {
+	b.normalize()
+	if s == nil {
+		b.rr = nil
+		return
+	}
+	b.rr = intersectRanges(b.rr, s.rr)
+}
+
+// Complement 取在 universe 中但不在当前集合中的地址
+func (b *IPSetBuilder) Complement(universe *IPSet) {
+	b.normalize()
+	if universe == nil {
+		b.rr = nil
+		return
+	}
+	b.rr = subtractRanges(universe.rr, b.rr)
+}
+
+// IPSet 生成集合，之后 builder 仍可继续使用
+func (b *IPSetBuilder) IPSet() *IPSet {
+	b.normalize()
+	return &IPSet{rr: slices.Clone(b.rr)}
+}
+
+// normalize 合并 pending 到 rr
+func (b *IPSetBuilder) normalize() {
+	if len(b.pending) == 0 {
+		return
+	}
+	all := append(b.rr, b.pending...)
+	b.pending = b.pending[:0]
+	b.rr = mergeRanges(all)
+}
+
+// mergeRanges 排序并合并重叠或相邻的区间，会修改 rr
+func mergeRanges(rr []Range) []Range {
+	if len(rr) == 0 {
+		return nil
+	}
+	slices.SortFunc(rr, func(a, b Range) int {
+		return a.from.Compare(b.from)
+	})
+	out := rr[:1]
+	for _, r := range rr[1:] {
+		last := &out[len(out)-1]
+		if r.from.bitLen == last.to.bitLen && (r.from.Compare(last.to) <= 0 || r.from == last.to.Next()) {
+			if last.to.Less(r.to) {
+				last.to = r.to
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return slices.Clip(out)
+}
+
+// subtractRanges a - b，a、b 均为规整后的区间列表
+func subtractRanges(a, b []Range) []Range {
+	out := make([]Range, 0, len(a))
+	j := 0
+	for _, r := range a {
+		// 跳过完全在 r 之前的 b
+		for j < len(b) && b[j].to.Less(r.from) {
+			j++
+		}
+		cur := r
+		valid := true
+		for k := j; k < len(b) && b[k].from.Compare(cur.to) <= 0; k++ {
+			if b[k].from.bitLen != cur.from.bitLen {
+				continue
+			}
+			if cur.from.Less(b[k].from) {
+				out = append(out, Range{from: cur.from, to: b[k].from.Prev()})
+			}
+			if b[k].to.Compare(cur.to) >= 0 {
+				valid = false
+				break
+			}
+			cur.from = b[k].to.Next()
+		}
+		if valid {
+			out = append(out, cur)
+		}
+	}
+	return out
+}
+
+// intersectRanges a ∩ b，a、b 均为规整后的区间列表
+func intersectRanges(a, b []Range) []Range {
+	var out []Range
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		from, to := a[i].from, a[i].to
+		if from.Less(b[j].from) {
+			from = b[j].from
+		}
+		if b[j].to.Less(to) {
+			to = b[j].to
+		}
+		if from.bitLen == to.bitLen && from.Compare(to) <= 0 {
+			out = append(out, Range{from: from, to: to})
+		}
+		if a[i].to.Less(b[j].to) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// Union s ∪ o
+func (s *IPSet) Union(o *IPSet) *IPSet {
+	var b IPSetBuilder
+	b.AddSet(s)
+	b.AddSet(o)
+	return b.IPSet()
+}
+
+// Intersect s ∩ o
+func (s *IPSet) Intersect(o *IPSet) *IPSet {
+	if s == nil || o == nil {
+		return &IPSet{}
+	}
+	return &IPSet{rr: intersectRanges(s.rr, o.rr)}
+}
+
+// Difference s - o
+func (s *IPSet) Difference(o *IPSet) *IPSet {
+	if s == nil {
+		return &IPSet{}
+	}
+	if o == nil {
+		return &IPSet{rr: slices.Clone(s.rr)}
+	}
+	return &IPSet{rr: subtractRanges(s.rr, o.rr)}
+}
+
+// Complement universe - s
+func (s *IPSet) Complement(universe *IPSet) *IPSet {
+	return universe.Difference(s)
+}
+
+// IsEmpty ...
+func (s *IPSet) IsEmpty() bool {
+	return s == nil || len(s.rr) == 0
+}
+
+// Equal 两个集合是否包含相同的地址
+func (s *IPSet) Equal(o *IPSet) bool {
+	if s.IsEmpty() || o.IsEmpty() {
+		return s.IsEmpty() == o.IsEmpty()
+	}
+	return slices.Equal(s.rr, o.rr)
+}
+
+// search 第一个 to >= ip 的区间下标
+func (s *IPSet) search(ip Addr) int {
+	return sort.Search(len(s.rr), func(i int) bool {
+		return s.rr[i].to.Compare(ip) >= 0
+	})
+}
+
+// Contains 地址是否在集合中
+func (s *IPSet) Contains(ip Addr) bool {
+	if s == nil {
+		return false
+	}
+	i := s.search(ip)
+	return i < len(s.rr) && s.rr[i].Contains(ip)
+}
+
+// ContainsRange 区间是否完全在集合中
+func (s *IPSet) ContainsRange(r Range) bool {
+	if s == nil || !r.IsValid() {
+		return false
+	}
+	i := s.search(r.from)
+	return i < len(s.rr) && s.rr[i].Contains(r.from) && r.to.Compare(s.rr[i].to) <= 0
+}
+
+// ContainsPrefix 网段是否完全在集合中
+func (s *IPSet) ContainsPrefix(p Prefix) bool {
+	return s.ContainsRange(RangeFromPrefix(p))
+}
+
+// OverlapsRange 区间是否与集合相交
+func (s *IPSet) OverlapsRange(r Range) bool {
+	if s == nil || !r.IsValid() {
+		return false
+	}
+	i := s.search(r.from)
+	return i < len(s.rr) && s.rr[i].Overlaps(r)
+}
+
+// OverlapsPrefix 网段是否与集合相交
+func (s *IPSet) OverlapsPrefix(p Prefix) bool {
+	return s.OverlapsRange(RangeFromPrefix(p))
+}
+
+// Overlaps 两个集合是否相交
+func (s *IPSet) Overlaps(o *IPSet) bool {
+	if s == nil || o == nil {
+		return false
+	}
+	i, j := 0, 0
+	for i < len(s.rr) && j < len(o.rr) {
+		if s.rr[i].Overlaps(o.rr[j]) {
+			return true
+		}
+		if s.rr[i].to.Less(o.rr[j].to) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return false
+}
+
+// Ranges 最少的区间列表，按地址排序
+func (s *IPSet) Ranges() []Range {
+	if s == nil {
+		return nil
+	}
+	return slices.Clone(s.rr)
+}
+
+// Prefixes 最少的网段列表，按地址排序
+func (s *IPSet) Prefixes() []Prefix {
+	if s == nil {
+		return nil
+	}
+	var out []Prefix
+	for _, r := range s.rr {
+		out = r.AppendPrefixes(out)
+	}
+	return out
+}
+
+// String 区间列表，如 {10.0.0.0-10.0.0.255, 192.168.1.1}
+func (s *IPSet) String() string {
+	if s == nil {
+		return "{}"
+	}
+	parts := make([]string, 0, len(s.rr))
+	for _, r := range s.rr {
+		if r.from == r.to {
+			parts = append(parts, r.from.String())
+		} else {
+			parts = append(parts, r.String())
+		}
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,314 @@
+package utip
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// setOf 按 ParseRange 的写法建集合
+func setOf(items ...string) *IPSet {
+	var b IPSetBuilder
+	for _, s := range items {
+		b.AddRange(MustParseRange(s))
+	}
+	return b.IPSet()
+}
+
+// prefixesString 便于比较的网段列表
+func prefixesString(ps []Prefix) string {
+	parts := make([]string, len(ps))
+	for i, p := range ps {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// TestIPSetBuilder 按调用顺序添加、删除，结果为最少的区间和网段
+func TestIPSetBuilder(t *testing.T) {
+	var b IPSetBuilder
+	b.AddPrefix(MustParsePrefix("10.0.0.0/24"))
+	b.AddPrefix(MustParsePrefix("10.0.1.0/24")) // 与上一个相邻，合并
+	b.Add(MustParseAddr("10.0.0.5"))            // 已包含
+	b.AddRange(MustParseRange("192.168.1.1-192.168.1.10"))
+	b.AddPrefix(MustParsePrefix("2001:db8::/127"))
+	b.Add(MustParseAddr("2001:db8::2"))
+	b.AddRange(Range{}) // 无效区间忽略
+	before := b.IPSet()
+
+	b.RemovePrefix(MustParsePrefix("10.0.0.128/25"))
+	b.Remove(MustParseAddr("192.168.1.5"))
+	b.Add(MustParseAddr("172.16.0.1"))
+	b.Remove(MustParseAddr("172.16.0.1"))
+	s := b.IPSet()
+
+	wantBefore := "{10.0.0.0-10.0.1.255, 192.168.1.1-192.168.1.10, 2001:db8::-2001:db8::2}"
+	if before.String() != wantBefore {
+		t.Errorf("before Remove = %s, want %s", before, wantBefore)
+	}
+	want := "{10.0.0.0-10.0.0.127, 10.0.1.0-10.0.1.255, 192.168.1.1-192.168.1.4, 192.168.1.6-192.168.1.10, 2001:db8::-2001:db8::2}"
+	if s.String() != want {
+		t.Errorf("IPSet = %s, want %s", s, want)
+	}
+	wantPrefixes := "10.0.0.0/25 10.0.1.0/24 192.168.1.1/32 192.168.1.2/31 192.168.1.4/32 " +
+		"192.168.1.6/31 192.168.1.8/31 192.168.1.10/32 2001:db8::/127 2001:db8::2/128"
+	if got := prefixesString(s.Prefixes()); got != wantPrefixes {
+		t.Errorf("Prefixes = %s, want %s", got, wantPrefixes)
+	}
+	if len(s.Ranges()) != 5 {
+		t.Errorf("Ranges = %v, want 5 ranges", s.Ranges())
+	}
+}
+
+// TestIPSetAlgebra 并集、交集、差集，IPv4 与 IPv6（含映射地址）互不相交
+func TestIPSetAlgebra(t *testing.T) {
+	tests := []struct {
+		name                     string
+		a, b                     []string
+		union, intersect, differ string
+		overlaps                 bool
+	}{
+		{
+			name:      "overlapping v4",
+			a:         []string{"10.0.0.0/24"},
+			b:         []string{"10.0.0.128-10.0.1.10"},
+			union:     "{10.0.0.0-10.0.1.10}",
+			intersect: "{10.0.0.128-10.0.0.255}",
+			differ:    "{10.0.0.0-10.0.0.127}",
+			overlaps:  true,
+		},
+		{
+			name:      "mixed families",
+			a:         []string{"10.0.0.0/24", "2001:db8::/32"},
+			b:         []string{"10.0.0.10", "2001:db8:ffff:ffff::/64", "2001:db9::/32"},
+			union:     "{10.0.0.0-10.0.0.255, 2001:db8::-2001:db9:ffff:ffff:ffff:ffff:ffff:ffff}",
+			intersect: "{10.0.0.10, 2001:db8:ffff:ffff::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff}",
+			differ:    "{10.0.0.0-10.0.0.9, 10.0.0.11-10.0.0.255, 2001:db8::-2001:db8:ffff:fffe:ffff:ffff:ffff:ffff}",
+			overlaps:  true,
+		},
+		{
+			name:      "v4 and v4-mapped v6",
+			a:         []string{"10.0.0.0/8"},
+			b:         []string{"::ffff:10.0.0.0/104"},
+			union:     "{10.0.0.0-10.255.255.255, ::ffff:10.0.0.0-::ffff:10.255.255.255}",
+			intersect: "{}",
+			differ:    "{10.0.0.0-10.255.255.255}",
+		},
+		{
+			name:      "superset",
+			a:         []string{"192.168.1.0/24"},
+			b:         []string{"192.168.0.0/16"},
+			union:     "{192.168.0.0-192.168.255.255}",
+			intersect: "{192.168.1.0-192.168.1.255}",
+			differ:    "{}",
+			overlaps:  true,
+		},
+		{
+			name:      "empty",
+			a:         []string{"10.0.0.1", "10.0.0.3"},
+			union:     "{10.0.0.1, 10.0.0.3}",
+			intersect: "{}",
+			differ:    "{10.0.0.1, 10.0.0.3}",
+		},
+	}
+	for _, tt := range tests {
+		a, b := setOf(tt.a...), setOf(tt.b...)
+		if got := a.Union(b).String(); got != tt.union {
+			t.Errorf("%s: Union = %s, want %s", tt.name, got, tt.union)
+		}
+		if got := a.Intersect(b).String(); got != tt.intersect {
+			t.Errorf("%s: Intersect = %s, want %s", tt.name, got, tt.intersect)
+		}
+		if got := a.Difference(b).String(); got != tt.differ {
+			t.Errorf("%s: Difference = %s, want %s", tt.name, got, tt.differ)
+		}
+		if !a.Union(b).Equal(b.Union(a)) || !a.Intersect(b).Equal(b.Intersect(a)) {
+			t.Errorf("%s: Union or Intersect is not commutative", tt.name)
+		}
+		if a.Overlaps(b) != tt.overlaps || b.Overlaps(a) != tt.overlaps {
+			t.Errorf("%s: Overlaps = %v, want %v", tt.name, a.Overlaps(b), tt.overlaps)
+		}
+		// (a - b) ∪ (a ∩ b) = a
+		if !a.Difference(b).Union(a.Intersect(b)).Equal(a) {
+			t.Errorf("%s: (a-b) ∪ (a∩b) != a", tt.name)
+		}
+	}
+}
+
+// TestIPSetComplement 在全地址空间中取补集，两次取补还原
+func TestIPSetComplement(t *testing.T) {
+	universe := setOf("0.0.0.0/0", "::/0")
+	s := setOf("10.0.0.0/8", "2001:db8::/32")
+	c := s.Complement(universe)
+
+	want := "{0.0.0.0-9.255.255.255, 11.0.0.0-255.255.255.255, " +
+		"::-2001:db7:ffff:ffff:ffff:ffff:ffff:ffff, 2001:db9::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff}"
+	if c.String() != want {
+		t.Errorf("Complement = %s, want %s", c, want)
+	}
+	v4 := c.Intersect(setOf("0.0.0.0/0"))
+	wantPrefixes := "0.0.0.0/5 8.0.0.0/7 11.0.0.0/8 12.0.0.0/6 16.0.0.0/4 32.0.0.0/3 64.0.0.0/2 128.0.0.0/1"
+	if got := prefixesString(v4.Prefixes()); got != wantPrefixes {
+		t.Errorf("v4 complement Prefixes = %s, want %s", got, wantPrefixes)
+	}
+	if !c.Complement(universe).Equal(s) || c.Overlaps(s) || !c.Union(s).Equal(universe) {
+		t.Error("complement does not partition the universe")
+	}
+
+	var b IPSetBuilder
+	b.AddSet(s)
+	b.Complement(universe)
+	if !b.IPSet().Equal(c) {
+		t.Errorf("IPSetBuilder.Complement = %s, want %s", b.IPSet(), c)
+	}
+	if !s.Complement(nil).IsEmpty() || !universe.Complement(universe).IsEmpty() {
+		t.Error("Complement with nil or full universe is not empty")
+	}
+}
+
+// TestIPSetContains 地址、网段、区间的包含与相交
+func TestIPSetContains(t *testing.T) {
+	s := setOf("10.0.0.0/24", "10.0.2.0/24", "2001:db8::/64")
+
+	for ip, want := range map[string]bool{
+		"10.0.0.0":        true,
+		"10.0.0.255":      true,
+		"10.0.1.0":        false,
+		"10.0.2.7":        true,
+		"9.255.255.255":   false,
+		"10.0.3.0":        false,
+		"2001:db8::ffff":  true,
+		"2001:db8:0:1::":  false,
+		"::ffff:10.0.0.1": false, // 映射地址属于 IPv6
+	} {
+		if got := s.Contains(MustParseAddr(ip)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	tests := []struct {
+		in                 string
+		contains, overlaps bool
+	}{
+		{"10.0.0.128/25", true, true},
+		{"10.0.0.5-10.0.0.10", true, true},
+		{"10.0.0.0/23", false, true},
+		{"10.0.0.200-10.0.2.3", false, true},
+		{"10.0.1.255-10.0.2.0", false, true},
+		{"10.0.1.0/24", false, false},
+		{"10.0.3.0/24", false, false},
+		{"10.0.0.0/22", false, true},
+		{"2001:db8::/63", false, true},
+		{"2001:db8::/32", false, true},
+		{"2001:db8::1-2001:db8::ff", true, true},
+		{"::ffff:10.0.0.0/120", false, false},
+	}
+	for _, tt := range tests {
+		r := MustParseRange(tt.in)
+		if got := s.ContainsRange(r); got != tt.contains {
+			t.Errorf("ContainsRange(%s) = %v, want %v", tt.in, got, tt.contains)
+		}
+		if got := s.OverlapsRange(r); got != tt.overlaps {
+			t.Errorf("OverlapsRange(%s) = %v, want %v", tt.in, got, tt.overlaps)
+		}
+		if p, ok := r.Prefix(); ok {
+			if s.ContainsPrefix(p) != tt.contains || s.OverlapsPrefix(p) != tt.overlaps {
+				t.Errorf("ContainsPrefix/OverlapsPrefix(%s) disagree with the range result", p)
+			}
+		}
+	}
+
+	if s.Overlaps(setOf("10.0.1.0/24", "10.0.3.0/24")) || !s.Overlaps(setOf("10.0.1.0/24", "2001:db8::1")) {
+		t.Error("Overlaps between sets")
+	}
+	var nilSet *IPSet
+	if nilSet.Contains(MustParseAddr("10.0.0.1")) || !nilSet.IsEmpty() || nilSet.Overlaps(s) || nilSet.String() != "{}" {
+		t.Error("nil IPSet is not empty")
+	}
+}
+
+// TestIPSetRandom 随机增删后与逐个地址的模型比较
+func TestIPSetRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	base := MustParseAddr("10.0.0.0")
+	addr := func(i int) Addr {
+		a := base
+		for ; i > 0; i-- {
+			a = a.Next()
+		}
+		return a
+	}
+
+	for round := 0; round < 20; round++ {
+		var b IPSetBuilder
+		var model [256]bool
+		for op := 0; op < 30; op++ {
+			from := rnd.Intn(256)
+			to := from + rnd.Intn(256-from)
+			r := RangeFrom(addr(from), addr(to))
+			add := rnd.Intn(3) > 0
+			if add {
+				b.AddRange(r)
+			} else {
+				b.RemoveRange(r)
+			}
+			for i := from; i <= to; i++ {
+				model[i] = add
+			}
+		}
+		s := b.IPSet()
+		for i := range model {
+			if s.Contains(addr(i)) != model[i] {
+				t.Fatalf("round %d: Contains(%s) = %v, want %v", round, addr(i), !model[i], model[i])
+			}
+		}
+		// 区间有序、不相交、不相邻
+		rr := s.Ranges()
+		for i := 1; i < len(rr); i++ {
+			if rr[i].From().Compare(rr[i-1].To().Next()) <= 0 {
+				t.Fatalf("round %d: ranges %s and %s not normalized", round, rr[i-1], rr[i])
+			}
+		}
+	}
+}
+
+// benchSet 约 5 万个随机 IPv4 网段和 IPv6 /64 组成的集合
+func benchSet(b *testing.B) (*IPSet, []Addr) {
+	rnd := rand.New(rand.NewSource(1))
+	var sb IPSetBuilder
+	for i := 0; i < 40000; i++ {
+		ip := AddrFrom4([4]byte{byte(rnd.Intn(224)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0})
+		sb.AddPrefix(PrefixFrom(ip, 24+rnd.Intn(9)).Masked())
+	}
+	for i := 0; i < 10000; i++ {
+		ip := AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		sb.AddPrefix(PrefixFrom(ip, 64))
+	}
+	queries := make([]Addr, 1024)
+	for i := range queries {
+		if i%4 == 0 {
+			queries[i] = AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+		} else {
+			queries[i] = AddrFrom4([4]byte{byte(rnd.Intn(224)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		}
+	}
+	return sb.IPSet(), queries
+}
+
+// BenchmarkIPSetBuild 构建约 5 万项的集合
+func BenchmarkIPSetBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchSet(b)
+	}
+}
+
+// BenchmarkIPSetContains 在约 5 万项的集合中查询
+func BenchmarkIPSetContains(b *testing.B) {
+	s, queries := benchSet(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Contains(queries[i%len(queries)])
+	}
+}
@@ -9,10 +9,12 @@ type uint128 struct {
 }
 
 // Addr ...
-// @Description:
-//
+// @Description: IPv4 或 IPv6 地址，值类型，可以直接比较和作为 map key
+// IPv4 以 ::ffff:a.b.c.d 的形式保存在 addr 中，bitLen 区分地址族
 type Addr struct {
-	addr uint128
+	addr   uint128
+	bitLen uint8
+	zone   string
 }
 
 const digits = "0123456789abcdef"
@@ -23,11 +25,6 @@ func (ip Addr) As4() (a4 [4]byte) {
 	return a4
 }
 
-// String ...
-func (ip Addr) String() string {
-	return ip.string4()
-}
-
 func (ip Addr) string4() string {
 	const max = len("255.255.255.255")
 	ret := make([]byte, 0, max)
@@ -121,5 +118,6 @@ func addrFrom4(addr [4]byte) Addr {
 	return Addr{
 		addr: uint128{0,
 			0xffff00000000 | uint64(addr[0])<<24 | uint64(addr[1])<<16 | uint64(addr[2])<<8 | uint64(addr[3])},
+		bitLen: 32,
 	}
 }
@@ -0,0 +1,167 @@
+package utip
+
+import (
+	"strconv"
+	"strings"
+)
+
+// addrParseError ParseAddr 的错误，带上原始输入
+type addrParseError struct {
+	in  string
+	msg string
+}
+
+func (e *addrParseError) Error() string {
+	return "ParseAddr(" + strconv.Quote(e.in) + "): " + e.msg
+}
+
+// MustParseAddr 解析失败时 panic，用于常量初始化
+func MustParseAddr(s string) Addr {
+	ip, err := ParseAddr(s)
+	if err != nil {
+		panic(err)
+	}
+	return ip
+}
+
+// ParseAddr ...
+// @Description: 解析 IPv4 或 IPv6 地址，支持 zone、:: 压缩、IPv4-mapped 和内嵌 IPv4 写法，
+// 与 netip.ParseAddr 行为一致，成功时不分配内存（带 zone 的地址除外）
+// @param s
+// @return Addr
+// @return error
+func ParseAddr(s string) (Addr, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '.':
+			ip, err := ParseIPv4(s)
+			if err != nil {
+				return Addr{}, &addrParseError{in: s, msg: err.Error()}
+			}
+			return ip, nil
+		case ':':
+			return parseIPv6(s)
+		case '%':
+			// zone 只出现在 IPv6 地址中
+			return Addr{}, &addrParseError{in: s, msg: "missing IPv6 address"}
+		}
+	}
+	return Addr{}, &addrParseError{in: s, msg: "unable to parse IP"}
+}
+
+// parseIPv6 ...
+func parseIPv6(in string) (Addr, error) {
+	s := in
+
+	zone := ""
+	if i := strings.IndexByte(s, '%'); i != -1 {
+		s, zone = s[:i], s[i+1:]
+		if zone == "" {
+			return Addr{}, &addrParseError{in: in, msg: "zone must be a non-empty string"}
+		}
+	}
+
+	var ip [16]byte
+	ellipsis := -1 // :: 所在位置
+
+	// 开头的 ::
+	if len(s) >= 2 && s[0] == ':' && s[1] == ':' {
+		ellipsis = 0
+		s = s[2:]
+		if len(s) == 0 {
+			return IPv6Unspecified().WithZone(zone), nil
+		}
+	}
+
+	i := 0
+	for i < 16 {
+		// 读取一段十六进制
+		off := 0
+		acc := uint32(0)
+		for ; off < len(s); off++ {
+			c := s[off]
+			if c >= '0' && c <= '9' {
+				acc = (acc << 4) + uint32(c-'0')
+			} else if c >= 'a' && c <= 'f' {
+				acc = (acc << 4) + uint32(c-'a'+10)
+			} else if c >= 'A' && c <= 'F' {
+				acc = (acc << 4) + uint32(c-'A'+10)
+			} else {
+				break
+			}
+			if off > 3 {
+				return Addr{}, &addrParseError{in: in, msg: "each colon-separated field must have at most 4 hex digits"}
+			}
+		}
+		if off == 0 {
+			return Addr{}, &addrParseError{in: in, msg: "each colon-separated field must have at least one digit"}
+		}
+
+		// 结尾的内嵌 IPv4，如 ::ffff:1.2.3.4 或 64:ff9b::1.2.3.4
+		if off < len(s) && s[off] == '.' {
+			if ellipsis < 0 && i != 12 {
+				return Addr{}, &addrParseError{in: in, msg: "embedded IPv4 address must replace the final 2 fields of the address"}
+			}
+			if i+4 > 16 {
+				return Addr{}, &addrParseError{in: in, msg: "too many hex fields to fit an embedded IPv4 at the end of the address"}
+			}
+			ip4, err := ParseIPv4(s)
+			if err != nil {
+				return Addr{}, &addrParseError{in: in, msg: err.Error()}
+			}
+			a4 := ip4.As4()
+			copy(ip[i:i+4], a4[:])
+			s = ""
+			i += 4
+			break
+		}
+
+		ip[i] = byte(acc >> 8)
+		ip[i+1] = byte(acc)
+		i += 2
+
+		s = s[off:]
+		if len(s) == 0 {
+			break
+		}
+
+		if s[0] != ':' {
+			return Addr{}, &addrParseError{in: in, msg: "unexpected character, want colon"}
+		} else if len(s) == 1 {
+			return Addr{}, &addrParseError{in: in, msg: "colon must be followed by more characters"}
+		}
+		s = s[1:]
+
+		if s[0] == ':' {
+			if ellipsis >= 0 {
+				return Addr{}, &addrParseError{in: in, msg: "multiple :: in address"}
+			}
+			ellipsis = i
+			s = s[1:]
+			if len(s) == 0 {
+				break
+			}
+		}
+	}
+
+	if len(s) != 0 {
+		return Addr{}, &addrParseError{in: in, msg: "trailing garbage after address"}
+	}
+
+	// :: 展开为若干全零段
+	if i < 16 {
+		if ellipsis < 0 {
+			return Addr{}, &addrParseError{in: in, msg: "address string too short"}
+		}
+		n := 16 - i
+		for j := i - 1; j >= ellipsis; j-- {
+			ip[j+n] = ip[j]
+		}
+		for j := ellipsis + n - 1; j >= ellipsis; j-- {
+			ip[j] = 0
+		}
+	} else if ellipsis >= 0 {
+		return Addr{}, &addrParseError{in: in, msg: "the :: must expand to at least one field of zeros"}
+	}
+	return AddrFrom16(ip).WithZone(zone), nil
+}
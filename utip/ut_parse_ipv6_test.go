@@ -0,0 +1,181 @@
+package utip
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// parseCases 与 netip.ParseAddr 逐项对照的输入
+var parseCases = []string{
+	// IPv4
+	"0.0.0.0",
+	"1.2.3.4",
+	"255.255.255.255",
+	"192.168.001.1",
+	"1.2.3",
+	"1.2.3.4.5",
+	"256.1.1.1",
+	"1.2.3.4%eth0",
+	// IPv6
+	"::",
+	"::1",
+	"2001:db8::1",
+	"2001:DB8::1",
+	"2001:0db8:0000:0000:0000:0000:0000:0001",
+	"2001:db8:0:0:1:0:0:1",
+	"2001:db8::1:0:0:1",
+	"2001:0:0:1::1",
+	"2001:db8:0:1:1:1:1:1",
+	"fe80::1%eth0",
+	"fe80::1%",
+	"ff02::1%1",
+	// 内嵌 IPv4
+	"::ffff:1.2.3.4",
+	"::ffff:0102:0304",
+	"::1.2.3.4",
+	"64:ff9b::192.0.2.33",
+	"2001:db8::1.2.3",
+	"::ffff:1.2.3.4%eth0",
+	// 非法
+	"",
+	":",
+	":::",
+	"1::2::3",
+	"1:2:3:4:5:6:7:8:9",
+	"1:2:3:4:5:6:7::8",
+	"12345::",
+	"g::1",
+	"::1.2.3.4:5",
+	"[::1]",
+}
+
+// TestParseAddrMatchesNetip ParseAddr 与 netip.ParseAddr 的结果、String、zone 一致
+func TestParseAddrMatchesNetip(t *testing.T) {
+	for _, s := range parseCases {
+		want, wantErr := netip.ParseAddr(s)
+		got, err := ParseAddr(s)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("ParseAddr(%q) err = %v, netip err = %v", s, err, wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.String() != want.String() {
+			t.Errorf("ParseAddr(%q).String() = %q, want %q", s, got.String(), want.String())
+		}
+		if got.Zone() != want.Zone() {
+			t.Errorf("ParseAddr(%q).Zone() = %q, want %q", s, got.Zone(), want.Zone())
+		}
+		if got.Is4() != want.Is4() || got.Is4In6() != want.Is4In6() {
+			t.Errorf("ParseAddr(%q) Is4/Is4In6 = %v/%v, want %v/%v", s, got.Is4(), got.Is4In6(), want.Is4(), want.Is4In6())
+		}
+		if got.As16() != want.As16() {
+			t.Errorf("ParseAddr(%q).As16() = %v, want %v", s, got.As16(), want.As16())
+		}
+		// String 的结果可以再解析回同一个地址
+		back, err := ParseAddr(got.String())
+		if err != nil || back != got {
+			t.Errorf("ParseAddr(%q) round trip = %v, %v", got.String(), back, err)
+		}
+	}
+}
+
+// TestAddrStringRFC5952 RFC 5952 第 4 节的规范写法
+func TestAddrStringRFC5952(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1"}, // 4.1 去掉前导 0
+		{"2001:db8:0:0:0:0:2:1", "2001:db8::2:1"},                  // 4.2.1 压缩最长的 0
+		{"2001:db8:0:1:1:1:1:1", "2001:db8:0:1:1:1:1:1"},           // 4.2.2 单个 0 不压缩
+		{"2001:0:0:1:0:0:0:1", "2001:0:0:1::1"},                    // 4.2.3 压缩更长的一段
+		{"2001:db8:0:0:1:0:0:1", "2001:db8::1:0:0:1"},              // 4.2.3 等长时压缩第一段
+		{"2001:DB8::AB", "2001:db8::ab"},                           // 4.3 小写
+		{"0:0:0:0:0:0:0:0", "::"},
+		{"::ffff:102:304", "::ffff:1.2.3.4"}, // 5 IPv4-mapped 用点分写法
+		{"fe80::1%eth0", "fe80::1%eth0"},
+	}
+	for _, tt := range tests {
+		ip, err := ParseAddr(tt.in)
+		if err != nil {
+			t.Fatalf("ParseAddr(%q): %v", tt.in, err)
+		}
+		if got := ip.String(); got != tt.want {
+			t.Errorf("ParseAddr(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestParseIPRedefine 与 net.ParseIP 一致，非法输入返回 nil
+func TestParseIPRedefine(t *testing.T) {
+	for _, s := range parseCases {
+		got, want := ParseIPRedefine(s), net.ParseIP(s)
+		if (got == nil) != (want == nil) {
+			t.Errorf("ParseIPRedefine(%q) = %v, net.ParseIP = %v", s, got, want)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ParseIPRedefine(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+var benchAddrs = []string{"192.168.1.1", "2001:db8::1", "::ffff:10.0.0.1", "fe80::1:2:3:4"}
+
+// BenchmarkParseAddr ...
+func BenchmarkParseAddr(b *testing.B) {
+	for _, s := range benchAddrs {
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseAddr(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkNetipParseAddr 对照
+func BenchmarkNetipParseAddr(b *testing.B) {
+	for _, s := range benchAddrs {
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := netip.ParseAddr(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkNetParseIP 对照
+func BenchmarkNetParseIP(b *testing.B) {
+	for _, s := range benchAddrs {
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if net.ParseIP(s) == nil {
+					b.Fatal("parse failed")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAddrString ...
+func BenchmarkAddrString(b *testing.B) {
+	for _, s := range benchAddrs {
+		ip := MustParseAddr(s)
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = ip.String()
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package utip
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrPlanInvalidRequest 请求的名字或大小不合法
+	ErrPlanInvalidRequest = errors.New("subnet plan: invalid request")
+	// ErrPlanDuplicateName 名字已在规划中
+	ErrPlanDuplicateName = errors.New("subnet plan: duplicate name")
+	// ErrPlanTooLarge 请求的网段比父网段还大
+	ErrPlanTooLarge = errors.New("subnet plan: request larger than parent")
+	// ErrPlanExhausted 剩余地址总数不够
+	ErrPlanExhausted = errors.New("subnet plan: not enough free addresses")
+	// ErrPlanFragmented 剩余地址总数够，但没有足够大的对齐空闲块
+	ErrPlanFragmented = errors.New("subnet plan: free space too fragmented")
+	// ErrPlanOverlap 已有规划中的网段越界或相互重叠
+	ErrPlanOverlap = errors.New("subnet plan: subnet overlaps or outside parent")
+)
+
+// SubnetRequest ...
+// @Description: 子网需求，Bits 和 Hosts 二选一，Bits 不为 nil 时优先
+// Bits 为前缀长度，可用 RequestBits 构造，*Bits 为 0 表示请求整个 /0；
+// Hosts 为可用主机数：IPv4 额外加上网络地址和广播地址，IPv6 不额外占用
+type SubnetRequest struct {
+	Name  string
+	Bits  *int
+	Hosts uint64
+}
+
+// RequestBits 构造 SubnetRequest.Bits
+func RequestBits(bits int) *int {
+	return &bits
+}
+
+// PlannedSubnet 规划结果中的一个子网
+type PlannedSubnet struct {
+	Name   string
+	Prefix Prefix
+}
+
+// PlanError ...
+// @Description: 请求无法放入规划时的详细原因，Err 为 ErrPlan* 之一
+type PlanError struct {
+	Name      string
+	Bits      int    // 需要的前缀长度，-1 表示无法计算
+	Free      string // 剩余地址个数
+	Largest   Prefix // 最大的空闲块，没有空闲时无效
+	ParentLen int
+	Err       error
+}
+
+// Error ...
+func (e *PlanError) Error() string {
+	switch {
+	case errors.Is(e.Err, ErrPlanTooLarge) && e.Bits < 0:
+		return fmt.Sprintf("%v: %q needs more than /0, parent is /%d", e.Err, e.Name, e.ParentLen)
+	case errors.Is(e.Err, ErrPlanTooLarge):
+		return fmt.Sprintf("%v: %q needs /%d, parent is /%d", e.Err, e.Name, e.Bits, e.ParentLen)
+	case errors.Is(e.Err, ErrPlanExhausted), errors.Is(e.Err, ErrPlanFragmented):
+		largest := "none"
+		if e.Largest.IsValid() {
+			largest = e.Largest.String()
+		}
+		return fmt.Sprintf("%v: %q needs /%d, %s addresses free, largest free block %s", e.Err, e.Name, e.Bits, e.Free, largest)
+	}
+	return fmt.Sprintf("%v: %q", e.Err, e.Name)
+}
+
+// Unwrap ...
+func (e *PlanError) Unwrap() error {
+	return e.Err
+}
+
+// SubnetPlan ...
+// @Description: 把父网段切分为多个命名子网，子网都按自身大小对齐且互不重叠，并发安全
+type SubnetPlan struct {
+	mu      sync.Mutex
+	parent  Prefix
+	subnets []PlannedSubnet // 按地址排序
+}
+
+// NewSubnetPlan ...
+// @Description: 以已有的子网创建规划，已有子网必须在父网段内且互不重叠
+// @param parent
+// @param existing 可以为空
+// @return *SubnetPlan
+// @return error
+func NewSubnetPlan(parent Prefix, existing []PlannedSubnet) (*SubnetPlan, error) {
+	if !parent.IsValid() {
+		return nil, errors.New("subnet plan: invalid parent prefix")
+	}
+	p := &SubnetPlan{parent: parent.Masked()}
+	names := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		if s.Name == "" || !s.Prefix.IsValid() {
+			return nil, &PlanError{Name: s.Name, Bits: -1, Err: ErrPlanInvalidRequest}
+		}
+		if names[s.Name] {
+			return nil, &PlanError{Name: s.Name, Bits: -1, Err: ErrPlanDuplicateName}
+		}
+		names[s.Name] = true
+		s.Prefix = s.Prefix.Masked()
+		if !p.parent.ContainsPrefix(s.Prefix) {
+			return nil, fmt.Errorf("%w: %s %s not in %s", ErrPlanOverlap, s.Name, s.Prefix, p.parent)
+		}
+		p.subnets = append(p.subnets, s)
+	}
+	p.sort()
+	for i := 1; i < len(p.subnets); i++ {
+		if p.subnets[i-1].Prefix.Overlaps(p.subnets[i].Prefix) {
+			return nil, fmt.Errorf("%w: %s %s and %s %s", ErrPlanOverlap,
+				p.subnets[i-1].Name, p.subnets[i-1].Prefix, p.subnets[i].Name, p.subnets[i].Prefix)
+		}
+	}
+	return p, nil
+}
+
+// PlanSubnets ...
+// @Description: 在父网段中为一组请求规划子网，从大到小依次放入最合适的空闲块，
+// 任何一个请求放不下时返回 *PlanError，不返回部分结果
+// @param parent
+// @param reqs
+// @return *SubnetPlan
+// @return error
+func PlanSubnets(parent Prefix, reqs []SubnetRequest) (*SubnetPlan, error) {
+	p, err := NewSubnetPlan(parent, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.Add(reqs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Parent 父网段
+func (p *SubnetPlan) Parent() Prefix {
+	return p.parent
+}
+
+// Subnets 已规划的子网，按地址排序
+func (p *SubnetPlan) Subnets() []PlannedSubnet {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.subnets)
+}
+
+// Get 按名字查找子网
+func (p *SubnetPlan) Get(name string) (Prefix, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.subnets {
+		if s.Name == name {
+			return s.Prefix, true
+		}
+	}
+	return Prefix{}, false
+}
+
+// Free 剩余的空闲地址
+func (p *SubnetPlan) Free() *IPSet {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.free()
+}
+
+// Remove 删除子网，返回是否存在
+func (p *SubnetPlan) Remove(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.IndexFunc(p.subnets, func(s PlannedSubnet) bool { return s.Name == name })
+	if i < 0 {
+		return false
+	}
+	p.subnets = slices.Delete(p.subnets, i, i+1)
+	return true
+}
+
+// Add ...
+// @Description: 把新请求放入已有规划，已有子网不移动；全部放得下才生效，否则返回 *PlanError
+// @param reqs
+// @return []PlannedSubnet 新分配的子网，顺序与 reqs 一致
+// @return error
+func (p *SubnetPlan) Add(reqs ...SubnetRequest) ([]PlannedSubnet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	type sized struct {
+		idx  int
+		bits int
+	}
+	order := make([]sized, 0, len(reqs))
+	names := make(map[string]bool, len(p.subnets)+len(reqs))
+	for _, s := range p.subnets {
+		names[s.Name] = true
+	}
+	for i, r := range reqs {
+		if names[r.Name] {
+			return nil, &PlanError{Name: r.Name, Bits: -1, Err: ErrPlanDuplicateName}
+		}
+		names[r.Name] = true
+		b, err := p.requestBits(r)
+		if err != nil {
+			return nil, err
+		}
+		order = append(order, sized{idx: i, bits: b})
+	}
+	// 大的先放，同样大小按请求顺序
+	slices.SortStableFunc(order, func(a, b sized) int { return a.bits - b.bits })
+
+	var bld IPSetBuilder
+	bld.AddSet(p.free())
+	res := make([]PlannedSubnet, len(reqs))
+	for _, o := range order {
+		free := bld.IPSet()
+		block, ok := bestFit(free.Prefixes(), o.bits)
+		if !ok {
+			return nil, p.noSpaceError(reqs[o.idx].Name, o.bits, free)
+		}
+		sub := PrefixFrom(block.Addr(), o.bits)
+		bld.RemovePrefix(sub)
+		res[o.idx] = PlannedSubnet{Name: reqs[o.idx].Name, Prefix: sub}
+	}
+
+	p.subnets = append(p.subnets, res...)
+	p.sort()
+	return res, nil
+}
+
+// requestBits 请求对应的前缀长度
+func (p *SubnetPlan) requestBits(r SubnetRequest) (int, error) {
+	if r.Name == "" {
+		return 0, &PlanError{Name: r.Name, Bits: -1, Err: ErrPlanInvalidRequest}
+	}
+	bitLen := p.parent.Addr().BitLen()
+	var b int
+	switch {
+	case r.Bits != nil:
+		b = *r.Bits
+		if b < 0 {
+			return 0, &PlanError{Name: r.Name, Bits: -1, Err: ErrPlanInvalidRequest}
+		}
+	case r.Hosts > 0:
+		need := r.Hosts
+		if bitLen == 32 {
+			need += 2
+		}
+		if need < r.Hosts {
+			// 溢出
+			return 0, &PlanError{Name: r.Name, Bits: -1, ParentLen: p.parent.Bits(), Err: ErrPlanTooLarge}
+		}
+		b = bitLen - bits.Len64(need-1)
+		if b < 0 {
+			// 主机数超过整个地址空间，先于范围检查报告为过大
+			return 0, &PlanError{Name: r.Name, Bits: -1, ParentLen: p.parent.Bits(), Err: ErrPlanTooLarge}
+		}
+	default:
+		return 0, &PlanError{Name: r.Name, Bits: -1, Err: ErrPlanInvalidRequest}
+	}
+	if b > bitLen {
+		return 0, &PlanError{Name: r.Name, Bits: b, Err: ErrPlanInvalidRequest}
+	}
+	if b < p.parent.Bits() {
+		return 0, &PlanError{Name: r.Name, Bits: b, ParentLen: p.parent.Bits(), Err: ErrPlanTooLarge}
+	}
+	return b, nil
+}
+
+// bestFit 能放下 /want 的最小空闲块，同样大小取地址最小的；blocks 均为对齐网段
+func bestFit(blocks []Prefix, want int) (Prefix, bool) {
+	var best Prefix
+	for _, b := range blocks {
+		if b.Bits() > want {
+			continue
+		}
+		if !best.IsValid() || b.Bits() > best.Bits() {
+			best = b
+		}
+	}
+	return best, best.IsValid()
+}
+
+// noSpaceError 区分地址总数不够和碎片化
+func (p *SubnetPlan) noSpaceError(name string, want int, free *IPSet) error {
+	total := new(big.Int)
+	var largest Prefix
+	for _, b := range free.Prefixes() {
+		total.Add(total, b.NumAddrs())
+		if !largest.IsValid() || b.Bits() < largest.Bits() {
+			largest = b
+		}
+	}
+	need := PrefixFrom(p.parent.Addr(), want).NumAddrs()
+	err := ErrPlanExhausted
+	if total.Cmp(need) >= 0 {
+		err = ErrPlanFragmented
+	}
+	return &PlanError{Name: name, Bits: want, Free: total.String(), Largest: largest, ParentLen: p.parent.Bits(), Err: err}
+}
+
+// free 父网段减去已规划的子网，调用方需持有 p.mu
+func (p *SubnetPlan) free() *IPSet {
+	var b IPSetBuilder
+	b.AddPrefix(p.parent)
+	for _, s := range p.subnets {
+		b.RemovePrefix(s.Prefix)
+	}
+	return b.IPSet()
+}
+
+// sort 按地址排序
+func (p *SubnetPlan) sort() {
+	slices.SortFunc(p.subnets, func(a, b PlannedSubnet) int {
+		return a.Prefix.Compare(b.Prefix)
+	})
+}
+
+// String 如 10.0.0.0/16{channel=10.0.0.0/20, route=10.0.16.0/24}
+func (p *SubnetPlan) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	parts := make([]string, 0, len(p.subnets))
+	for _, s := range p.subnets {
+		parts = append(parts, s.Name+"="+s.Prefix.String())
+	}
+	return p.parent.String() + "{" + strings.Join(parts, ", ") + "}"
+}
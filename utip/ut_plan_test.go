@@ -0,0 +1,222 @@
+package utip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestPlanRequestTooLarge 主机数超过整个地址空间时报告 ErrPlanTooLarge
+func TestPlanRequestTooLarge(t *testing.T) {
+	tests := []struct {
+		parent string
+		req    SubnetRequest
+		want   error
+	}{
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Hosts: 1 << 32}, ErrPlanTooLarge},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Hosts: 1<<32 - 2}, ErrPlanTooLarge},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Hosts: 1<<64 - 1}, ErrPlanTooLarge},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Bits: RequestBits(7)}, ErrPlanTooLarge},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Bits: RequestBits(33)}, ErrPlanInvalidRequest},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Bits: RequestBits(0)}, ErrPlanTooLarge},
+		{"10.0.0.0/8", SubnetRequest{Name: "a", Bits: RequestBits(-1)}, ErrPlanInvalidRequest},
+		{"10.0.0.0/8", SubnetRequest{Name: "a"}, ErrPlanInvalidRequest},
+		{"10.0.0.0/8", SubnetRequest{Bits: RequestBits(24)}, ErrPlanInvalidRequest},
+		{"2001:db8::/96", SubnetRequest{Name: "a", Hosts: 1<<64 - 1}, ErrPlanTooLarge},
+		{"2001:db8::/32", SubnetRequest{Name: "a", Bits: RequestBits(129)}, ErrPlanInvalidRequest},
+	}
+	for _, tt := range tests {
+		plan, err := NewSubnetPlan(MustParsePrefix(tt.parent), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = plan.Add(tt.req)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s Add(%+v) = %v, want %v", tt.parent, tt.req, err, tt.want)
+		}
+	}
+}
+
+// TestPlanWholeSpace 整个 /0 可用 Bits 0 请求，IPv4 也可用 Hosts 请求
+func TestPlanWholeSpace(t *testing.T) {
+	tests := []struct {
+		parent string
+		req    SubnetRequest
+	}{
+		{"0.0.0.0/0", SubnetRequest{Name: "all", Hosts: 1<<32 - 2}},
+		{"0.0.0.0/0", SubnetRequest{Name: "all", Bits: RequestBits(0)}},
+		{"::/0", SubnetRequest{Name: "all", Bits: RequestBits(0)}},
+		{"::/0", SubnetRequest{Name: "all", Bits: RequestBits(0), Hosts: 1}},
+	}
+	for _, tt := range tests {
+		plan, err := PlanSubnets(MustParsePrefix(tt.parent), []SubnetRequest{tt.req})
+		if err != nil {
+			t.Errorf("%s PlanSubnets(%+v): %v", tt.parent, tt.req, err)
+			continue
+		}
+		if p, _ := plan.Get("all"); p.String() != tt.parent {
+			t.Errorf("%s PlanSubnets(%+v) = %s", tt.parent, tt.req, p)
+		}
+	}
+}
+
+// plannedString 如 a=10.0.0.0/24 b=10.0.1.0/25
+func plannedString(subnets []PlannedSubnet) string {
+	parts := make([]string, 0, len(subnets))
+	for _, s := range subnets {
+		parts = append(parts, s.Name+"="+s.Prefix.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// TestPlanSubnets 子网按自身大小对齐，放入能放下的最小空闲块，结果顺序与请求一致
+func TestPlanSubnets(t *testing.T) {
+	tests := []struct {
+		parent string
+		reqs   []SubnetRequest
+		want   string
+	}{
+		{"10.0.0.0/16", []SubnetRequest{
+			{Name: "a", Hosts: 100},
+			{Name: "b", Bits: RequestBits(24)},
+			{Name: "c", Hosts: 2},
+			{Name: "d", Bits: RequestBits(20)},
+		}, "a=10.0.17.0/25 b=10.0.16.0/24 c=10.0.17.128/30 d=10.0.0.0/20"},
+		// 254 个主机正好是 /24，255 个需要 /23
+		{"10.0.0.0/16", []SubnetRequest{
+			{Name: "a", Hosts: 254},
+			{Name: "b", Hosts: 255},
+		}, "a=10.0.2.0/24 b=10.0.0.0/23"},
+		// 父网段不是规范写法时按 Masked 处理
+		{"10.0.0.77/24", []SubnetRequest{{Name: "a", Bits: RequestBits(26)}}, "a=10.0.0.0/26"},
+		{"2001:db8::/48", []SubnetRequest{
+			{Name: "hosts", Hosts: 1 << 16},
+			{Name: "lan", Bits: RequestBits(64)},
+		}, "hosts=2001:db8:0:1::/112 lan=2001:db8::/64"},
+	}
+	for _, tt := range tests {
+		plan, err := PlanSubnets(MustParsePrefix(tt.parent), tt.reqs)
+		if err != nil {
+			t.Errorf("%s: %v", tt.parent, err)
+			continue
+		}
+		subnets := plan.Subnets()
+		want := strings.Fields(tt.want)
+		for i, r := range tt.reqs {
+			p, ok := plan.Get(r.Name)
+			if !ok || r.Name+"="+p.String() != want[i] {
+				t.Errorf("%s: %s = %s, want %s", tt.parent, r.Name, p, want[i])
+			}
+			if p != p.Masked() || !plan.Parent().ContainsPrefix(p) {
+				t.Errorf("%s: %s = %s not aligned inside %s", tt.parent, r.Name, p, plan.Parent())
+			}
+		}
+		for i := 1; i < len(subnets); i++ {
+			if subnets[i-1].Prefix.Compare(subnets[i].Prefix) >= 0 || subnets[i-1].Prefix.Overlaps(subnets[i].Prefix) {
+				t.Errorf("%s: Subnets not sorted or overlapping: %s", tt.parent, plannedString(subnets))
+			}
+		}
+	}
+}
+
+// TestPlanLargestFirst 按从小到大的顺序请求也能把父网段恰好填满，不产生碎片
+func TestPlanLargestFirst(t *testing.T) {
+	reqs := []SubnetRequest{
+		{Name: "s1", Bits: RequestBits(28)},
+		{Name: "s2", Bits: RequestBits(28)},
+		{Name: "s3", Bits: RequestBits(28)},
+		{Name: "s4", Bits: RequestBits(28)},
+		{Name: "m", Bits: RequestBits(26)},
+		{Name: "l", Hosts: 126},
+	}
+	plan, err := PlanSubnets(MustParsePrefix("10.0.0.0/24"), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "l=10.0.0.0/25 m=10.0.0.128/26 s1=10.0.0.192/28 s2=10.0.0.208/28 s3=10.0.0.224/28 s4=10.0.0.240/28"
+	if got := plannedString(plan.Subnets()); got != want {
+		t.Errorf("Subnets = %s, want %s", got, want)
+	}
+	if !plan.Free().Equal(new(IPSet)) {
+		t.Errorf("Free = %s, want empty", prefixesString(plan.Free().Prefixes()))
+	}
+
+	_, err = plan.Add(SubnetRequest{Name: "more", Hosts: 1})
+	var pe *PlanError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrPlanExhausted) || pe.Bits != 30 || pe.Free != "0" || pe.Largest.IsValid() {
+		t.Errorf("Add to full plan = %v", err)
+	}
+}
+
+// TestPlanAddExisting 在已有规划中放入新子网，已有子网不移动，放不下时整体不生效
+func TestPlanAddExisting(t *testing.T) {
+	plan, err := NewSubnetPlan(MustParsePrefix("10.0.0.0/24"), []PlannedSubnet{
+		{Name: "y", Prefix: MustParsePrefix("10.0.0.192/27")},
+		{Name: "x", Prefix: MustParsePrefix("10.0.0.64/26")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := plan.Add(SubnetRequest{Name: "a", Bits: RequestBits(26)})
+	if err != nil || plannedString(res) != "a=10.0.0.0/26" {
+		t.Fatalf("Add(a) = %s, %v", plannedString(res), err)
+	}
+	// 先填满 /27 的空闲块，再切分 /26
+	res, err = plan.Add(SubnetRequest{Name: "b", Bits: RequestBits(27)}, SubnetRequest{Name: "c", Hosts: 30})
+	if err != nil || plannedString(res) != "b=10.0.0.224/27 c=10.0.0.128/27" {
+		t.Fatalf("Add(b, c) = %s, %v", plannedString(res), err)
+	}
+	want := "a=10.0.0.0/26 x=10.0.0.64/26 c=10.0.0.128/27 d=10.0.0.160/27 y=10.0.0.192/27 b=10.0.0.224/27"
+
+	// 第二个请求放不下，第一个也不生效
+	_, err = plan.Add(SubnetRequest{Name: "d", Bits: RequestBits(27)}, SubnetRequest{Name: "e", Bits: RequestBits(27)})
+	if !errors.Is(err, ErrPlanExhausted) {
+		t.Errorf("Add(d, e) = %v, want ErrPlanExhausted", err)
+	}
+	if _, ok := plan.Get("d"); ok {
+		t.Error("failed Add left d in the plan")
+	}
+	if _, err = plan.Add(SubnetRequest{Name: "x", Hosts: 1}); !errors.Is(err, ErrPlanDuplicateName) {
+		t.Errorf("Add(x) = %v, want ErrPlanDuplicateName", err)
+	}
+	if _, err = plan.Add(SubnetRequest{Name: "d", Bits: RequestBits(27)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := plannedString(plan.Subnets()); got != want {
+		t.Errorf("Subnets = %s, want %s", got, want)
+	}
+	if !plan.Remove("c") || plan.Remove("c") {
+		t.Error("Remove(c) twice")
+	}
+	if got := prefixesString(plan.Free().Prefixes()); got != "10.0.0.128/27" {
+		t.Errorf("Free after Remove = %s", got)
+	}
+}
+
+// TestPlanFragmented 剩余地址总数够但没有对齐的空闲块时返回 ErrPlanFragmented
+func TestPlanFragmented(t *testing.T) {
+	plan, err := NewSubnetPlan(MustParsePrefix("10.0.0.0/24"), []PlannedSubnet{
+		{Name: "x", Prefix: MustParsePrefix("10.0.0.32/27")},
+		{Name: "y", Prefix: MustParsePrefix("10.0.0.160/27")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = plan.Add(SubnetRequest{Name: "big", Bits: RequestBits(25)})
+	var pe *PlanError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrPlanFragmented) || pe.Free != "192" || pe.Largest.String() != "10.0.0.64/26" {
+		t.Fatalf("Add(big) = %v", err)
+	}
+	if !strings.Contains(err.Error(), "largest free block 10.0.0.64/26") {
+		t.Errorf("Error() = %s", err)
+	}
+
+	for _, existing := range [][]PlannedSubnet{
+		{{Name: "x", Prefix: MustParsePrefix("10.0.1.0/27")}},
+		{{Name: "x", Prefix: MustParsePrefix("10.0.0.0/25")}, {Name: "y", Prefix: MustParsePrefix("10.0.0.64/26")}},
+	} {
+		if _, err = NewSubnetPlan(MustParsePrefix("10.0.0.0/24"), existing); !errors.Is(err, ErrPlanOverlap) {
+			t.Errorf("NewSubnetPlan(%s) = %v, want ErrPlanOverlap", plannedString(existing), err)
+		}
+	}
+}
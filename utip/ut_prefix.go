@@ -0,0 +1,269 @@
+package utip
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxSubnetBits Subnets 一次最多切分的位数，即最多返回 2^20 个子网
+const maxSubnetBits = 20
+
+// Prefix ...
+// @Description: IP 网段，值类型，只需解析一次，支持 IPv4 和 IPv6
+// 与 netip.Prefix 一样保留未掩码的地址，需要网络地址时调用 Masked
+type Prefix struct {
+	ip   Addr
+	bits uint8
+}
+
+// PrefixFrom 地址和掩码长度构造网段，掩码长度越界时返回无效 Prefix，zone 会被丢弃
+func PrefixFrom(ip Addr, bits int) Prefix {
+	if !ip.IsValid() || bits < 0 || bits > ip.BitLen() {
+		return Prefix{}
+	}
+	return Prefix{ip: ip.WithZone(""), bits: uint8(bits)}
+}
+
+// PrefixFromIPNet net.IPNet to Prefix
+func PrefixFromIPNet(ipNet *net.IPNet) (Prefix, bool) {
+	if ipNet == nil {
+		return Prefix{}, false
+	}
+	ip, ok := AddrFromIP(ipNet.IP)
+	if !ok {
+		return Prefix{}, false
+	}
+	ones, size := ipNet.Mask.Size()
+	if size == 0 {
+		return Prefix{}, false
+	}
+	if size == 128 && ip.Is4() {
+		// 16 字节掩码的 IPv4 网段
+		if ones < 96 {
+			return Prefix{}, false
+		}
+		ones -= 96
+	}
+	p := PrefixFrom(ip, ones)
+	return p, p.IsValid()
+}
+
+// ParsePrefix ...
+// @Description: 解析 CIDR 字符串，如 172.21.0.1/16、2001:db8::/32，不接受 zone
+// @param s
+// @return Prefix
+// @return error
+func ParsePrefix(s string) (Prefix, error) {
+	i := strings.LastIndexByte(s, '/')
+	if i < 0 {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): no '/'", s)
+	}
+	ip, err := ParseAddr(s[:i])
+	if err != nil {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): %v", s, err)
+	}
+	if ip.Zone() != "" {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): IPv6 zones cannot be present in a prefix", s)
+	}
+
+	bitsStr := s[i+1:]
+	// 不接受 +8、08 这类写法
+	if bitsStr == "" || bitsStr[0] == '+' || bitsStr[0] == '-' || len(bitsStr) > 1 && bitsStr[0] == '0' {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): bad bits after slash: %q", s, bitsStr)
+	}
+	bits, err := strconv.Atoi(bitsStr)
+	if err != nil {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): bad bits after slash: %q", s, bitsStr)
+	}
+	if bits > ip.BitLen() {
+		return Prefix{}, fmt.Errorf("ParsePrefix(%q): prefix length out of range", s)
+	}
+	return PrefixFrom(ip, bits), nil
+}
+
+// MustParsePrefix 解析失败时 panic，用于常量初始化
+func MustParsePrefix(s string) Prefix {
+	p, err := ParsePrefix(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Addr 网段中的地址，不一定是网络地址
+func (p Prefix) Addr() Addr { return p.ip }
+
+// Bits 掩码长度，无效 Prefix 返回 -1
+func (p Prefix) Bits() int {
+	if !p.IsValid() {
+		return -1
+	}
+	return int(p.bits)
+}
+
+// IsValid ...
+func (p Prefix) IsValid() bool { return p.ip.IsValid() }
+
+// IsSingleIP /32 或 /128
+func (p Prefix) IsSingleIP() bool { return p.IsValid() && int(p.bits) == p.ip.BitLen() }
+
+// offset IPv4 地址在 uint128 中的起始位
+func (p Prefix) offset() uint8 {
+	if p.ip.Is4() {
+		return 96
+	}
+	return 0
+}
+
+// Masked 主机位清零后的网段
+func (p Prefix) Masked() Prefix {
+	if !p.IsValid() {
+		return Prefix{}
+	}
+	p.ip.addr = p.ip.addr.bitsClearedFrom(p.offset() + p.bits)
+	return p
+}
+
+// First 网段第一个地址（网络地址），GetFirstIP 对应 First().Next()
+func (p Prefix) First() Addr {
+	return p.Masked().ip
+}
+
+// Last 网段最后一个地址
+func (p Prefix) Last() Addr {
+	if !p.IsValid() {
+		return Addr{}
+	}
+	ip := p.ip
+	ip.addr = ip.addr.bitsSetFrom(p.offset() + p.bits)
+	return ip
+}
+
+// Broadcast IPv4 广播地址，IPv6 没有广播地址，返回无效地址
+func (p Prefix) Broadcast() Addr {
+	if !p.ip.Is4() {
+		return Addr{}
+	}
+	return p.Last()
+}
+
+// NumAddrs 网段中地址个数，IPv6 可能超过 uint64
+func (p Prefix) NumAddrs() *big.Int {
+	if !p.IsValid() {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.ip.BitLen()-int(p.bits)))
+}
+
+// Contains 地址是否在网段中，地址族必须一致，带 zone 的地址返回 false
+func (p Prefix) Contains(ip Addr) bool {
+	if !p.IsValid() || ip.bitLen != p.ip.bitLen || ip.zone != "" {
+		return false
+	}
+	return ip.addr.xor(p.ip.addr).and(mask6(int(p.offset() + p.bits))).isZero()
+}
+
+// ContainsPrefix o 是否是 p 的子网（含相等）
+func (p Prefix) ContainsPrefix(o Prefix) bool {
+	return o.IsValid() && o.bits >= p.bits && p.Contains(o.ip)
+}
+
+// Overlaps 两个网段是否相交
+func (p Prefix) Overlaps(o Prefix) bool {
+	if !p.IsValid() || !o.IsValid() || p.ip.bitLen != o.ip.bitLen {
+		return false
+	}
+	minBits := p.bits
+	if o.bits < minBits {
+		minBits = o.bits
+	}
+	return p.ip.addr.xor(o.ip.addr).and(mask6(int(p.offset() + minBits))).isZero()
+}
+
+// Next 紧随其后的同样大小的网段，溢出时返回无效 Prefix
+func (p Prefix) Next() Prefix {
+	if !p.IsValid() || p.bits == 0 {
+		return Prefix{}
+	}
+	m := p.Masked()
+	step := uint128{0, 1}.lsh(uint(p.ip.BitLen() - int(p.bits)))
+	next := m.ip.addr.add(step)
+	if p.ip.Is4() {
+		if next.lo>>32 != 0xffff {
+			return Prefix{}
+		}
+	} else if next.cmp(m.ip.addr) <= 0 {
+		return Prefix{}
+	}
+	m.ip.addr = next
+	return m
+}
+
+// Subnets ...
+// @Description: 按新的掩码长度切分网段，如 10.0.0.0/16 切成 /24 得到 256 个子网
+// @receiver p
+// @param newBits
+// @return []Prefix
+// @return error
+func (p Prefix) Subnets(newBits int) ([]Prefix, error) {
+	if !p.IsValid() {
+		return nil, errors.New("Subnets: invalid prefix")
+	}
+	if newBits < int(p.bits) || newBits > p.ip.BitLen() {
+		return nil, fmt.Errorf("Subnets: new prefix length %d out of range [%d, %d]", newBits, p.bits, p.ip.BitLen())
+	}
+	if newBits-int(p.bits) > maxSubnetBits {
+		return nil, fmt.Errorf("Subnets: %s into /%d exceeds 2^%d subnets", p, newBits, maxSubnetBits)
+	}
+
+	n := 1 << (newBits - int(p.bits))
+	res := make([]Prefix, 0, n)
+	cur := PrefixFrom(p.First(), newBits)
+	for i := 0; i < n; i++ {
+		res = append(res, cur)
+		cur = cur.Next()
+	}
+	return res, nil
+}
+
+// Compare 先比较地址族，再比较网络地址，最后比较掩码长度，返回 -1、0、1
+func (p Prefix) Compare(o Prefix) int {
+	if c := p.Masked().ip.Compare(o.Masked().ip); c != 0 {
+		return c
+	}
+	switch {
+	case p.bits < o.bits:
+		return -1
+	case p.bits > o.bits:
+		return 1
+	}
+	return p.ip.Compare(o.ip)
+}
+
+// IPNet Prefix to net.IPNet
+func (p Prefix) IPNet() *net.IPNet {
+	if !p.IsValid() {
+		return nil
+	}
+	return &net.IPNet{
+		IP:   p.First().IP(),
+		Mask: net.CIDRMask(int(p.bits), p.ip.BitLen()),
+	}
+}
+
+// String 如 172.21.0.1/16
+func (p Prefix) String() string {
+	if !p.IsValid() {
+		return "invalid Prefix"
+	}
+	const max = len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128")
+	b := make([]byte, 0, max)
+	b = p.ip.AppendTo(b)
+	b = append(b, '/')
+	b = strconv.AppendInt(b, int64(p.bits), 10)
+	return string(b)
+}
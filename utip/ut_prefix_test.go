@@ -0,0 +1,251 @@
+package utip
+
+import (
+	"math/big"
+	"net"
+	"testing"
+)
+
+// TestPrefixAddrs 网络地址、首尾地址、广播地址和地址个数
+func TestPrefixAddrs(t *testing.T) {
+	tests := []struct {
+		in, masked, first, last, broadcast string
+		num                                string
+	}{
+		{"172.21.3.4/16", "172.21.0.0/16", "172.21.0.0", "172.21.255.255", "172.21.255.255", "65536"},
+		{"10.0.0.5/30", "10.0.0.4/30", "10.0.0.4", "10.0.0.7", "10.0.0.7", "4"},
+		{"10.0.0.5/32", "10.0.0.5/32", "10.0.0.5", "10.0.0.5", "10.0.0.5", "1"},
+		{"1.2.3.4/0", "0.0.0.0/0", "0.0.0.0", "255.255.255.255", "255.255.255.255", "4294967296"},
+		{"2001:db8::1/64", "2001:db8::/64", "2001:db8::", "2001:db8::ffff:ffff:ffff:ffff", "invalid IP", "18446744073709551616"},
+		{"2001:db8::1/128", "2001:db8::1/128", "2001:db8::1", "2001:db8::1", "invalid IP", "1"},
+		{"::1/0", "::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "invalid IP", "340282366920938463463374607431768211456"},
+		{"::ffff:10.0.0.1/120", "::ffff:10.0.0.0/120", "::ffff:10.0.0.0", "::ffff:10.0.0.255", "invalid IP", "256"},
+	}
+	for _, tt := range tests {
+		p := MustParsePrefix(tt.in)
+		if got := p.Masked().String(); got != tt.masked {
+			t.Errorf("%s: Masked = %s, want %s", tt.in, got, tt.masked)
+		}
+		if p.String() != tt.in {
+			t.Errorf("%s: String = %s, host bits must be kept", tt.in, p)
+		}
+		if got := p.First().String(); got != tt.first {
+			t.Errorf("%s: First = %s, want %s", tt.in, got, tt.first)
+		}
+		if got := p.Last().String(); got != tt.last {
+			t.Errorf("%s: Last = %s, want %s", tt.in, got, tt.last)
+		}
+		if got := p.Broadcast().String(); got != tt.broadcast {
+			t.Errorf("%s: Broadcast = %s, want %s", tt.in, got, tt.broadcast)
+		}
+		want, _ := new(big.Int).SetString(tt.num, 10)
+		if got := p.NumAddrs(); got.Cmp(want) != 0 {
+			t.Errorf("%s: NumAddrs = %s, want %s", tt.in, got, tt.num)
+		}
+	}
+	if (Prefix{}).NumAddrs().Sign() != 0 || (Prefix{}).Bits() != -1 || (Prefix{}).Masked().IsValid() {
+		t.Error("zero Prefix is not empty")
+	}
+}
+
+// TestPrefixContains 地址族必须一致，IPv4 与 IPv4 映射的 IPv6 地址不互相包含
+func TestPrefixContains(t *testing.T) {
+	tests := []struct {
+		prefix, ip string
+		want       bool
+	}{
+		{"10.0.0.0/8", "10.255.255.255", true},
+		{"10.0.0.0/8", "11.0.0.0", false},
+		{"10.1.2.3/8", "10.0.0.1", true}, // 未掩码的网段
+		{"0.0.0.0/0", "255.255.255.255", true},
+		{"0.0.0.0/0", "::", false},
+		{"10.0.0.0/8", "::ffff:10.0.0.1", false},
+		{"::ffff:10.0.0.0/104", "10.0.0.1", false},
+		{"::ffff:10.0.0.0/104", "::ffff:10.0.0.1", true},
+		{"2001:db8::/32", "2001:db8:ffff::1", true},
+		{"2001:db8::/32", "2001:db9::", false},
+		{"fe80::/10", "fe80::1%eth0", false}, // 带 zone 的地址
+		{"::/0", "2001:db8::1", true},
+	}
+	for _, tt := range tests {
+		if got := MustParsePrefix(tt.prefix).Contains(MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("%s.Contains(%s) = %v, want %v", tt.prefix, tt.ip, got, tt.want)
+		}
+	}
+}
+
+// TestPrefixOverlaps 相交与包含关系，参数顺序无关
+func TestPrefixOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b               string
+		overlaps, contains bool // contains 为 a 包含 b
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", true, true},
+		{"10.1.0.0/16", "10.0.0.0/8", true, false},
+		{"10.0.0.0/24", "10.0.1.0/24", false, false},
+		{"10.0.0.0/23", "10.0.1.0/24", true, true},
+		{"10.0.0.0/24", "10.0.0.0/24", true, true},
+		{"10.1.0.0/8", "10.2.0.0/16", true, true}, // 未掩码的网段
+		{"0.0.0.0/0", "::/0", false, false},
+		{"10.0.0.0/8", "::ffff:10.0.0.0/104", false, false},
+		{"2001:db8::/32", "2001:db8:1::/48", true, true},
+		{"2001:db8::/48", "2001:db8:1::/48", false, false},
+		{"::/0", "2001:db8::1/128", true, true},
+	}
+	for _, tt := range tests {
+		a, b := MustParsePrefix(tt.a), MustParsePrefix(tt.b)
+		if a.Overlaps(b) != tt.overlaps || b.Overlaps(a) != tt.overlaps {
+			t.Errorf("%s.Overlaps(%s) = %v, want %v", tt.a, tt.b, a.Overlaps(b), tt.overlaps)
+		}
+		if got := a.ContainsPrefix(b); got != tt.contains {
+			t.Errorf("%s.ContainsPrefix(%s) = %v, want %v", tt.a, tt.b, got, tt.contains)
+		}
+		// net.IPNet 无法区分 IPv4 和 IPv4 映射的 IPv6 网段
+		if a.Addr().Is4In6() || b.Addr().Is4In6() {
+			continue
+		}
+		n1, n2 := a.IPNet(), b.IPNet()
+		if got := Intersect(n1, n2); got != tt.overlaps {
+			t.Errorf("Intersect(%s, %s) = %v, want %v", n1, n2, got, tt.overlaps)
+		}
+	}
+	if Intersect(nil, MustParsePrefix("10.0.0.0/8").IPNet()) {
+		t.Error("Intersect(nil, ...) = true")
+	}
+}
+
+// TestPrefixNext 下一个同样大小的网段，地址空间末尾溢出时无效
+func TestPrefixNext(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"10.0.0.5/24", "10.0.1.0/24"},
+		{"10.0.255.0/24", "10.1.0.0/24"},
+		{"255.255.255.0/24", "invalid Prefix"},
+		{"255.255.255.255/32", "invalid Prefix"},
+		{"0.0.0.0/0", "invalid Prefix"},
+		{"2001:db8::/64", "2001:db8:0:1::/64"},
+		{"2001:db8:ffff:ffff::/64", "2001:db9::/64"},
+		{"ffff:ffff:ffff:ffff::/64", "invalid Prefix"},
+		{"::ffff:255.255.255.0/120", "::1:0:0:0/120"},
+	}
+	for _, tt := range tests {
+		if got := MustParsePrefix(tt.in).Next().String(); got != tt.want {
+			t.Errorf("%s.Next() = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestPrefixSubnets 切分得到连续、不重叠的子网，超出范围或数量时返回错误
+func TestPrefixSubnets(t *testing.T) {
+	tests := []struct {
+		in      string
+		newBits int
+		want    []string
+	}{
+		{"10.0.0.7/24", 26, []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}},
+		{"10.0.0.0/24", 24, []string{"10.0.0.0/24"}},
+		{"255.255.255.252/30", 32, []string{"255.255.255.252/32", "255.255.255.253/32", "255.255.255.254/32", "255.255.255.255/32"}},
+		{"2001:db8::/47", 48, []string{"2001:db8::/48", "2001:db8:1::/48"}},
+		{"2001:db8::/126", 127, []string{"2001:db8::/127", "2001:db8::2/127"}},
+	}
+	for _, tt := range tests {
+		got, err := MustParsePrefix(tt.in).Subnets(tt.newBits)
+		if err != nil {
+			t.Errorf("%s.Subnets(%d): %v", tt.in, tt.newBits, err)
+			continue
+		}
+		if prefixesString(got) != prefixesString(mustPrefixes(tt.want)) {
+			t.Errorf("%s.Subnets(%d) = %s, want %v", tt.in, tt.newBits, prefixesString(got), tt.want)
+		}
+	}
+
+	subnets, err := MustParsePrefix("10.0.0.0/8").Subnets(24)
+	if err != nil || len(subnets) != 1<<16 || subnets[len(subnets)-1].String() != "10.255.255.0/24" {
+		t.Errorf("10.0.0.0/8 into /24: %d subnets, %v", len(subnets), err)
+	}
+	for _, bad := range []struct {
+		in      string
+		newBits int
+	}{
+		{"10.0.0.0/24", 23},
+		{"10.0.0.0/24", 33},
+		{"2001:db8::/32", 129},
+		{"2001:db8::/32", 64}, // 2^32 个子网
+	} {
+		if _, err := MustParsePrefix(bad.in).Subnets(bad.newBits); err == nil {
+			t.Errorf("%s.Subnets(%d): want error", bad.in, bad.newBits)
+		}
+	}
+}
+
+// mustPrefixes 解析网段列表
+func mustPrefixes(ss []string) []Prefix {
+	ps := make([]Prefix, len(ss))
+	for i, s := range ss {
+		ps[i] = MustParsePrefix(s)
+	}
+	return ps
+}
+
+// TestPrefixIPNet 与 net.IPNet 互转，16 字节掩码的 IPv4 网段按 IPv4 处理
+func TestPrefixIPNet(t *testing.T) {
+	for _, s := range []string{"10.1.2.0/24", "0.0.0.0/0", "2001:db8::/32", "::/0"} {
+		_, ipNet, _ := net.ParseCIDR(s)
+		p, ok := PrefixFromIPNet(ipNet)
+		if !ok || p.String() != s || p.IPNet().String() != ipNet.String() {
+			t.Errorf("PrefixFromIPNet(%s) = %s, %v; IPNet = %s", ipNet, p, ok, p.IPNet())
+		}
+	}
+	ipNet := &net.IPNet{IP: net.ParseIP("10.1.2.0"), Mask: net.CIDRMask(120, 128)}
+	if p, ok := PrefixFromIPNet(ipNet); !ok || p.String() != "10.1.2.0/24" {
+		t.Errorf("16-byte IPv4 IPNet = %s, %v, want 10.1.2.0/24", p, ok)
+	}
+	for _, bad := range []*net.IPNet{nil, {IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(64, 128)}, {IP: net.ParseIP("10.0.0.0")}} {
+		if p, ok := PrefixFromIPNet(bad); ok {
+			t.Errorf("PrefixFromIPNet(%v) = %s, want invalid", bad, p)
+		}
+	}
+}
+
+// TestLegacySubnetHelpers 基于 Prefix 的旧接口
+func TestLegacySubnetHelpers(t *testing.T) {
+	tests := []struct {
+		cidr, broadcast, first string
+	}{
+		{"172.21.3.4/16", "172.21.255.255", "172.21.0.1"},
+		{"10.0.0.5/30", "10.0.0.7", "10.0.0.5"},
+		{"10.0.0.5/31", "10.0.0.5", "10.0.0.5"},
+		{"10.0.0.5/32", "10.0.0.5", "<nil>"},
+		{"2001:db8::/64", "<nil>", "<nil>"},
+		{"bad", "<nil>", "<nil>"},
+	}
+	for _, tt := range tests {
+		if got := GetBroadcastIP(tt.cidr); got.String() != tt.broadcast {
+			t.Errorf("GetBroadcastIP(%s) = %s, want %s", tt.cidr, got, tt.broadcast)
+		}
+		if got := GetFirstIP(tt.cidr); got.String() != tt.first {
+			t.Errorf("GetFirstIP(%s) = %s, want %s", tt.cidr, got, tt.first)
+		}
+		if got := GetBroadcastIP(tt.cidr); got != nil && len(got) != net.IPv4len {
+			t.Errorf("GetBroadcastIP(%s) has %d bytes, want 4", tt.cidr, len(got))
+		}
+	}
+
+	sameTests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.1/24", "10.0.0.200/24", true},
+		{"10.0.0.1/24", "10.0.0.1/25", false},
+		{"10.0.0.1/24", "10.0.1.1/24", false},
+		{"10.0.0.0/8", "::ffff:10.0.0.0/104", false},
+		{"2001:db8::1/64", "2001:db8::ffff/64", true},
+		{"2001:db8::1/64", "bad", false},
+	}
+	for _, tt := range sameTests {
+		if got := IsSameSubnet(tt.a, tt.b); got != tt.want {
+			t.Errorf("IsSameSubnet(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
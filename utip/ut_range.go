@@ -0,0 +1,287 @@
+package utip
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Range ...
+// @Description: 连续的 IP 地址区间 [from, to]，两端地址族一致
+type Range struct {
+	from Addr
+	to   Addr
+}
+
+// RangeFrom 构造区间，地址族不一致、带 zone 或 from > to 时返回无效 Range
+func RangeFrom(from, to Addr) Range {
+	if !from.IsValid() || from.bitLen != to.bitLen || from.zone != "" || to.zone != "" || to.Less(from) {
+		return Range{}
+	}
+	return Range{from: from, to: to}
+}
+
+// RangeFromPrefix 网段转区间
+func RangeFromPrefix(p Prefix) Range {
+	if !p.IsValid() {
+		return Range{}
+	}
+	return Range{from: p.First(), to: p.Last()}
+}
+
+// From 起始地址
+func (r Range) From() Addr { return r.from }
+
+// To 结束地址（包含）
+func (r Range) To() Addr { return r.to }
+
+// IsValid ...
+func (r Range) IsValid() bool { return r.from.IsValid() }
+
+// Contains 地址是否在区间内
+func (r Range) Contains(ip Addr) bool {
+	return r.IsValid() && ip.bitLen == r.from.bitLen && ip.zone == "" &&
+		r.from.Compare(ip) <= 0 && ip.Compare(r.to) <= 0
+}
+
+// Overlaps 两个区间是否相交
+func (r Range) Overlaps(o Range) bool {
+	return r.IsValid() && o.IsValid() && r.from.bitLen == o.from.bitLen &&
+		r.from.Compare(o.to) <= 0 && o.from.Compare(r.to) <= 0
+}
+
+// Prefix 区间恰好是一个网段时返回该网段
+func (r Range) Prefix() (Prefix, bool) {
+	ps := r.AppendPrefixes(nil)
+	if len(ps) != 1 {
+		return Prefix{}, false
+	}
+	return ps[0], true
+}
+
+// Prefixes 覆盖区间的最少网段列表
+func (r Range) Prefixes() []Prefix {
+	return r.AppendPrefixes(nil)
+}
+
+// AppendPrefixes ...
+// @Description: 区间拆成最少的网段，每次取起始地址对齐且不超出区间的最大网段
+// @receiver r
+// @param dst
+// @return []Prefix
+func (r Range) AppendPrefixes(dst []Prefix) []Prefix {
+	if !r.IsValid() {
+		return dst
+	}
+	bitLen := r.from.BitLen()
+	from := r.from
+	for {
+		// 起始地址的对齐位数
+		k := from.addr.trailingZeros()
+		if k > bitLen {
+			k = bitLen
+		}
+		// 剩余地址个数 diff+1 不小于 2^k
+		diff := r.to.addr.sub(from.addr)
+		if n := diff.addOne(); !n.isZero() {
+			if maxK := 127 - n.leadingZeros(); maxK < k {
+				k = maxK
+			}
+		}
+		p := PrefixFrom(from, bitLen-k)
+		dst = append(dst, p)
+
+		last := p.Last()
+		if last.Compare(r.to) >= 0 {
+			return dst
+		}
+		from = last.Next()
+	}
+}
+
+// String 如 10.0.0.1-10.0.0.50
+func (r Range) String() string {
+	if !r.IsValid() {
+		return "invalid Range"
+	}
+	b := make([]byte, 0, 2*len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")+1)
+	b = r.from.AppendTo(b)
+	b = append(b, '-')
+	b = r.to.AppendTo(b)
+	return string(b)
+}
+
+// RangeParseError ...
+// @Description: ParseRange 的错误，Expected 说明该格式期望的写法
+type RangeParseError struct {
+	Input    string
+	Expected string
+	Err      error
+}
+
+// Error ...
+func (e *RangeParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("ParseRange(%q): expected %s: %v", e.Input, e.Expected, e.Err)
+	}
+	return fmt.Sprintf("ParseRange(%q): expected %s", e.Input, e.Expected)
+}
+
+// Unwrap ...
+func (e *RangeParseError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	expectAny      = "IP, CIDR, IP range, wildcard or IP with netmask"
+	expectRange    = "IP range like 10.0.0.1-10.0.0.50 or 10.0.0.1-50"
+	expectShort    = "last octet range like 10.0.0.1-50 with end in [start, 255]"
+	expectWildcard = "IPv4 wildcard like 10.0.0.* with * only in trailing octets"
+	expectMask     = "IPv4 with contiguous netmask like 10.0.0.0 255.255.255.0"
+	expectCIDR     = "CIDR like 10.0.0.0/24 or 2001:db8::/32"
+	expectIP       = "IP address without zone"
+)
+
+// MustParseRange 解析失败时 panic，用于常量初始化
+func MustParseRange(s string) Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ParseRange ...
+// @Description: 统一解析客户资源文件中的地址写法，返回 Range，错误为 *RangeParseError
+// 支持：10.0.0.1、10.0.0.0/24、2001:db8::/32、10.0.0.1-10.0.0.50、10.0.0.1-50、
+// 10.0.0.*、10.0.*.*、10.0.0.0 255.255.255.0、10.0.0.0/255.255.255.0
+// @param s
+// @return Range
+// @return error
+func ParseRange(s string) (Range, error) {
+	in := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Range{}, &RangeParseError{Input: in, Expected: expectAny, Err: errors.New("empty string")}
+	}
+
+	// 区间两侧允许有空格
+	if fromStr, toStr, ok := strings.Cut(s, "-"); ok {
+		return parseDashRange(in, strings.TrimSpace(fromStr), strings.TrimSpace(toStr))
+	}
+
+	if fields := strings.Fields(s); len(fields) == 2 {
+		return parseMaskRange(in, fields[0], fields[1])
+	} else if len(fields) > 2 {
+		return Range{}, &RangeParseError{Input: in, Expected: expectAny, Err: errors.New("too many fields")}
+	}
+
+	if ip, mask, ok := strings.Cut(s, "/"); ok {
+		if strings.Contains(mask, ".") {
+			return parseMaskRange(in, ip, mask)
+		}
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return Range{}, &RangeParseError{Input: in, Expected: expectCIDR, Err: err}
+		}
+		return RangeFromPrefix(p), nil
+	}
+
+	if strings.Contains(s, "*") {
+		return parseWildcardRange(in, s)
+	}
+
+	ip, err := ParseAddr(s)
+	if err != nil {
+		return Range{}, &RangeParseError{Input: in, Expected: expectAny, Err: err}
+	}
+	if ip.Zone() != "" {
+		return Range{}, &RangeParseError{Input: in, Expected: expectIP, Err: errors.New("zone not allowed")}
+	}
+	return Range{from: ip, to: ip}, nil
+}
+
+// parseDashRange 10.0.0.1-10.0.0.50 或 10.0.0.1-50
+func parseDashRange(in, fromStr, toStr string) (Range, error) {
+	from, err := ParseAddr(fromStr)
+	if err != nil {
+		return Range{}, &RangeParseError{Input: in, Expected: expectRange, Err: err}
+	}
+
+	var to Addr
+	if from.Is4() && toStr != "" && !strings.ContainsAny(toStr, ".:") {
+		// 只写了最后一段
+		last, err := strconv.ParseUint(toStr, 10, 8)
+		if err != nil {
+			return Range{}, &RangeParseError{Input: in, Expected: expectShort, Err: err}
+		}
+		a4 := from.As4()
+		if uint8(last) < a4[3] {
+			return Range{}, &RangeParseError{Input: in, Expected: expectShort, Err: fmt.Errorf("end %d is less than start %d", last, a4[3])}
+		}
+		a4[3] = uint8(last)
+		to = AddrFrom4(a4)
+	} else {
+		to, err = ParseAddr(toStr)
+		if err != nil {
+			return Range{}, &RangeParseError{Input: in, Expected: expectRange, Err: err}
+		}
+	}
+
+	if from.Zone() != "" || to.Zone() != "" {
+		return Range{}, &RangeParseError{Input: in, Expected: expectRange, Err: errors.New("zone not allowed")}
+	}
+	if from.BitLen() != to.BitLen() {
+		return Range{}, &RangeParseError{Input: in, Expected: expectRange, Err: errors.New("start and end are different address families")}
+	}
+	if to.Less(from) {
+		return Range{}, &RangeParseError{Input: in, Expected: expectRange, Err: fmt.Errorf("end %s is less than start %s", to, from)}
+	}
+	return Range{from: from, to: to}, nil
+}
+
+// parseWildcardRange 10.0.0.* 或 10.0.*.*
+func parseWildcardRange(in, s string) (Range, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return Range{}, &RangeParseError{Input: in, Expected: expectWildcard, Err: fmt.Errorf("got %d octets", len(parts))}
+	}
+	var from, to [4]byte
+	wild := false
+	for i, part := range parts {
+		if part == "*" {
+			wild = true
+			from[i], to[i] = 0, 255
+			continue
+		}
+		if wild {
+			return Range{}, &RangeParseError{Input: in, Expected: expectWildcard, Err: fmt.Errorf("octet %d follows a wildcard", i+1)}
+		}
+		v, err := strconv.ParseUint(part, 10, 8)
+		if err != nil || len(part) > 1 && part[0] == '0' {
+			return Range{}, &RangeParseError{Input: in, Expected: expectWildcard, Err: fmt.Errorf("invalid octet %q", part)}
+		}
+		from[i], to[i] = uint8(v), uint8(v)
+	}
+	return Range{from: AddrFrom4(from), to: AddrFrom4(to)}, nil
+}
+
+// parseMaskRange 10.0.0.0 255.255.255.0
+func parseMaskRange(in, ipStr, maskStr string) (Range, error) {
+	ip, err := ParseIPv4(ipStr)
+	if err != nil {
+		return Range{}, &RangeParseError{Input: in, Expected: expectMask, Err: fmt.Errorf("address %q: %v", ipStr, err)}
+	}
+	mask, err := ParseIPv4(maskStr)
+	if err != nil {
+		return Range{}, &RangeParseError{Input: in, Expected: expectMask, Err: fmt.Errorf("netmask %q: %v", maskStr, err)}
+	}
+	m := uint32(mask.addr.lo)
+	// 连续的 1 之后全是 0
+	if (^m)&(^m+1) != 0 {
+		return Range{}, &RangeParseError{Input: in, Expected: expectMask, Err: fmt.Errorf("netmask %s is not contiguous", mask)}
+	}
+	return RangeFromPrefix(PrefixFrom(ip, bits.OnesCount32(m))), nil
+}
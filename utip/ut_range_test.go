@@ -0,0 +1,150 @@
+package utip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestParseRange 客户资源文件中的各种写法
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"10.0.0.1", "10.0.0.1-10.0.0.1"},
+		{" 10.0.0.1 - 10.0.0.50 ", "10.0.0.1-10.0.0.50"},
+		{"10.0.0.1-10.0.1.3", "10.0.0.1-10.0.1.3"},
+		{"10.0.0.1-50", "10.0.0.1-10.0.0.50"},
+		{"10.0.0.5-5", "10.0.0.5-10.0.0.5"},
+		{"10.0.0.0-255", "10.0.0.0-10.0.0.255"},
+		{"10.0.0.*", "10.0.0.0-10.0.0.255"},
+		{"10.0.*.*", "10.0.0.0-10.0.255.255"},
+		{"*.*.*.*", "0.0.0.0-255.255.255.255"},
+		{"10.0.0.0 255.255.255.0", "10.0.0.0-10.0.0.255"},
+		{"10.0.0.9  255.255.255.248", "10.0.0.8-10.0.0.15"},
+		{"10.0.0.9/255.255.255.248", "10.0.0.8-10.0.0.15"},
+		{"10.0.0.0 0.0.0.0", "0.0.0.0-255.255.255.255"},
+		{"10.0.0.0/24", "10.0.0.0-10.0.0.255"},
+		{"10.0.0.9/29", "10.0.0.8-10.0.0.15"},
+		{"10.0.0.9/32", "10.0.0.9-10.0.0.9"},
+		{"2001:db8::/126", "2001:db8::-2001:db8::3"},
+		{"2001:db8::1-2001:db8::ff", "2001:db8::1-2001:db8::ff"},
+		{"2001:db8::1", "2001:db8::1-2001:db8::1"},
+		{"::ffff:10.0.0.1", "::ffff:10.0.0.1-::ffff:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.in)
+		if err != nil {
+			t.Errorf("ParseRange(%q): %v", tt.in, err)
+			continue
+		}
+		if r.String() != tt.want {
+			t.Errorf("ParseRange(%q) = %s, want %s", tt.in, r, tt.want)
+		}
+	}
+}
+
+// TestParseRangeError 错误为 *RangeParseError，Expected 说明该写法期望的格式
+func TestParseRangeError(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"", expectAny},
+		{"   ", expectAny},
+		{"hello", expectAny},
+		{"10.0.0.1 10.0.0.2 10.0.0.3", expectAny},
+		{"10.0.0.1 - 10.0.0.x", expectRange},
+		{"x-10.0.0.1", expectRange},
+		{"10.0.0.50-10.0.0.1", expectRange},
+		{"10.0.0.1-2001:db8::1", expectRange},
+		{"2001:db8::1-ff", expectRange},
+		{"fe80::1%eth0-fe80::2", expectRange},
+		{"10.0.0.1-", expectRange},
+		{"10.0.0.50-10", expectShort},
+		{"10.0.0.1-256", expectShort},
+		{"10.0.0.1-+5", expectShort},
+		{"10.*.0.*", expectWildcard},
+		{"10.0.*", expectWildcard},
+		{"10.0.01.*", expectWildcard},
+		{"10.0.256.*", expectWildcard},
+		{"10.0.0.0 255.0.255.0", expectMask},
+		{"10.0.0.0/255.255.0.255", expectMask},
+		{"10.0.0.0 24", expectMask},
+		{"2001:db8:: ffff::", expectMask},
+		{"10.0.0.0/33", expectCIDR},
+		{"10.0.0.0/", expectCIDR},
+		{"10.0.0.0/08", expectCIDR},
+		{"fe80::/10%eth0", expectCIDR},
+		{"fe80::1%eth0", expectIP},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.in)
+		var pe *RangeParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("ParseRange(%q) = %s, %v, want *RangeParseError", tt.in, r, err)
+			continue
+		}
+		if pe.Expected != tt.expected || pe.Input != tt.in {
+			t.Errorf("ParseRange(%q): Input %q, Expected %q, want %q", tt.in, pe.Input, pe.Expected, tt.expected)
+		}
+		if !strings.Contains(err.Error(), "expected "+tt.expected) || r.IsValid() {
+			t.Errorf("ParseRange(%q): error %q, range %s", tt.in, err, r)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParseRange(invalid) did not panic")
+		}
+	}()
+	MustParseRange("10.0.0.x")
+}
+
+// TestRangeAppendPrefixes 区间拆成最少的对齐网段
+func TestRangeAppendPrefixes(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"10.0.0.1-10.0.0.50", "10.0.0.1/32 10.0.0.2/31 10.0.0.4/30 10.0.0.8/29 10.0.0.16/28 10.0.0.32/28 10.0.0.48/31 10.0.0.50/32"},
+		{"10.0.0.0-10.0.0.255", "10.0.0.0/24"},
+		{"10.0.0.255-10.0.1.0", "10.0.0.255/32 10.0.1.0/32"},
+		{"10.0.0.7", "10.0.0.7/32"},
+		{"0.0.0.0-255.255.255.255", "0.0.0.0/0"},
+		{"0.0.0.1-255.255.255.255", "0.0.0.1/32 0.0.0.2/31 0.0.0.4/30 0.0.0.8/29 0.0.0.16/28 0.0.0.32/27 0.0.0.64/26 " +
+			"0.0.0.128/25 0.0.1.0/24 0.0.2.0/23 0.0.4.0/22 0.0.8.0/21 0.0.16.0/20 0.0.32.0/19 0.0.64.0/18 0.0.128.0/17 " +
+			"0.1.0.0/16 0.2.0.0/15 0.4.0.0/14 0.8.0.0/13 0.16.0.0/12 0.32.0.0/11 0.64.0.0/10 0.128.0.0/9 " +
+			"1.0.0.0/8 2.0.0.0/7 4.0.0.0/6 8.0.0.0/5 16.0.0.0/4 32.0.0.0/3 64.0.0.0/2 128.0.0.0/1"},
+		{"255.255.255.254-255.255.255.255", "255.255.255.254/31"},
+		{"::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "::/0"},
+		{"2001:db8::1-2001:db8::ffff", "2001:db8::1/128 2001:db8::2/127 2001:db8::4/126 2001:db8::8/125 " +
+			"2001:db8::10/124 2001:db8::20/123 2001:db8::40/122 2001:db8::80/121 2001:db8::100/120 2001:db8::200/119 " +
+			"2001:db8::400/118 2001:db8::800/117 2001:db8::1000/116 2001:db8::2000/115 2001:db8::4000/114 2001:db8::8000/113"},
+		{"2001:db8::-2001:db9:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::/31"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127"},
+	}
+	for _, tt := range tests {
+		r := MustParseRange(tt.in)
+		ps := r.AppendPrefixes(nil)
+		if got := prefixesString(ps); got != tt.want {
+			t.Errorf("%s: AppendPrefixes = %s, want %s", tt.in, got, tt.want)
+		}
+		for _, p := range ps {
+			if p != p.Masked() {
+				t.Errorf("%s: prefix %s is not masked", tt.in, p)
+			}
+		}
+		if p, ok := r.Prefix(); ok != (len(ps) == 1) || ok && p != ps[0] {
+			t.Errorf("%s: Prefix = %s, %v", tt.in, p, ok)
+		}
+	}
+
+	dst := []Prefix{MustParsePrefix("192.168.0.0/16")}
+	dst = MustParseRange("10.0.0.0-10.0.0.1").AppendPrefixes(dst)
+	if got := prefixesString(dst); got != "192.168.0.0/16 10.0.0.0/31" {
+		t.Errorf("AppendPrefixes keeps dst: %s", got)
+	}
+	if got := (Range{}).AppendPrefixes(dst); len(got) != 2 {
+		t.Errorf("invalid Range appended %d prefixes", len(got)-2)
+	}
+}
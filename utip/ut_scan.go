@@ -0,0 +1,320 @@
+package utip
+
+import (
+	"io"
+)
+
+const (
+	// scanBufSize IPScanner 默认缓冲区大小
+	scanBufSize = 64 * 1024
+	// scanMaxRun 超过这个长度的候选片段不可能是地址，直接跳过
+	scanMaxRun = 64
+)
+
+// IPScanner ...
+// @Description: 从字节流中逐个提取 IPv4 和 IPv6 地址，用于分析大日志文件，
+// 缓冲区固定，提取过程不分配内存；地址是 [0-9a-fA-F.:] 组成的片段，
+// 如 1.2.3.4:8080、[2001:db8::1]:443、client=10.0.0.1, 都能提取出地址，zone 会被忽略
+// 用法与 bufio.Scanner 一致：for s.Scan() { s.Addr() }，非并发安全
+type IPScanner struct {
+	rd  io.Reader
+	buf []byte
+	r   int // 未处理数据起点
+	w   int // 数据终点
+	eof bool
+	err error
+
+	skipRun bool   // 正在跳过过长的片段
+	run     []byte // 当前片段中尚未检查的部分
+	tok     []byte
+	addr    Addr
+}
+
+// NewIPScanner 从 io.Reader 读取
+func NewIPScanner(rd io.Reader) *IPScanner {
+	return &IPScanner{rd: rd, buf: make([]byte, scanBufSize)}
+}
+
+// NewIPScannerBytes 直接扫描内存中的数据，不复制
+func NewIPScannerBytes(b []byte) *IPScanner {
+	return &IPScanner{buf: b, w: len(b), eof: true}
+}
+
+// ScanIPs 提取 b 中所有地址，fn 返回 false 时停止
+func ScanIPs(b []byte, fn func(ip Addr) bool) {
+	s := IPScanner{buf: b, w: len(b), eof: true}
+	for s.Scan() {
+		if !fn(s.addr) {
+			return
+		}
+	}
+}
+
+// Addr 最近一次 Scan 得到的地址
+func (s *IPScanner) Addr() Addr {
+	return s.addr
+}
+
+// Bytes 最近一次 Scan 得到的地址原文，下次 Scan 后失效
+func (s *IPScanner) Bytes() []byte {
+	return s.tok
+}
+
+// Err 读取时遇到的第一个非 EOF 错误
+func (s *IPScanner) Err() error {
+	return s.err
+}
+
+// Scan 前进到下一个地址，没有更多地址或读取出错时返回 false
+func (s *IPScanner) Scan() bool {
+	for {
+		if len(s.run) > 0 && s.nextInRun() {
+			return true
+		}
+
+		// 跳过非地址字符
+		for s.r < s.w && (s.skipRun && isScanChar(s.buf[s.r]) || !isScanChar(s.buf[s.r])) {
+			if !isScanChar(s.buf[s.r]) {
+				s.skipRun = false
+			}
+			s.r++
+		}
+		if s.r == s.w {
+			if s.eof {
+				return false
+			}
+			s.fill()
+			continue
+		}
+
+		end := s.r
+		for end < s.w && isScanChar(s.buf[end]) {
+			end++
+		}
+		if end-s.r > scanMaxRun {
+			s.r, s.skipRun = end, true
+			continue
+		}
+		if end == s.w && !s.eof {
+			// 片段可能被缓冲区截断，读入更多数据再判断
+			s.fill()
+			continue
+		}
+		s.run = s.buf[s.r:end]
+		s.r = end
+	}
+}
+
+// fill 把未处理数据移到缓冲区开头并读入更多数据
+func (s *IPScanner) fill() {
+	if s.r > 0 {
+		s.w = copy(s.buf, s.buf[s.r:s.w])
+		s.r = 0
+	}
+	for tries := 0; tries < 100; tries++ {
+		n, err := s.rd.Read(s.buf[s.w:])
+		s.w += n
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.eof = true
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	s.err = io.ErrNoProgress
+	s.eof = true
+}
+
+// nextInRun 在当前片段中找下一个地址
+func (s *IPScanner) nextInRun() bool {
+	run := s.run
+	// 片段中有冒号时先整体按 IPv6 解析，去掉句末的点
+	if hasByte(run, ':') {
+		t := trimByte(run, '.')
+		if ip, ok := scanIPv6(t); ok {
+			s.run, s.tok, s.addr = nil, t, ip
+			return true
+		}
+	}
+	// 否则取其中 [0-9.] 组成的子片段按 IPv4 解析，如 1.2.3.4:8080
+	for len(run) > 0 {
+		i := 0
+		for i < len(run) && !isDigitOrDot(run[i]) {
+			i++
+		}
+		j := i
+		for j < len(run) && isDigitOrDot(run[j]) {
+			j++
+		}
+		t := trimByte(run[i:j], '.')
+		run = run[j:]
+		if ip, ok := scanIPv4(t); ok {
+			s.run, s.tok, s.addr = run, t, ip
+			return true
+		}
+	}
+	s.run = nil
+	return false
+}
+
+// isScanChar 可能出现在地址中的字符
+func isScanChar(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' || c == '.' || c == ':'
+}
+
+func isDigitOrDot(c byte) bool {
+	return '0' <= c && c <= '9' || c == '.'
+}
+
+func hasByte(b []byte, c byte) bool {
+	for _, x := range b {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// trimByte 去掉两端的 c
+func trimByte(b []byte, c byte) []byte {
+	for len(b) > 0 && b[0] == c {
+		b = b[1:]
+	}
+	for len(b) > 0 && b[len(b)-1] == c {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// scanIPv4 与 ParseIPv4 规则相同，失败时不构造 error
+func scanIPv4(s []byte) (Addr, bool) {
+	var fields [4]uint8
+	var val, pos, digLen int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			if digLen == 1 && val == 0 {
+				return Addr{}, false
+			}
+			val = val*10 + int(c-'0')
+			digLen++
+			if val > 255 {
+				return Addr{}, false
+			}
+		case c == '.':
+			if i == 0 || i == len(s)-1 || s[i-1] == '.' || pos == 3 {
+				return Addr{}, false
+			}
+			fields[pos] = uint8(val)
+			pos++
+			val, digLen = 0, 0
+		default:
+			return Addr{}, false
+		}
+	}
+	if pos < 3 {
+		return Addr{}, false
+	}
+	fields[3] = uint8(val)
+	return addrFrom4(fields), true
+}
+
+// scanIPv6 与 parseIPv6 规则相同（不含 zone），失败时不构造 error
+func scanIPv6(s []byte) (Addr, bool) {
+	var ip [16]byte
+	ellipsis := -1
+
+	if len(s) >= 2 && s[0] == ':' && s[1] == ':' {
+		ellipsis = 0
+		s = s[2:]
+		if len(s) == 0 {
+			return IPv6Unspecified(), true
+		}
+	}
+
+	i := 0
+	for i < 16 {
+		off := 0
+		acc := uint32(0)
+		for ; off < len(s); off++ {
+			c := s[off]
+			if c >= '0' && c <= '9' {
+				acc = (acc << 4) + uint32(c-'0')
+			} else if c >= 'a' && c <= 'f' {
+				acc = (acc << 4) + uint32(c-'a'+10)
+			} else if c >= 'A' && c <= 'F' {
+				acc = (acc << 4) + uint32(c-'A'+10)
+			} else {
+				break
+			}
+			if off > 3 {
+				return Addr{}, false
+			}
+		}
+		if off == 0 {
+			return Addr{}, false
+		}
+
+		if off < len(s) && s[off] == '.' {
+			if ellipsis < 0 && i != 12 || i+4 > 16 {
+				return Addr{}, false
+			}
+			ip4, ok := scanIPv4(s)
+			if !ok {
+				return Addr{}, false
+			}
+			a4 := ip4.As4()
+			copy(ip[i:i+4], a4[:])
+			s = nil
+			i += 4
+			break
+		}
+
+		ip[i] = byte(acc >> 8)
+		ip[i+1] = byte(acc)
+		i += 2
+
+		s = s[off:]
+		if len(s) == 0 {
+			break
+		}
+		if s[0] != ':' || len(s) == 1 {
+			return Addr{}, false
+		}
+		s = s[1:]
+		if s[0] == ':' {
+			if ellipsis >= 0 {
+				return Addr{}, false
+			}
+			ellipsis = i
+			s = s[1:]
+			if len(s) == 0 {
+				break
+			}
+		}
+	}
+
+	if len(s) != 0 {
+		return Addr{}, false
+	}
+	if i < 16 {
+		if ellipsis < 0 {
+			return Addr{}, false
+		}
+		n := 16 - i
+		for j := i - 1; j >= ellipsis; j-- {
+			ip[j+n] = ip[j]
+		}
+		for j := ellipsis + n - 1; j >= ellipsis; j-- {
+			ip[j] = 0
+		}
+	} else if ellipsis >= 0 {
+		return Addr{}, false
+	}
+	return AddrFrom16(ip), true
+}
@@ -0,0 +1,92 @@
+package utip
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// scanLine 混合 IPv4、IPv6、端口、时间和十六进制字段的日志行
+const scanLine = `2024-05-01T12:34:56Z client=10.0.0.1:51234 xff="203.0.113.7, 198.51.100.23" ` +
+	`upstream=[2001:db8::1]:443 peer=fe80::1%eth0 mapped=::ffff:192.0.2.1 id=deadbeef ` +
+	`bad=1.2.3.256 ver=1.2.3 dns=8.8.8.8. nat64=64:ff9b::192.0.2.33 ts=12:34:56` + "\n"
+
+// scanWant scanLine 中的地址
+var scanWant = []string{
+	"10.0.0.1", "203.0.113.7", "198.51.100.23", "2001:db8::1", "fe80::1",
+	"::ffff:192.0.2.1", "8.8.8.8", "64:ff9b::c000:221",
+}
+
+// TestScanIPs ...
+func TestScanIPs(t *testing.T) {
+	var got []string
+	ScanIPs([]byte(scanLine), func(ip Addr) bool {
+		got = append(got, ip.String())
+		return true
+	})
+	if strings.Join(got, " ") != strings.Join(scanWant, " ") {
+		t.Errorf("ScanIPs = %v, want %v", got, scanWant)
+	}
+}
+
+// TestIPScannerReader 从 io.Reader 读取时，跨缓冲区边界的地址也能完整提取
+func TestIPScannerReader(t *testing.T) {
+	n := scanBufSize/len(scanLine) + 3
+	s := NewIPScanner(strings.NewReader(strings.Repeat(scanLine, n)))
+	count := 0
+	for s.Scan() {
+		if want := scanWant[count%len(scanWant)]; s.Addr().String() != want {
+			t.Fatalf("token %d = %s (%q), want %s", count, s.Addr(), s.Bytes(), want)
+		}
+		count++
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if count != n*len(scanWant) {
+		t.Errorf("scanned %d addresses, want %d", count, n*len(scanWant))
+	}
+}
+
+// TestScanZeroAllocs 提取和计数每个地址都不分配内存
+func TestScanZeroAllocs(t *testing.T) {
+	line := []byte(scanLine)
+	c := NewAddrCounter(24, 64)
+	countLine := func() {
+		ScanIPs(line, func(ip Addr) bool {
+			c.Add(ip)
+			return true
+		})
+	}
+	// 先插入所有 key，之后计数只更新已有的 map 项
+	countLine()
+	if allocs := testing.AllocsPerRun(100, countLine); allocs != 0 {
+		t.Errorf("ScanIPs + AddrCounter.Add: %v allocs per line, want 0", allocs)
+	}
+
+	s := NewIPScanner(bytes.NewReader(bytes.Repeat(line, 200)))
+	scanLineTokens := func() {
+		for i := 0; i < len(scanWant); i++ {
+			if !s.Scan() {
+				t.Fatal("scanner stopped early")
+			}
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, scanLineTokens); allocs != 0 {
+		t.Errorf("IPScanner.Scan: %v allocs per line, want 0", allocs)
+	}
+}
+
+// BenchmarkScanIPs 每次迭代处理一行，约 8 个地址
+func BenchmarkScanIPs(b *testing.B) {
+	line := []byte(scanLine)
+	c := NewAddrCounter(24, 64)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	for i := 0; i < b.N; i++ {
+		ScanIPs(line, func(ip Addr) bool {
+			c.Add(ip)
+			return true
+		})
+	}
+}
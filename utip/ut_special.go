@@ -0,0 +1,266 @@
+package utip
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// AddrFlags 地址分类标志，一个地址可以同时有多个标志
+type AddrFlags uint64
+
+const (
+	// FlagGlobal 全局可达（IANA Globally Reachable），不在任何特殊用途网段中的单播地址也是全局可达
+	FlagGlobal AddrFlags = 1 << iota
+	// FlagUnspecified 0.0.0.0/32、::/128
+	FlagUnspecified
+	// FlagThisNetwork 0.0.0.0/8
+	FlagThisNetwork
+	// FlagPrivate RFC 1918 私网：10/8、172.16/12、192.168/16
+	FlagPrivate
+	// FlagShared 运营商级 NAT 共享地址 100.64/10（RFC 6598）
+	FlagShared
+	// FlagLoopback 127/8、::1/128
+	FlagLoopback
+	// FlagLinkLocal 169.254/16、fe80::/10
+	FlagLinkLocal
+	// FlagIETFProtocol IETF 协议分配 192.0.0/24、2001::/23
+	FlagIETFProtocol
+	// FlagDSLite IPv4 Service Continuity Prefix 192.0.0.0/29
+	FlagDSLite
+	// FlagDummy 占位地址 192.0.0.8/32、100:0:0:1::/64
+	FlagDummy
+	// FlagAnycast PCP、TURN、DNS-SD SRP 等协议的任播地址
+	FlagAnycast
+	// FlagNAT64Discovery NAT64/DNS64 发现 192.0.0.170/32、192.0.0.171/32
+	FlagNAT64Discovery
+	// FlagDocumentation 文档示例地址 192.0.2/24、198.51.100/24、203.0.113/24、2001:db8::/32、3fff::/20
+	FlagDocumentation
+	// FlagAS112 AS112 服务
+	FlagAS112
+	// FlagAMT Automatic Multicast Tunneling 192.52.193/24、2001:3::/32
+	FlagAMT
+	// Flag6to4Relay 已废弃的 6to4 中继任播 192.88.99/24
+	Flag6to4Relay
+	// FlagBenchmarking 性能测试 198.18/15、2001:2::/48
+	FlagBenchmarking
+	// FlagReserved 保留 240/4
+	FlagReserved
+	// FlagBroadcast 受限广播 255.255.255.255/32
+	FlagBroadcast
+	// FlagMulticast 组播 224/4、ff00::/8
+	FlagMulticast
+	// FlagIPv4Mapped ::ffff:0:0/96
+	FlagIPv4Mapped
+	// FlagTranslation IPv4/IPv6 转换 64:ff9b::/96、64:ff9b:1::/48
+	FlagTranslation
+	// FlagDiscardOnly 丢弃前缀 100::/64
+	FlagDiscardOnly
+	// FlagTeredo 2001::/32
+	FlagTeredo
+	// FlagORCHID ORCHID 2001:10::/28（已废弃）、2001:20::/28
+	FlagORCHID
+	// FlagDRIP 无人机远程识别 2001:30::/28
+	FlagDRIP
+	// Flag6to4 2002::/16
+	Flag6to4
+	// FlagSRv6 SRv6 SID 5f00::/16
+	FlagSRv6
+	// FlagUniqueLocal IPv6 唯一本地地址 fc00::/7
+	FlagUniqueLocal
+)
+
+// flagNames String 使用，顺序与定义一致
+var flagNames = []string{
+	"global", "unspecified", "this-network", "private", "shared", "loopback", "link-local",
+	"ietf-protocol", "ds-lite", "dummy", "anycast", "nat64-discovery", "documentation", "as112", "amt",
+	"6to4-relay", "benchmarking", "reserved", "broadcast", "multicast", "ipv4-mapped", "translation",
+	"discard-only", "teredo", "orchid", "drip", "6to4", "srv6", "unique-local",
+}
+
+// Has 是否包含任一标志
+func (f AddrFlags) Has(flags AddrFlags) bool { return f&flags != 0 }
+
+// IsPublic 全局可达的单播地址
+func (f AddrFlags) IsPublic() bool {
+	return f.Has(FlagGlobal) && !f.Has(FlagMulticast|FlagBroadcast)
+}
+
+// IsIntranet 内网地址：RFC 1918 私网、CGNAT 共享地址、IPv6 唯一本地地址
+func (f AddrFlags) IsIntranet() bool {
+	return f.Has(FlagPrivate | FlagShared | FlagUniqueLocal)
+}
+
+// String 如 private|benchmarking
+func (f AddrFlags) String() string {
+	var names []string
+	for i, name := range flagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// SpecialPurpose ...
+// @Description: IANA IPv4/IPv6 Special-Purpose Address Registry 中的一项，属性含义与注册表一致
+type SpecialPurpose struct {
+	Prefix             Prefix
+	Name               string
+	RFC                string
+	Flags              AddrFlags
+	Source             bool
+	Destination        bool
+	Forwardable        bool
+	GloballyReachable  bool
+	ReservedByProtocol bool
+}
+
+// specialPurposeRow 注册表原始数据，attrs 依次为 Source、Destination、Forwardable、Globally Reachable、Reserved-by-Protocol
+type specialPurposeRow struct {
+	prefix string
+	name   string
+	rfc    string
+	flags  AddrFlags
+	attrs  string
+}
+
+// specialPurposeRegistry ...
+// @Description: 数据来自 https://www.iana.org/assignments/iana-ipv4-special-registry
+// 和 https://www.iana.org/assignments/iana-ipv6-special-registry，注册表中的 N/A 记为 F；
+// 组播网段来自 IANA 组播地址空间注册表
+var specialPurposeRegistry = []specialPurposeRow{
+	// IPv4
+	{"0.0.0.0/8", "This network", "RFC791", FlagThisNetwork, "TFFFT"},
+	{"0.0.0.0/32", "This host on this network", "RFC1122", FlagThisNetwork | FlagUnspecified, "TFFFT"},
+	{"10.0.0.0/8", "Private-Use", "RFC1918", FlagPrivate, "TTTFF"},
+	{"100.64.0.0/10", "Shared Address Space", "RFC6598", FlagShared, "TTTFF"},
+	{"127.0.0.0/8", "Loopback", "RFC1122", FlagLoopback, "FFFFT"},
+	{"169.254.0.0/16", "Link Local", "RFC3927", FlagLinkLocal, "TTFFT"},
+	{"172.16.0.0/12", "Private-Use", "RFC1918", FlagPrivate, "TTTFF"},
+	{"192.0.0.0/24", "IETF Protocol Assignments", "RFC6890", FlagIETFProtocol, "FFFFF"},
+	{"192.0.0.0/29", "IPv4 Service Continuity Prefix", "RFC7335", FlagIETFProtocol | FlagDSLite, "TTTFF"},
+	{"192.0.0.8/32", "IPv4 dummy address", "RFC7600", FlagIETFProtocol | FlagDummy, "TFFFF"},
+	{"192.0.0.9/32", "Port Control Protocol Anycast", "RFC7723", FlagIETFProtocol | FlagAnycast, "TTTTF"},
+	{"192.0.0.10/32", "Traversal Using Relays around NAT Anycast", "RFC8155", FlagIETFProtocol | FlagAnycast, "TTTTF"},
+	{"192.0.0.170/32", "NAT64/DNS64 Discovery", "RFC8880", FlagIETFProtocol | FlagNAT64Discovery, "FFFFT"},
+	{"192.0.0.171/32", "NAT64/DNS64 Discovery", "RFC8880", FlagIETFProtocol | FlagNAT64Discovery, "FFFFT"},
+	{"192.0.2.0/24", "Documentation (TEST-NET-1)", "RFC5737", FlagDocumentation, "FFFFF"},
+	{"192.31.196.0/24", "AS112-v4", "RFC7535", FlagAS112, "TTTTF"},
+	{"192.52.193.0/24", "AMT", "RFC7450", FlagAMT, "TTTTF"},
+	{"192.88.99.0/24", "Deprecated (6to4 Relay Anycast)", "RFC7526", Flag6to4Relay, "FFFFF"},
+	{"192.168.0.0/16", "Private-Use", "RFC1918", FlagPrivate, "TTTFF"},
+	{"192.175.48.0/24", "Direct Delegation AS112 Service", "RFC7534", FlagAS112, "TTTTF"},
+	{"198.18.0.0/15", "Benchmarking", "RFC2544", FlagBenchmarking, "TTTFF"},
+	{"198.51.100.0/24", "Documentation (TEST-NET-2)", "RFC5737", FlagDocumentation, "FFFFF"},
+	{"203.0.113.0/24", "Documentation (TEST-NET-3)", "RFC5737", FlagDocumentation, "FFFFF"},
+	{"224.0.0.0/4", "Multicast", "RFC5771", FlagMulticast, "FTTFF"},
+	{"240.0.0.0/4", "Reserved", "RFC1112", FlagReserved, "FFFFT"},
+	{"255.255.255.255/32", "Limited Broadcast", "RFC8190", FlagReserved | FlagBroadcast, "FTFFT"},
+
+	// IPv6
+	{"::1/128", "Loopback Address", "RFC4291", FlagLoopback, "FFFFT"},
+	{"::/128", "Unspecified Address", "RFC4291", FlagUnspecified, "TFFFT"},
+	{"::ffff:0:0/96", "IPv4-mapped Address", "RFC4291", FlagIPv4Mapped, "FFFFT"},
+	{"64:ff9b::/96", "IPv4-IPv6 Translat.", "RFC6052", FlagTranslation, "TTTTF"},
+	{"64:ff9b:1::/48", "IPv4-IPv6 Translat.", "RFC8215", FlagTranslation, "TTTFF"},
+	{"100::/64", "Discard-Only Address Block", "RFC6666", FlagDiscardOnly, "TTTFF"},
+	{"100:0:0:1::/64", "Dummy IPv6 Prefix", "RFC9780", FlagDummy, "TFFFF"},
+	{"2001::/23", "IETF Protocol Assignments", "RFC2928", FlagIETFProtocol, "FFFFF"},
+	{"2001::/32", "TEREDO", "RFC4380", FlagIETFProtocol | FlagTeredo, "TTTFF"},
+	{"2001:1::1/128", "Port Control Protocol Anycast", "RFC7723", FlagIETFProtocol | FlagAnycast, "TTTTF"},
+	{"2001:1::2/128", "Traversal Using Relays around NAT Anycast", "RFC8155", FlagIETFProtocol | FlagAnycast, "TTTTF"},
+	{"2001:1::3/128", "DNS-SD Service Registration Protocol Anycast", "RFC9665", FlagIETFProtocol | FlagAnycast, "TTTTF"},
+	{"2001:2::/48", "Benchmarking", "RFC5180", FlagIETFProtocol | FlagBenchmarking, "TTTFF"},
+	{"2001:3::/32", "AMT", "RFC7450", FlagIETFProtocol | FlagAMT, "TTTTF"},
+	{"2001:4:112::/48", "AS112-v6", "RFC7535", FlagIETFProtocol | FlagAS112, "TTTTF"},
+	{"2001:10::/28", "Deprecated (previously ORCHID)", "RFC4843", FlagIETFProtocol | FlagORCHID, "FFFFF"},
+	{"2001:20::/28", "ORCHIDv2", "RFC7343", FlagIETFProtocol | FlagORCHID, "TTTTF"},
+	{"2001:30::/28", "Drone Remote ID Protocol Entity Tags (DETs) Prefix", "RFC9374", FlagIETFProtocol | FlagDRIP, "TTTTF"},
+	{"2001:db8::/32", "Documentation", "RFC3849", FlagDocumentation, "FFFFF"},
+	{"2002::/16", "6to4", "RFC3056", Flag6to4, "TTTFF"},
+	{"2620:4f:8000::/48", "Direct Delegation AS112 Service", "RFC7534", FlagAS112, "TTTTF"},
+	{"3fff::/20", "Documentation", "RFC9637", FlagDocumentation, "FFFFF"},
+	{"5f00::/16", "Segment Routing (SRv6) SIDs", "RFC9602", FlagSRv6, "TTTFF"},
+	{"fc00::/7", "Unique-Local", "RFC4193", FlagUniqueLocal, "TTTFF"},
+	{"fe80::/10", "Link-Local Unicast", "RFC4291", FlagLinkLocal, "TTFFT"},
+	{"ff00::/8", "Multicast", "RFC4291", FlagMulticast, "FTTFF"},
+}
+
+var (
+	specialPurposeOnce  sync.Once
+	specialPurposeTable Table[SpecialPurpose]
+)
+
+// loadSpecialPurpose 注册表数据载入路由表，只执行一次
+func loadSpecialPurpose() {
+	specialPurposeOnce.Do(func() {
+		entries := make([]TableEntry[SpecialPurpose], 0, len(specialPurposeRegistry))
+		for _, row := range specialPurposeRegistry {
+			p := MustParsePrefix(row.prefix)
+			entries = append(entries, TableEntry[SpecialPurpose]{Prefix: p, Value: SpecialPurpose{
+				Prefix:             p,
+				Name:               row.name,
+				RFC:                row.rfc,
+				Flags:              row.flags,
+				Source:             row.attrs[0] == 'T',
+				Destination:        row.attrs[1] == 'T',
+				Forwardable:        row.attrs[2] == 'T',
+				GloballyReachable:  row.attrs[3] == 'T',
+				ReservedByProtocol: row.attrs[4] == 'T',
+			}})
+		}
+		specialPurposeTable.InsertAll(entries)
+	})
+}
+
+// SpecialPurposes 包含地址的所有注册表项，从大网段到小网段排列，最后一项的属性优先
+func SpecialPurposes(ip Addr) []SpecialPurpose {
+	if !ip.IsValid() {
+		return nil
+	}
+	loadSpecialPurpose()
+	entries := specialPurposeTable.Supernets(PrefixFrom(ip, ip.BitLen()))
+	res := make([]SpecialPurpose, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Value)
+	}
+	return res
+}
+
+// Classify ...
+// @Description: 按 IANA 特殊用途地址注册表给地址分类，返回所有匹配网段的标志；
+// 全局可达由最长匹配项决定，不在注册表中的地址全局可达；
+// IPv4-mapped 地址同时带上内嵌 IPv4 地址的分类标志
+// @param ip
+// @return AddrFlags
+func Classify(ip Addr) AddrFlags {
+	if !ip.IsValid() {
+		return 0
+	}
+	var flags AddrFlags
+	global := true
+	for _, sp := range SpecialPurposes(ip.WithZone("")) {
+		flags |= sp.Flags
+		global = sp.GloballyReachable
+	}
+	if ip.Is4In6() {
+		flags |= Classify(ip.Unmap()) &^ FlagGlobal
+	}
+	if global {
+		flags |= FlagGlobal
+	}
+	return flags
+}
+
+// ClassifyIP net.IP 版本的 Classify
+func ClassifyIP(ip net.IP) AddrFlags {
+	addr, ok := AddrFromIP(ip)
+	if !ok {
+		return 0
+	}
+	return Classify(addr)
+}
@@ -0,0 +1,207 @@
+package utip
+
+import (
+	"net"
+	"testing"
+)
+
+// registryCases 注册表的每一项各取一个只落在该项（及其上级网段）中的地址，
+// flags 为 Classify 的完整结果，name 为最长匹配项
+var registryCases = []struct {
+	prefix string
+	probe  string
+	name   string
+	flags  AddrFlags
+}{
+	// IPv4
+	{"0.0.0.0/8", "0.1.2.3", "This network", FlagThisNetwork},
+	{"0.0.0.0/32", "0.0.0.0", "This host on this network", FlagThisNetwork | FlagUnspecified},
+	{"10.0.0.0/8", "10.1.2.3", "Private-Use", FlagPrivate},
+	{"100.64.0.0/10", "100.127.255.254", "Shared Address Space", FlagShared},
+	{"127.0.0.0/8", "127.0.0.1", "Loopback", FlagLoopback},
+	{"169.254.0.0/16", "169.254.1.1", "Link Local", FlagLinkLocal},
+	{"172.16.0.0/12", "172.31.255.255", "Private-Use", FlagPrivate},
+	{"192.0.0.0/24", "192.0.0.100", "IETF Protocol Assignments", FlagIETFProtocol},
+	{"192.0.0.0/29", "192.0.0.1", "IPv4 Service Continuity Prefix", FlagIETFProtocol | FlagDSLite},
+	{"192.0.0.8/32", "192.0.0.8", "IPv4 dummy address", FlagIETFProtocol | FlagDummy},
+	{"192.0.0.9/32", "192.0.0.9", "Port Control Protocol Anycast", FlagIETFProtocol | FlagAnycast | FlagGlobal},
+	{"192.0.0.10/32", "192.0.0.10", "Traversal Using Relays around NAT Anycast", FlagIETFProtocol | FlagAnycast | FlagGlobal},
+	{"192.0.0.170/32", "192.0.0.170", "NAT64/DNS64 Discovery", FlagIETFProtocol | FlagNAT64Discovery},
+	{"192.0.0.171/32", "192.0.0.171", "NAT64/DNS64 Discovery", FlagIETFProtocol | FlagNAT64Discovery},
+	{"192.0.2.0/24", "192.0.2.1", "Documentation (TEST-NET-1)", FlagDocumentation},
+	{"192.31.196.0/24", "192.31.196.1", "AS112-v4", FlagAS112 | FlagGlobal},
+	{"192.52.193.0/24", "192.52.193.1", "AMT", FlagAMT | FlagGlobal},
+	{"192.88.99.0/24", "192.88.99.1", "Deprecated (6to4 Relay Anycast)", Flag6to4Relay},
+	{"192.168.0.0/16", "192.168.1.1", "Private-Use", FlagPrivate},
+	{"192.175.48.0/24", "192.175.48.1", "Direct Delegation AS112 Service", FlagAS112 | FlagGlobal},
+	{"198.18.0.0/15", "198.19.0.1", "Benchmarking", FlagBenchmarking},
+	{"198.51.100.0/24", "198.51.100.1", "Documentation (TEST-NET-2)", FlagDocumentation},
+	{"203.0.113.0/24", "203.0.113.1", "Documentation (TEST-NET-3)", FlagDocumentation},
+	{"224.0.0.0/4", "224.0.0.1", "Multicast", FlagMulticast},
+	{"240.0.0.0/4", "240.0.0.1", "Reserved", FlagReserved},
+	{"255.255.255.255/32", "255.255.255.255", "Limited Broadcast", FlagReserved | FlagBroadcast},
+
+	// IPv6
+	{"::1/128", "::1", "Loopback Address", FlagLoopback},
+	{"::/128", "::", "Unspecified Address", FlagUnspecified},
+	{"::ffff:0:0/96", "::ffff:8.8.8.8", "IPv4-mapped Address", FlagIPv4Mapped},
+	{"64:ff9b::/96", "64:ff9b::808:808", "IPv4-IPv6 Translat.", FlagTranslation | FlagGlobal},
+	{"64:ff9b:1::/48", "64:ff9b:1::1", "IPv4-IPv6 Translat.", FlagTranslation},
+	{"100::/64", "100::1", "Discard-Only Address Block", FlagDiscardOnly},
+	{"100:0:0:1::/64", "100:0:0:1::1", "Dummy IPv6 Prefix", FlagDummy},
+	{"2001::/23", "2001:100::1", "IETF Protocol Assignments", FlagIETFProtocol},
+	{"2001::/32", "2001::1", "TEREDO", FlagIETFProtocol | FlagTeredo},
+	{"2001:1::1/128", "2001:1::1", "Port Control Protocol Anycast", FlagIETFProtocol | FlagAnycast | FlagGlobal},
+	{"2001:1::2/128", "2001:1::2", "Traversal Using Relays around NAT Anycast", FlagIETFProtocol | FlagAnycast | FlagGlobal},
+	{"2001:1::3/128", "2001:1::3", "DNS-SD Service Registration Protocol Anycast", FlagIETFProtocol | FlagAnycast | FlagGlobal},
+	{"2001:2::/48", "2001:2::1", "Benchmarking", FlagIETFProtocol | FlagBenchmarking},
+	{"2001:3::/32", "2001:3::1", "AMT", FlagIETFProtocol | FlagAMT | FlagGlobal},
+	{"2001:4:112::/48", "2001:4:112::1", "AS112-v6", FlagIETFProtocol | FlagAS112 | FlagGlobal},
+	{"2001:10::/28", "2001:10::1", "Deprecated (previously ORCHID)", FlagIETFProtocol | FlagORCHID},
+	{"2001:20::/28", "2001:20::1", "ORCHIDv2", FlagIETFProtocol | FlagORCHID | FlagGlobal},
+	{"2001:30::/28", "2001:30::1", "Drone Remote ID Protocol Entity Tags (DETs) Prefix", FlagIETFProtocol | FlagDRIP | FlagGlobal},
+	{"2001:db8::/32", "2001:db8::1", "Documentation", FlagDocumentation},
+	{"2002::/16", "2002:c000:204::1", "6to4", Flag6to4},
+	{"2620:4f:8000::/48", "2620:4f:8000::1", "Direct Delegation AS112 Service", FlagAS112 | FlagGlobal},
+	{"3fff::/20", "3fff:fff::1", "Documentation", FlagDocumentation},
+	{"5f00::/16", "5f00::1", "Segment Routing (SRv6) SIDs", FlagSRv6},
+	{"fc00::/7", "fd12:3456::1", "Unique-Local", FlagUniqueLocal},
+	{"fe80::/10", "fe80::1", "Link-Local Unicast", FlagLinkLocal},
+	{"ff00::/8", "ff02::1", "Multicast", FlagMulticast},
+}
+
+// TestClassifyRegistry 注册表每一项的分类、最长匹配项，以及网段首尾地址都能查到该项
+func TestClassifyRegistry(t *testing.T) {
+	if len(registryCases) != len(specialPurposeRegistry) {
+		t.Fatalf("registryCases has %d rows, registry has %d", len(registryCases), len(specialPurposeRegistry))
+	}
+	for i, tt := range registryCases {
+		if row := specialPurposeRegistry[i]; row.prefix != tt.prefix || row.name != tt.name {
+			t.Errorf("row %d = %s %q, want %s %q", i, row.prefix, row.name, tt.prefix, tt.name)
+		}
+		p := MustParsePrefix(tt.prefix)
+		ip := MustParseAddr(tt.probe)
+		if !p.Contains(ip) {
+			t.Fatalf("%s does not contain probe %s", tt.prefix, tt.probe)
+		}
+		if got := Classify(ip); got != tt.flags {
+			t.Errorf("Classify(%s) = %v, want %v", tt.probe, got, tt.flags)
+		}
+		sps := SpecialPurposes(ip)
+		if len(sps) == 0 || sps[len(sps)-1].Prefix != p || sps[len(sps)-1].Name != tt.name {
+			t.Errorf("SpecialPurposes(%s) = %v, want last %s %q", tt.probe, sps, tt.prefix, tt.name)
+		}
+		for _, edge := range []Addr{p.Addr(), p.Last()} {
+			if !hasSpecialPurpose(SpecialPurposes(edge), p) {
+				t.Errorf("SpecialPurposes(%s) misses %s", edge, tt.prefix)
+			}
+		}
+	}
+}
+
+// hasSpecialPurpose sps 中是否有网段 p
+func hasSpecialPurpose(sps []SpecialPurpose, p Prefix) bool {
+	for _, sp := range sps {
+		if sp.Prefix == p {
+			return true
+		}
+	}
+	return false
+}
+
+// TestClassifyBoundary 网段边界内外的地址
+func TestClassifyBoundary(t *testing.T) {
+	tests := []struct {
+		ip    string
+		flags AddrFlags
+	}{
+		// 198.18.0.0/15
+		{"198.17.255.255", FlagGlobal},
+		{"198.18.0.0", FlagBenchmarking},
+		{"198.19.255.255", FlagBenchmarking},
+		{"198.20.0.0", FlagGlobal},
+		// 224.0.0.0/4、240.0.0.0/4、255.255.255.255/32
+		{"223.255.255.255", FlagGlobal},
+		{"239.255.255.255", FlagMulticast},
+		{"240.0.0.0", FlagReserved},
+		{"255.255.255.254", FlagReserved},
+		{"255.255.255.255", FlagReserved | FlagBroadcast},
+		// fc00::/7
+		{"fbff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", FlagGlobal},
+		{"fc00::", FlagUniqueLocal},
+		{"fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", FlagUniqueLocal},
+		{"fe00::", FlagGlobal},
+		// fe80::/10，已废弃的站点本地地址 fec0::/10 不在注册表中
+		{"fe7f:ffff:ffff:ffff:ffff:ffff:ffff:ffff", FlagGlobal},
+		{"fe80::", FlagLinkLocal},
+		{"febf:ffff:ffff:ffff:ffff:ffff:ffff:ffff", FlagLinkLocal},
+		{"fec0::", FlagGlobal},
+		// 2002::/16
+		{"2001:ffff:ffff:ffff:ffff:ffff:ffff:ffff", FlagGlobal},
+		{"2002::", Flag6to4},
+		{"2002:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Flag6to4},
+		{"2003::", FlagGlobal},
+		// IPv4-mapped 带上内嵌地址的分类，全局可达由 ::ffff:0:0/96 决定
+		{"::ffff:10.0.0.1", FlagIPv4Mapped | FlagPrivate},
+		{"::ffff:198.18.0.1", FlagIPv4Mapped | FlagBenchmarking},
+		// zone 不影响分类
+		{"fe80::1%eth0", FlagLinkLocal},
+	}
+	for _, tt := range tests {
+		if got := Classify(MustParseAddr(tt.ip)); got != tt.flags {
+			t.Errorf("Classify(%s) = %v, want %v", tt.ip, got, tt.flags)
+		}
+	}
+	if got := Classify(Addr{}); got != 0 {
+		t.Errorf("Classify(invalid) = %v, want none", got)
+	}
+}
+
+// TestIsPublicIP 按注册表分类后的回归用例
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want bool
+	}{
+		{"8.8.8.8/32", true},
+		{"1.1.1.1/32", true},
+		{"171.8.1.1/16", true},
+		{"10.0.0.1/8", false},
+		{"172.16.0.1/12", false},
+		{"172.32.0.1/12", true},
+		{"192.168.1.1/24", false},
+		{"100.64.0.1/10", false},
+		{"127.0.0.1/8", false},
+		{"169.254.1.1/16", false},
+		{"192.0.2.1/24", false},
+		{"198.18.0.1/15", false},
+		{"224.0.0.1/4", false},
+		{"240.0.0.1/4", false},
+		{"255.255.255.255/32", false},
+		{"192.0.0.9/32", true},
+		// 全局单播 IPv6 地址是公网地址
+		{"2606:4700::1111/128", true},
+		{"2400:cb00::1/32", true},
+		{"::ffff:8.8.8.8/128", true}, // net.IP 不区分 IPv4-mapped 和 IPv4
+		{"2001:db8::1/64", false},
+		{"fd00::1/8", false},
+		{"fe80::1/64", false},
+		{"ff02::1/128", false},
+		{"::1/128", false},
+	}
+	for _, tt := range tests {
+		got, err := IsPublicIP(tt.cidr)
+		if err != nil {
+			t.Fatalf("IsPublicIP(%q): %v", tt.cidr, err)
+		}
+		if got != tt.want {
+			t.Errorf("IsPublicIP(%q) = %v, want %v", tt.cidr, got, tt.want)
+		}
+	}
+	if _, err := IsPublicIP("8.8.8.8"); err == nil {
+		t.Error("IsPublicIP without prefix length: want error")
+	}
+	if ClassifyIP(net.IP{1, 2, 3}) != 0 {
+		t.Error("ClassifyIP(bad length) != 0")
+	}
+}
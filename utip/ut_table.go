@@ -0,0 +1,344 @@
+package utip
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Table ...
+// @Description: 按网段做最长前缀匹配的路由表（路径压缩的二叉 radix trie），支持 IPv4 和 IPv6
+// 读操作无锁，写操作串行并复制受影响的路径后原子替换根节点（copy-on-write），
+// 读者总是看到某个完整版本，零值可用
+type Table[T any] struct {
+	mu   sync.Mutex
+	root atomic.Pointer[tableRoot[T]]
+}
+
+// TableEntry 路由表中的一项
+type TableEntry[T any] struct {
+	Prefix Prefix
+	Value  T
+}
+
+// tableRoot 路由表的一个不可变版本
+type tableRoot[T any] struct {
+	v4   *tableNode[T]
+	v6   *tableNode[T]
+	size int
+	gen  uint64
+}
+
+// tableNode 节点发布后不再修改，更新时复制；同一次写操作中新建的节点（gen 相同）可以原地修改
+type tableNode[T any] struct {
+	prefix   Prefix // 已掩码
+	value    T
+	hasValue bool
+	child    [2]*tableNode[T]
+	gen      uint64
+}
+
+// own 返回可在本次写操作中修改的节点
+func (n *tableNode[T]) own(gen uint64) *tableNode[T] {
+	if n.gen == gen {
+		return n
+	}
+	nn := *n
+	nn.gen = gen
+	return &nn
+}
+
+// load 当前版本，零值 Table 返回空版本
+func (t *Table[T]) load() *tableRoot[T] {
+	if r := t.root.Load(); r != nil {
+		return r
+	}
+	return &tableRoot[T]{}
+}
+
+// top 地址族对应的根节点
+func (r *tableRoot[T]) top(ip Addr) *tableNode[T] {
+	if ip.Is4() {
+		return r.v4
+	}
+	return r.v6
+}
+
+// setTop 替换地址族对应的根节点，只用于尚未发布的版本
+func (r *tableRoot[T]) setTop(ip Addr, n *tableNode[T]) {
+	if ip.Is4() {
+		r.v4 = n
+	} else {
+		r.v6 = n
+	}
+}
+
+// begin 开始一次写操作，返回下一个版本的副本，调用方需持有 t.mu
+func (t *Table[T]) begin() *tableRoot[T] {
+	nr := *t.load()
+	nr.gen++
+	return &nr
+}
+
+// prefixBit 地址在网段内第 i 位（从 0 开始）
+func prefixBit(ip Addr, i int) int {
+	if ip.Is4() {
+		i += 96
+	}
+	return int(ip.addr.bit(i))
+}
+
+// commonBits 两个同族网段的公共前缀长度
+func commonBits(a, b Prefix) int {
+	n := a.ip.addr.xor(b.ip.addr).leadingZeros()
+	if a.ip.Is4() {
+		n -= 96
+	}
+	if int(a.bits) < n {
+		n = int(a.bits)
+	}
+	if int(b.bits) < n {
+		n = int(b.bits)
+	}
+	return n
+}
+
+// Len 表项个数
+func (t *Table[T]) Len() int {
+	return t.load().size
+}
+
+// Insert 插入或覆盖网段，网段会先掩码，无效网段忽略
+func (t *Table[T]) Insert(p Prefix, value T) {
+	t.InsertAll([]TableEntry[T]{{Prefix: p, Value: value}})
+}
+
+// InsertAll 批量插入，整批只发布一次新版本，适合初始化大表
+func (t *Table[T]) InsertAll(entries []TableEntry[T]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.begin()
+	for _, e := range entries {
+		if !e.Prefix.IsValid() {
+			continue
+		}
+		p := e.Prefix.Masked()
+		n, added := tableInsert(r.top(p.ip), p, e.Value, r.gen)
+		r.setTop(p.ip, n)
+		if added {
+			r.size++
+		}
+	}
+	t.root.Store(r)
+}
+
+// tableInsert 返回新的子树根节点，以及是否新增了表项
+func tableInsert[T any](n *tableNode[T], p Prefix, value T, gen uint64) (*tableNode[T], bool) {
+	if n == nil {
+		return &tableNode[T]{prefix: p, value: value, hasValue: true, gen: gen}, true
+	}
+
+	common := commonBits(n.prefix, p)
+	switch {
+	case common == int(n.prefix.bits) && n.prefix.bits == p.bits:
+		// 同一网段，覆盖
+		added := !n.hasValue
+		nn := n.own(gen)
+		nn.value, nn.hasValue = value, true
+		return nn, added
+	case common == int(n.prefix.bits):
+		// p 在 n 之下
+		nn := n.own(gen)
+		b := prefixBit(p.ip, int(n.prefix.bits))
+		var added bool
+		nn.child[b], added = tableInsert(nn.child[b], p, value, gen)
+		return nn, added
+	case common == int(p.bits):
+		// n 在 p 之下
+		nn := &tableNode[T]{prefix: p, value: value, hasValue: true, gen: gen}
+		nn.child[prefixBit(n.prefix.ip, int(p.bits))] = n
+		return nn, true
+	default:
+		// 分叉，插入不带值的中间节点
+		glue := &tableNode[T]{prefix: PrefixFrom(p.ip, common).Masked(), gen: gen}
+		glue.child[prefixBit(n.prefix.ip, common)] = n
+		glue.child[prefixBit(p.ip, common)] = &tableNode[T]{prefix: p, value: value, hasValue: true, gen: gen}
+		return glue, true
+	}
+}
+
+// Delete 删除网段，返回是否存在
+func (t *Table[T]) Delete(p Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	p = p.Masked()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.begin()
+	n, deleted := tableDelete(r.top(p.ip), p, r.gen)
+	if !deleted {
+		return false
+	}
+	r.setTop(p.ip, n)
+	r.size--
+	t.root.Store(r)
+	return true
+}
+
+// tableDelete 返回新的子树根节点，以及是否删除了表项
+func tableDelete[T any](n *tableNode[T], p Prefix, gen uint64) (*tableNode[T], bool) {
+	if n == nil || !n.prefix.ContainsPrefix(p) {
+		return n, false
+	}
+	if n.prefix.bits == p.bits {
+		if !n.hasValue {
+			return n, false
+		}
+		nn := n.own(gen)
+		var zero T
+		nn.value, nn.hasValue = zero, false
+		return nn.compact(), true
+	}
+
+	b := prefixBit(p.ip, int(n.prefix.bits))
+	c, deleted := tableDelete(n.child[b], p, gen)
+	if !deleted {
+		return n, false
+	}
+	nn := n.own(gen)
+	nn.child[b] = c
+	return nn.compact(), true
+}
+
+// compact 不带值的节点只剩一个或没有子节点时移除
+func (n *tableNode[T]) compact() *tableNode[T] {
+	if n.hasValue {
+		return n
+	}
+	switch {
+	case n.child[0] == nil:
+		return n.child[1]
+	case n.child[1] == nil:
+		return n.child[0]
+	}
+	return n
+}
+
+// Get 精确查找网段
+func (t *Table[T]) Get(p Prefix) (value T, ok bool) {
+	if !p.IsValid() {
+		return value, false
+	}
+	p = p.Masked()
+	n := t.load().top(p.ip)
+	for n != nil && n.prefix.ContainsPrefix(p) {
+		if n.prefix.bits == p.bits {
+			return n.value, n.hasValue
+		}
+		n = n.child[prefixBit(p.ip, int(n.prefix.bits))]
+	}
+	return value, false
+}
+
+// Lookup 最长前缀匹配，返回包含 ip 的最长网段
+func (t *Table[T]) Lookup(ip Addr) (p Prefix, value T, ok bool) {
+	if !ip.IsValid() {
+		return p, value, false
+	}
+	ip = ip.WithZone("")
+	n := t.load().top(ip)
+	for n != nil && n.prefix.Contains(ip) {
+		if n.hasValue {
+			p, value, ok = n.prefix, n.value, true
+		}
+		if int(n.prefix.bits) == ip.BitLen() {
+			break
+		}
+		n = n.child[prefixBit(ip, int(n.prefix.bits))]
+	}
+	return p, value, ok
+}
+
+// LookupPrefix 最长前缀匹配，返回包含 p 的最长网段（含 p 自身）
+func (t *Table[T]) LookupPrefix(p Prefix) (match Prefix, value T, ok bool) {
+	supernets := t.Supernets(p)
+	if len(supernets) == 0 {
+		return match, value, false
+	}
+	last := supernets[len(supernets)-1]
+	return last.Prefix, last.Value, true
+}
+
+// Supernets 包含 p 的所有表项（含 p 自身），从短到长排列
+func (t *Table[T]) Supernets(p Prefix) []TableEntry[T] {
+	if !p.IsValid() {
+		return nil
+	}
+	p = p.Masked()
+	var res []TableEntry[T]
+	n := t.load().top(p.ip)
+	for n != nil && n.prefix.ContainsPrefix(p) {
+		if n.hasValue {
+			res = append(res, TableEntry[T]{Prefix: n.prefix, Value: n.value})
+		}
+		if n.prefix.bits == p.bits {
+			break
+		}
+		n = n.child[prefixBit(p.ip, int(n.prefix.bits))]
+	}
+	return res
+}
+
+// Subnets 被 p 包含的所有表项（含 p 自身），按地址排序
+func (t *Table[T]) Subnets(p Prefix) []TableEntry[T] {
+	if !p.IsValid() {
+		return nil
+	}
+	p = p.Masked()
+	n := t.load().top(p.ip)
+	for n != nil && !p.ContainsPrefix(n.prefix) {
+		if !n.prefix.ContainsPrefix(p) {
+			return nil
+		}
+		n = n.child[prefixBit(p.ip, int(n.prefix.bits))]
+	}
+	var res []TableEntry[T]
+	n.walk(func(e TableEntry[T]) bool {
+		res = append(res, e)
+		return true
+	})
+	return res
+}
+
+// Walk 按地址顺序遍历（IPv4 在前，同一地址短网段在前），fn 返回 false 时停止
+func (t *Table[T]) Walk(fn func(p Prefix, value T) bool) {
+	r := t.load()
+	cb := func(e TableEntry[T]) bool {
+		return fn(e.Prefix, e.Value)
+	}
+	if r.v4.walk(cb) {
+		r.v6.walk(cb)
+	}
+}
+
+// Entries 所有表项，按 Walk 的顺序
+func (t *Table[T]) Entries() []TableEntry[T] {
+	res := make([]TableEntry[T], 0, t.Len())
+	t.Walk(func(p Prefix, value T) bool {
+		res = append(res, TableEntry[T]{Prefix: p, Value: value})
+		return true
+	})
+	return res
+}
+
+// walk 前序遍历，返回 false 表示已被中止
+func (n *tableNode[T]) walk(fn func(e TableEntry[T]) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.hasValue && !fn(TableEntry[T]{Prefix: n.prefix, Value: n.value}) {
+		return false
+	}
+	return n.child[0].walk(fn) && n.child[1].walk(fn)
+}
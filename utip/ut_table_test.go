@@ -0,0 +1,350 @@
+package utip
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// newTestTable 按 "网段 值" 建表
+func newTestTable(t testing.TB, prefixes ...string) *Table[string] {
+	t.Helper()
+	tbl := &Table[string]{}
+	for _, s := range prefixes {
+		tbl.Insert(MustParsePrefix(s), s)
+	}
+	return tbl
+}
+
+var tablePrefixes = []string{
+	"0.0.0.0/0",
+	"10.0.0.0/8",
+	"10.1.0.0/16",
+	"10.1.2.0/24",
+	"10.1.2.3/32",
+	"10.2.0.0/16",
+	"192.168.0.0/16",
+	"::/0",
+	"2001:db8::/32",
+	"2001:db8:1::/48",
+	"2001:db8:1:2::/64",
+	"fe80::/10",
+}
+
+// TestTableLookup 最长前缀匹配
+func TestTableLookup(t *testing.T) {
+	tbl := newTestTable(t, tablePrefixes...)
+	tests := []struct {
+		ip, want string
+	}{
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.4", "10.1.2.0/24"},
+		{"10.1.3.1", "10.1.0.0/16"},
+		{"10.3.0.1", "10.0.0.0/8"},
+		{"11.0.0.1", "0.0.0.0/0"},
+		{"192.168.255.255", "192.168.0.0/16"},
+		{"2001:db8:1:2::1", "2001:db8:1:2::/64"},
+		{"2001:db8:1:3::1", "2001:db8:1::/48"},
+		{"2001:db8:2::1", "2001:db8::/32"},
+		{"fe80::1%eth0", "fe80::/10"},
+		{"2002::1", "::/0"},
+	}
+	for _, tt := range tests {
+		p, v, ok := tbl.Lookup(MustParseAddr(tt.ip))
+		if !ok || p.String() != tt.want || v != tt.want {
+			t.Errorf("Lookup(%s) = %s, %q, %v, want %s", tt.ip, p, v, ok, tt.want)
+		}
+	}
+
+	tbl.Delete(MustParsePrefix("0.0.0.0/0"))
+	if p, _, ok := tbl.Lookup(MustParseAddr("11.0.0.1")); ok {
+		t.Errorf("Lookup(11.0.0.1) after delete = %s, want no match", p)
+	}
+	if _, ok := tbl.Get(MustParsePrefix("10.1.0.0/16")); !ok {
+		t.Error("Get(10.1.0.0/16) not found")
+	}
+	if _, ok := tbl.Get(MustParsePrefix("10.1.0.0/17")); ok {
+		t.Error("Get(10.1.0.0/17) found, want not found")
+	}
+}
+
+// entryPrefixes 表项的网段
+func entryPrefixes(entries []TableEntry[string]) []string {
+	res := make([]string, len(entries))
+	for i, e := range entries {
+		res[i] = e.Prefix.String()
+	}
+	return res
+}
+
+// TestTableSupernetsSubnets ...
+func TestTableSupernetsSubnets(t *testing.T) {
+	tbl := newTestTable(t, tablePrefixes...)
+	tests := []struct {
+		name      string
+		prefix    string
+		supernets []string
+		subnets   []string
+	}{
+		{
+			name:      "v4 middle",
+			prefix:    "10.1.0.0/16",
+			supernets: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"},
+			subnets:   []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32"},
+		},
+		{
+			name:      "v4 not in table",
+			prefix:    "10.1.2.128/25",
+			supernets: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+			subnets:   nil,
+		},
+		{
+			name:      "v4 covers several",
+			prefix:    "10.0.0.0/14",
+			supernets: []string{"0.0.0.0/0", "10.0.0.0/8"},
+			subnets:   []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "10.2.0.0/16"},
+		},
+		{
+			name:      "v6",
+			prefix:    "2001:db8::/32",
+			supernets: []string{"::/0", "2001:db8::/32"},
+			subnets:   []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1:2::/64"},
+		},
+	}
+	for _, tt := range tests {
+		p := MustParsePrefix(tt.prefix)
+		if got := entryPrefixes(tbl.Supernets(p)); !slices.Equal(got, tt.supernets) {
+			t.Errorf("%s: Supernets(%s) = %v, want %v", tt.name, p, got, tt.supernets)
+		}
+		if got := entryPrefixes(tbl.Subnets(p)); !slices.Equal(got, tt.subnets) {
+			t.Errorf("%s: Subnets(%s) = %v, want %v", tt.name, p, got, tt.subnets)
+		}
+	}
+}
+
+// TestTableWalkOrder 乱序插入后按地址、网段长度有序遍历
+func TestTableWalkOrder(t *testing.T) {
+	shuffled := slices.Clone(tablePrefixes)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	tbl := newTestTable(t, shuffled...)
+
+	want := make([]Prefix, len(tablePrefixes))
+	for i, s := range tablePrefixes {
+		want[i] = MustParsePrefix(s)
+	}
+	slices.SortFunc(want, Prefix.Compare)
+
+	entries := tbl.Entries()
+	if len(entries) != len(want) || tbl.Len() != len(want) {
+		t.Fatalf("Entries() len = %d, Len() = %d, want %d", len(entries), tbl.Len(), len(want))
+	}
+	for i, e := range entries {
+		if e.Prefix != want[i] {
+			t.Errorf("Entries()[%d] = %s, want %s", i, e.Prefix, want[i])
+		}
+	}
+
+	// 提前停止
+	n := 0
+	tbl.Walk(func(Prefix, string) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Walk stopped after %d entries, want 3", n)
+	}
+}
+
+// TestTableCopyOnWrite 遍历过程中修改表，遍历看到的仍是开始时的版本
+func TestTableCopyOnWrite(t *testing.T) {
+	tbl := newTestTable(t, tablePrefixes...)
+	before := entryPrefixes(tbl.Entries())
+
+	var seen []string
+	tbl.Walk(func(p Prefix, _ string) bool {
+		if len(seen) == 0 {
+			tbl.Delete(MustParsePrefix("10.1.0.0/16"))
+			tbl.Insert(MustParsePrefix("10.1.128.0/17"), "new")
+			tbl.Insert(MustParsePrefix("2001:db8:1:3::/64"), "new")
+			tbl.Insert(MustParsePrefix("10.1.2.3/32"), "changed")
+		}
+		seen = append(seen, p.String())
+		return true
+	})
+	if !slices.Equal(seen, before) {
+		t.Errorf("Walk during writes saw %v, want %v", seen, before)
+	}
+
+	if _, ok := tbl.Get(MustParsePrefix("10.1.0.0/16")); ok {
+		t.Error("deleted prefix still present after walk")
+	}
+	if v, _ := tbl.Get(MustParsePrefix("10.1.2.3/32")); v != "changed" {
+		t.Errorf("Get(10.1.2.3/32) = %q, want changed", v)
+	}
+	if tbl.Len() != len(tablePrefixes)+1 {
+		t.Errorf("Len() = %d, want %d", tbl.Len(), len(tablePrefixes)+1)
+	}
+}
+
+// TestTableConcurrent 写者不断插入、删除更长的网段，读者对固定网段的查询结果不受影响；用 -race 运行
+func TestTableConcurrent(t *testing.T) {
+	tbl := newTestTable(t, "10.0.0.0/8", "2001:db8::/32")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// 写者只改动 10.0.0.0-10.199.255.255 和 2001:db8::/32 内的更长网段
+				ip4 := AddrFrom4([4]byte{11, byte(rnd.Intn(256)), 0, 1})
+				if p, _, ok := tbl.Lookup(ip4); ok {
+					t.Errorf("Lookup(%s) = %s, want no match", ip4, p)
+					return
+				}
+				p, v, ok := tbl.Lookup(AddrFrom4([4]byte{10, 200, byte(rnd.Intn(256)), 1}))
+				if !ok || p.Bits() != 8 || v != "10.0.0.0/8" {
+					t.Errorf("Lookup(10.200.x.1) = %s, %q, %v", p, v, ok)
+					return
+				}
+				if entries := tbl.Entries(); !slices.IsSortedFunc(entries, func(a, b TableEntry[string]) int {
+					return a.Prefix.Compare(b.Prefix)
+				}) {
+					t.Error("Entries() not sorted")
+					return
+				}
+			}
+		}(int64(r))
+	}
+
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 2000; i++ {
+		p4 := PrefixFrom(AddrFrom4([4]byte{10, byte(rnd.Intn(200)), byte(rnd.Intn(256)), 0}), 16+rnd.Intn(17)).Masked()
+		a16 := MustParseAddr("2001:db8::").As16()
+		a16[4], a16[5] = byte(rnd.Intn(256)), byte(rnd.Intn(256))
+		p6 := PrefixFrom(AddrFrom16(a16), 48).Masked()
+		if i%3 == 2 {
+			tbl.Delete(p4)
+			tbl.Delete(p6)
+		} else {
+			tbl.Insert(p4, p4.String())
+			tbl.Insert(p6, p6.String())
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+const benchTableSize = 1 << 20
+
+var (
+	benchOnce    sync.Once
+	benchV4      []TableEntry[int]
+	benchV6      []TableEntry[int]
+	benchAddrsV4 []Addr
+	benchAddrsV6 []Addr
+)
+
+// benchData 1M 个随机网段和查询地址，IPv4 以 /24 为主，IPv6 以 /48 为主
+func benchData() {
+	benchOnce.Do(func() {
+		rnd := rand.New(rand.NewSource(1))
+		bits4 := []int{8, 12, 16, 20, 22, 24, 24, 24, 24, 28, 32}
+		bits6 := []int{24, 32, 40, 48, 48, 48, 56, 64, 64}
+		benchV4 = make([]TableEntry[int], benchTableSize)
+		benchV6 = make([]TableEntry[int], benchTableSize)
+		benchAddrsV4 = make([]Addr, benchTableSize)
+		benchAddrsV6 = make([]Addr, benchTableSize)
+		for i := 0; i < benchTableSize; i++ {
+			var a4 [4]byte
+			rnd.Read(a4[:])
+			benchV4[i] = TableEntry[int]{Prefix: PrefixFrom(AddrFrom4(a4), bits4[rnd.Intn(len(bits4))]).Masked(), Value: i}
+			rnd.Read(a4[:])
+			benchAddrsV4[i] = AddrFrom4(a4)
+
+			var a16 [16]byte
+			a16[0] = 0x20
+			rnd.Read(a16[1:8])
+			benchV6[i] = TableEntry[int]{Prefix: PrefixFrom(AddrFrom16(a16), bits6[rnd.Intn(len(bits6))]).Masked(), Value: i}
+			rnd.Read(a16[1:])
+			benchAddrsV6[i] = AddrFrom16(a16)
+		}
+	})
+}
+
+// benchmarkTableInsert 每次迭代插入 1M 个网段
+func benchmarkTableInsert(b *testing.B, entries []TableEntry[int]) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tbl := &Table[int]{}
+		for _, e := range entries {
+			tbl.Insert(e.Prefix, e.Value)
+		}
+	}
+}
+
+// benchmarkTableInsertAll 每次迭代批量插入 1M 个网段
+func benchmarkTableInsertAll(b *testing.B, entries []TableEntry[int]) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tbl := &Table[int]{}
+		tbl.InsertAll(entries)
+	}
+}
+
+// benchmarkTableLookup 在 1M 网段的表中查询
+func benchmarkTableLookup(b *testing.B, entries []TableEntry[int], addrs []Addr) {
+	tbl := &Table[int]{}
+	tbl.InsertAll(entries)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tbl.Lookup(addrs[i%len(addrs)])
+	}
+}
+
+// BenchmarkTableInsert1MV4 ...
+func BenchmarkTableInsert1MV4(b *testing.B) {
+	benchData()
+	benchmarkTableInsert(b, benchV4)
+}
+
+// BenchmarkTableInsert1MV6 ...
+func BenchmarkTableInsert1MV6(b *testing.B) {
+	benchData()
+	benchmarkTableInsert(b, benchV6)
+}
+
+// BenchmarkTableInsertAll1MV4 ...
+func BenchmarkTableInsertAll1MV4(b *testing.B) {
+	benchData()
+	benchmarkTableInsertAll(b, benchV4)
+}
+
+// BenchmarkTableInsertAll1MV6 ...
+func BenchmarkTableInsertAll1MV6(b *testing.B) {
+	benchData()
+	benchmarkTableInsertAll(b, benchV6)
+}
+
+// BenchmarkTableLookup1MV4 ...
+func BenchmarkTableLookup1MV4(b *testing.B) {
+	benchData()
+	benchmarkTableLookup(b, benchV4, benchAddrsV4)
+}
+
+// BenchmarkTableLookup1MV6 ...
+func BenchmarkTableLookup1MV6(b *testing.B) {
+	benchData()
+	benchmarkTableLookup(b, benchV6, benchAddrsV6)
+}
@@ -0,0 +1,135 @@
+package utip
+
+import "math/bits"
+
+// mask6 前 n 位为 1 的掩码
+func mask6(n int) uint128 {
+	return uint128{^(^uint64(0) >> n), ^uint64(0) << (128 - n)}
+}
+
+// isZero ...
+func (u uint128) isZero() bool { return u.hi|u.lo == 0 }
+
+// and ...
+func (u uint128) and(m uint128) uint128 {
+	return uint128{u.hi & m.hi, u.lo & m.lo}
+}
+
+// xor ...
+func (u uint128) xor(m uint128) uint128 {
+	return uint128{u.hi ^ m.hi, u.lo ^ m.lo}
+}
+
+// or ...
+func (u uint128) or(m uint128) uint128 {
+	return uint128{u.hi | m.hi, u.lo | m.lo}
+}
+
+// not ...
+func (u uint128) not() uint128 {
+	return uint128{^u.hi, ^u.lo}
+}
+
+// subOne ...
+func (u uint128) subOne() uint128 {
+	lo, borrow := bits.Sub64(u.lo, 1, 0)
+	return uint128{u.hi - borrow, lo}
+}
+
+// addOne ...
+func (u uint128) addOne() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{u.hi + carry, lo}
+}
+
+// add ...
+func (u uint128) add(v uint128) uint128 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	hi, _ := bits.Add64(u.hi, v.hi, carry)
+	return uint128{hi, lo}
+}
+
+// sub ...
+func (u uint128) sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	hi, _ := bits.Sub64(u.hi, v.hi, borrow)
+	return uint128{hi, lo}
+}
+
+// lsh 左移 n 位，n >= 128 时为 0
+func (u uint128) lsh(n uint) uint128 {
+	switch {
+	case n >= 128:
+		return uint128{}
+	case n >= 64:
+		return uint128{u.lo << (n - 64), 0}
+	case n == 0:
+		return u
+	default:
+		return uint128{u.hi<<n | u.lo>>(64-n), u.lo << n}
+	}
+}
+
+// rsh 右移 n 位，n >= 128 时为 0
+func (u uint128) rsh(n uint) uint128 {
+	switch {
+	case n >= 128:
+		return uint128{}
+	case n >= 64:
+		return uint128{0, u.hi >> (n - 64)}
+	case n == 0:
+		return u
+	default:
+		return uint128{u.hi >> n, u.lo>>n | u.hi<<(64-n)}
+	}
+}
+
+// cmp 比较大小，返回 -1、0、1
+func (u uint128) cmp(v uint128) int {
+	switch {
+	case u.hi < v.hi:
+		return -1
+	case u.hi > v.hi:
+		return 1
+	case u.lo < v.lo:
+		return -1
+	case u.lo > v.lo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// bitsSetFrom 第 bit 位及之后全部置 1
+func (u uint128) bitsSetFrom(bit uint8) uint128 {
+	return u.or(mask6(int(bit)).not())
+}
+
+// bitsClearedFrom 第 bit 位及之后全部置 0
+func (u uint128) bitsClearedFrom(bit uint8) uint128 {
+	return u.and(mask6(int(bit)))
+}
+
+// leadingZeros ...
+func (u uint128) leadingZeros() int {
+	if u.hi != 0 {
+		return bits.LeadingZeros64(u.hi)
+	}
+	return 64 + bits.LeadingZeros64(u.lo)
+}
+
+// trailingZeros ...
+func (u uint128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	return 64 + bits.TrailingZeros64(u.hi)
+}
+
+// bit 第 i 位（从最高位 0 开始）
+func (u uint128) bit(i int) uint8 {
+	if i < 64 {
+		return uint8(u.hi>>(63-i)) & 1
+	}
+	return uint8(u.lo>>(127-i)) & 1
+}
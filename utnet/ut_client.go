@@ -0,0 +1,332 @@
+package utnet
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TLSMode 客户端校验服务端证书的方式
+type TLSMode int
+
+const (
+	// TLSSystem 使用系统根证书
+	TLSSystem TLSMode = iota
+	// TLSCustomCA 使用指定的根证书
+	TLSCustomCA
+	// TLSMutual 使用指定的根证书，并提供客户端证书（双向认证）
+	TLSMutual
+	// TLSInsecure 不校验服务端证书，必须显式调用 WithInsecureSkipVerify
+	TLSInsecure
+)
+
+// String ...
+func (m TLSMode) String() string {
+	switch m {
+	case TLSSystem:
+		return "system"
+	case TLSCustomCA:
+		return "custom-ca"
+	case TLSMutual:
+		return "mtls"
+	case TLSInsecure:
+		return "insecure"
+	}
+	return fmt.Sprintf("TLSMode(%d)", int(m))
+}
+
+// ClientOptions ...
+// @Description: http.Client 的配置，NewClientOptions 给出与原有全局 client 相同的默认值，
+// 通过 With* 方法链式修改，Build 每次都返回独立的 client 和 Transport，互不影响
+type ClientOptions struct {
+	// 超时
+	Timeout               time.Duration // 整个请求的超时，0 表示不限制
+	DialTimeout           time.Duration // 0 表示不限制
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	ExpectContinueTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	// 连接池
+	MaxIdleConns        int // 0 表示不限制
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// 长连接
+	KeepAlive         time.Duration // TCP keepalive 间隔，0 使用 net.Dialer 的默认值，负数表示关闭
+	DisableKeepAlives bool          // 关闭 HTTP 长连接，每个请求新建连接
+
+	// TLS
+	TLSMode        TLSMode
+	RootCAFile     string
+	RootCAPEM      []byte
+	ClientCertFile string
+	ClientKeyFile  string
+	ClientCert     *tls.Certificate
+	ServerName     string
+	MinTLSVersion  uint16
+
+	// 代理和拨号
+	Proxy       func(*http.Request) (*url.URL, error) // nil 表示不使用代理
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewClientOptions 默认配置：10s 超时，每个 host 32 个空闲连接、最多 128 个连接，系统根证书，使用环境变量中的代理
+func NewClientOptions() *ClientOptions {
+	return &ClientOptions{
+		Timeout:               10 * time.Second,
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 5 * time.Second,
+		IdleConnTimeout:       30 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		MaxConnsPerHost:       128,
+		KeepAlive:             30 * time.Second,
+		TLSMode:               TLSSystem,
+		MinTLSVersion:         tls.VersionTLS12,
+		Proxy:                 http.ProxyFromEnvironment,
+	}
+}
+
+// WithTimeout 整个请求的超时
+func (o *ClientOptions) WithTimeout(d time.Duration) *ClientOptions {
+	o.Timeout = d
+	return o
+}
+
+// WithDialTimeout 建立 TCP 连接的超时
+func (o *ClientOptions) WithDialTimeout(d time.Duration) *ClientOptions {
+	o.DialTimeout = d
+	return o
+}
+
+// WithTLSHandshakeTimeout TLS 握手超时
+func (o *ClientOptions) WithTLSHandshakeTimeout(d time.Duration) *ClientOptions {
+	o.TLSHandshakeTimeout = d
+	return o
+}
+
+// WithResponseHeaderTimeout 发送完请求后等待响应头的超时
+func (o *ClientOptions) WithResponseHeaderTimeout(d time.Duration) *ClientOptions {
+	o.ResponseHeaderTimeout = d
+	return o
+}
+
+// WithExpectContinueTimeout 请求带 Expect: 100-continue 时等待服务端首个响应的时间，0 表示不等待直接发送 body
+func (o *ClientOptions) WithExpectContinueTimeout(d time.Duration) *ClientOptions {
+	o.ExpectContinueTimeout = d
+	return o
+}
+
+// WithPool 连接池大小
+func (o *ClientOptions) WithPool(maxIdleConns, maxIdleConnsPerHost, maxConnsPerHost int) *ClientOptions {
+	o.MaxIdleConns = maxIdleConns
+	o.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	o.MaxConnsPerHost = maxConnsPerHost
+	return o
+}
+
+// WithIdleConnTimeout 空闲连接保留时间
+func (o *ClientOptions) WithIdleConnTimeout(d time.Duration) *ClientOptions {
+	o.IdleConnTimeout = d
+	return o
+}
+
+// WithMinTLSVersion TLS 最低版本，如 tls.VersionTLS12，0 表示使用 crypto/tls 的默认值
+func (o *ClientOptions) WithMinTLSVersion(v uint16) *ClientOptions {
+	o.MinTLSVersion = v
+	return o
+}
+
+// WithKeepAlive TCP keepalive 间隔，负数表示关闭
+func (o *ClientOptions) WithKeepAlive(d time.Duration) *ClientOptions {
+	o.KeepAlive = d
+	return o
+}
+
+// WithDisableKeepAlives 关闭 HTTP 长连接
+func (o *ClientOptions) WithDisableKeepAlives(disable bool) *ClientOptions {
+	o.DisableKeepAlives = disable
+	return o
+}
+
+// WithSystemRoots 使用系统根证书校验服务端
+func (o *ClientOptions) WithSystemRoots() *ClientOptions {
+	o.TLSMode = TLSSystem
+	return o
+}
+
+// WithCustomCA 使用 PEM 文件中的根证书校验服务端
+func (o *ClientOptions) WithCustomCA(rootCertFile string) *ClientOptions {
+	o.TLSMode = TLSCustomCA
+	o.RootCAFile = rootCertFile
+	return o
+}
+
+// WithCustomCAPEM 使用 PEM 内容中的根证书校验服务端
+func (o *ClientOptions) WithCustomCAPEM(pem []byte) *ClientOptions {
+	o.TLSMode = TLSCustomCA
+	o.RootCAPEM = pem
+	return o
+}
+
+// WithMutualTLS 双向认证，rootCertFile 为空时使用系统根证书校验服务端
+func (o *ClientOptions) WithMutualTLS(clientCertFile, clientKeyFile, rootCertFile string) *ClientOptions {
+	o.TLSMode = TLSMutual
+	o.ClientCertFile = clientCertFile
+	o.ClientKeyFile = clientKeyFile
+	o.RootCAFile = rootCertFile
+	return o
+}
+
+// WithClientCertificate 双向认证，直接使用已加载的客户端证书
+func (o *ClientOptions) WithClientCertificate(cert tls.Certificate) *ClientOptions {
+	o.TLSMode = TLSMutual
+	o.ClientCert = &cert
+	return o
+}
+
+// WithServerName 校验证书时使用的服务端名字，默认取请求的 host
+func (o *ClientOptions) WithServerName(name string) *ClientOptions {
+	o.ServerName = name
+	return o
+}
+
+// WithInsecureSkipVerify 不校验服务端证书，只应在测试或内网自签证书且无法分发根证书时使用
+func (o *ClientOptions) WithInsecureSkipVerify() *ClientOptions {
+	o.TLSMode = TLSInsecure
+	return o
+}
+
+// WithProxy 固定使用代理，如 http://127.0.0.1:3128
+func (o *ClientOptions) WithProxy(proxyURL string) *ClientOptions {
+	u, err := url.Parse(proxyURL)
+	o.Proxy = func(*http.Request) (*url.URL, error) {
+		return u, err
+	}
+	return o
+}
+
+// WithProxyFunc 自定义代理选择，nil 表示不使用代理
+func (o *ClientOptions) WithProxyFunc(fn func(*http.Request) (*url.URL, error)) *ClientOptions {
+	o.Proxy = fn
+	return o
+}
+
+// WithDialContext 自定义拨号，如绑定网卡、走 WireGuard 通道，设置后 DialTimeout 和 KeepAlive 不再生效
+func (o *ClientOptions) WithDialContext(fn func(ctx context.Context, network, addr string) (net.Conn, error)) *ClientOptions {
+	o.DialContext = fn
+	return o
+}
+
+// TLSConfig ...
+// @Description: 按 TLSMode 生成 tls.Config，证书文件在这里读取
+// @receiver o
+// @return *tls.Config
+// @return error
+func (o *ClientOptions) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: o.ServerName,
+		MinVersion: o.MinTLSVersion,
+	}
+	switch o.TLSMode {
+	case TLSSystem:
+		return cfg, nil
+	case TLSInsecure:
+		cfg.InsecureSkipVerify = true
+		return cfg, nil
+	case TLSCustomCA, TLSMutual:
+	default:
+		return nil, fmt.Errorf("unknown tls mode %v", o.TLSMode)
+	}
+
+	pool, err := o.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil && o.TLSMode == TLSCustomCA {
+		return nil, errors.New("custom ca mode without root certificate")
+	}
+	cfg.RootCAs = pool
+
+	if o.TLSMode == TLSMutual {
+		switch {
+		case o.ClientCert != nil:
+			cfg.Certificates = []tls.Certificate{*o.ClientCert}
+		case o.ClientCertFile != "" && o.ClientKeyFile != "":
+			cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate: %w", err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		default:
+			return nil, errors.New("mtls mode without client certificate")
+		}
+	}
+	return cfg, nil
+}
+
+// rootCAs 读取根证书，没有配置时返回 nil
+func (o *ClientOptions) rootCAs() (*x509.CertPool, error) {
+	pemData := o.RootCAPEM
+	if len(pemData) == 0 && o.RootCAFile != "" {
+		var err error
+		pemData, err = os.ReadFile(o.RootCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca certificate: %w", err)
+		}
+	}
+	if len(pemData) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("no valid ca certificate in pem")
+	}
+	return pool, nil
+}
+
+// Transport 按配置生成新的 http.Transport
+func (o *ClientOptions) Transport() (*http.Transport, error) {
+	tlsConfig, err := o.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	dial := o.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   o.DialTimeout,
+			KeepAlive: o.KeepAlive,
+		}).DialContext
+	}
+	return &http.Transport{
+		Proxy:                 o.Proxy,
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          o.MaxIdleConns,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		TLSHandshakeTimeout:   o.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: o.ExpectContinueTimeout,
+		DisableKeepAlives:     o.DisableKeepAlives,
+	}, nil
+}
+
+// Build 生成新的 http.Client，每次调用都使用新的 Transport 和连接池
+func (o *ClientOptions) Build() (*http.Client, error) {
+	tr, err := o.Transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr, Timeout: o.Timeout}, nil
+}
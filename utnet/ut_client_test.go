@@ -0,0 +1,229 @@
+package utnet
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTLSServer 返回客户端证书个数的 https 服务，requireCert 时要求客户端提供证书
+func newTLSServer(t *testing.T, requireCert bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, strconv.Itoa(len(req.TLS.PeerCertificates)))
+	}))
+	srv.TLS = &tls.Config{}
+	// 握手失败是预期的，不输出日志
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if requireCert {
+		srv.TLS.ClientAuth = tls.RequireAnyClientCert
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serverPEM 服务端证书的 PEM，用作自定义根证书
+func serverPEM(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+// getBody 用 o 生成的 client 请求 url，返回响应体
+func getBody(o *ClientOptions, url string) (string, error) {
+	cli, err := o.Build()
+	if err != nil {
+		return "", err
+	}
+	defer cli.CloseIdleConnections()
+	resp, err := cli.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+// TestClientOptionsBuild 默认值与原有全局 client 一致，每次 Build 的 Transport 互相独立
+func TestClientOptionsBuild(t *testing.T) {
+	o := NewClientOptions()
+	cli, err := o.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := cli.Transport.(*http.Transport)
+	if cli.Timeout != 10*time.Second || tr.MaxIdleConnsPerHost != 32 || tr.MaxConnsPerHost != 128 ||
+		tr.IdleConnTimeout != 30*time.Second || tr.TLSClientConfig.InsecureSkipVerify ||
+		tr.TLSClientConfig.MinVersion != tls.VersionTLS12 || tr.DisableKeepAlives {
+		t.Errorf("default client: timeout %v, transport %+v", cli.Timeout, tr)
+	}
+
+	o.WithTimeout(time.Second).WithPool(1, 2, 3).WithDisableKeepAlives(true).
+		WithResponseHeaderTimeout(4 * time.Second).WithExpectContinueTimeout(0)
+	other, err := o.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otr := other.Transport.(*http.Transport)
+	if otr == tr || otr.TLSClientConfig == tr.TLSClientConfig {
+		t.Error("Build shares the transport or tls config")
+	}
+	if other.Timeout != time.Second || otr.MaxIdleConns != 1 || otr.MaxIdleConnsPerHost != 2 || otr.MaxConnsPerHost != 3 ||
+		!otr.DisableKeepAlives || otr.ResponseHeaderTimeout != 4*time.Second || otr.ExpectContinueTimeout != 0 {
+		t.Errorf("configured client: timeout %v, transport %+v", other.Timeout, otr)
+	}
+	if cli.Timeout != 10*time.Second || tr.MaxConnsPerHost != 128 || tr.DisableKeepAlives {
+		t.Error("changing options modified a client built earlier")
+	}
+}
+
+// TestClientOptionsProxyDial 固定代理、不使用代理和自定义拨号
+func TestClientOptionsProxyDial(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	tr, err := NewClientOptions().WithProxy("http://127.0.0.1:3128").Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := tr.Proxy(req); err != nil || u.String() != "http://127.0.0.1:3128" {
+		t.Errorf("WithProxy: %v, %v", u, err)
+	}
+	tr, _ = NewClientOptions().WithProxy("://bad").Transport()
+	if _, err = tr.Proxy(req); err == nil {
+		t.Error("WithProxy(invalid url): want error")
+	}
+	tr, _ = NewClientOptions().WithProxyFunc(nil).Transport()
+	if tr.Proxy != nil {
+		t.Error("WithProxyFunc(nil) still uses a proxy")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	var dials atomic.Int32
+	var d net.Dialer
+	o := NewClientOptions().WithProxyFunc(nil).WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	})
+	// 任何 host 都拨到测试服务
+	if body, err := getBody(o, "http://service.invalid/"); err != nil || body != "ok" || dials.Load() != 1 {
+		t.Errorf("WithDialContext: %q, %v, %d dials", body, err, dials.Load())
+	}
+}
+
+// TestClientOptionsTLS 各 TLSMode 能否连上自签证书的服务
+func TestClientOptionsTLS(t *testing.T) {
+	srv := newTLSServer(t, false)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, serverPEM(srv), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		o    *ClientOptions
+		ok   bool
+	}{
+		{"system", NewClientOptions(), false},
+		{"insecure", NewClientOptions().WithInsecureSkipVerify(), true},
+		{"custom ca pem", NewClientOptions().WithCustomCAPEM(serverPEM(srv)), true},
+		{"custom ca file", NewClientOptions().WithCustomCA(caFile), true},
+		{"wrong server name", NewClientOptions().WithCustomCA(caFile).WithServerName("other.test"), false},
+		{"insecure then system", NewClientOptions().WithInsecureSkipVerify().WithSystemRoots(), false},
+	}
+	for _, tt := range tests {
+		_, err := getBody(tt.o, srv.URL)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		var uae x509.UnknownAuthorityError
+		var hne x509.HostnameError
+		if err != nil && !errors.As(err, &uae) && !errors.As(err, &hne) {
+			t.Errorf("%s: err = %v, want certificate error", tt.name, err)
+		}
+	}
+}
+
+// TestClientOptionsMutualTLS 双向认证时发送客户端证书
+func TestClientOptionsMutualTLS(t *testing.T) {
+	srv := newTLSServer(t, true)
+	cert := srv.TLS.Certificates[0]
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		caFile:   serverPEM(srv),
+	} {
+		if err = os.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, o := range map[string]*ClientOptions{
+		"files":       NewClientOptions().WithMutualTLS(certFile, keyFile, caFile),
+		"certificate": NewClientOptions().WithCustomCAPEM(serverPEM(srv)).WithClientCertificate(cert),
+	} {
+		if body, err := getBody(o, srv.URL); err != nil || body != "1" {
+			t.Errorf("%s: %q, %v, want 1 peer certificate", name, body, err)
+		}
+	}
+	// 服务端要求证书，只信任服务端证书而不提供客户端证书时握手失败
+	if _, err = getBody(NewClientOptions().WithCustomCAPEM(serverPEM(srv)), srv.URL); err == nil {
+		t.Error("request without client certificate succeeded")
+	}
+}
+
+// TestClientOptionsTLSConfigError 缺少证书或证书无效时 Build 返回错误
+func TestClientOptionsTLSConfigError(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	tests := []struct {
+		name string
+		o    *ClientOptions
+		want string
+	}{
+		{"custom ca without cert", NewClientOptions().WithCustomCA(""), "without root certificate"},
+		{"custom ca missing file", NewClientOptions().WithCustomCA(missing), "read ca certificate"},
+		{"custom ca invalid pem", NewClientOptions().WithCustomCAPEM([]byte("not a certificate")), "no valid ca certificate"},
+		{"mtls without client cert", NewClientOptions().WithMutualTLS("", "", ""), "without client certificate"},
+		{"mtls key only", NewClientOptions().WithMutualTLS("", missing, ""), "without client certificate"},
+		{"mtls missing files", NewClientOptions().WithMutualTLS(missing, missing, ""), "load client certificate"},
+		{"unknown mode", &ClientOptions{TLSMode: TLSMode(9)}, "unknown tls mode TLSMode(9)"},
+	}
+	for _, tt := range tests {
+		cli, err := tt.o.Build()
+		if err == nil || !strings.Contains(err.Error(), tt.want) || cli != nil {
+			t.Errorf("%s: Build = %v, %v, want error containing %q", tt.name, cli, err, tt.want)
+		}
+	}
+
+	// 双向认证不指定根证书时使用系统根证书
+	cfg, err := NewClientOptions().WithClientCertificate(tls.Certificate{}).TLSConfig()
+	if err != nil || cfg.RootCAs != nil || len(cfg.Certificates) != 1 || cfg.InsecureSkipVerify {
+		t.Errorf("mtls with system roots: %+v, %v", cfg, err)
+	}
+	for _, m := range []TLSMode{TLSSystem, TLSCustomCA, TLSMutual, TLSInsecure} {
+		if strings.HasPrefix(m.String(), "TLSMode(") {
+			t.Errorf("TLSMode %d has no name", int(m))
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package utnet
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fangzw1120/utils/utip"
+)
+
+const (
+	// HeaderForwarded RFC 7239
+	HeaderForwarded = "Forwarded"
+	// HeaderXForwardedFor ...
+	HeaderXForwardedFor = "X-Forwarded-For"
+	// HeaderXRealIP ...
+	HeaderXRealIP = "X-Real-IP"
+)
+
+// defaultTrustedCIDRs ...
+// @Description: 默认只信任本机上的代理；内网中任何机器都能伪造转发头部，
+// 代理部署在其它机器上时由使用方通过 SetTrustedProxies 显式配置代理所在的网段，如
+// tp, err := NewTrustedProxies("127.0.0.0/8", "::1/128", "10.0.0.0/8"); SetTrustedProxies(tp)
+var defaultTrustedCIDRs = []string{
+	"127.0.0.0/8", "::1/128",
+}
+
+// trustedProxies SessionInfo.Parse 使用的代理配置
+var trustedProxies atomic.Pointer[TrustedProxies]
+
+func init() {
+	tp, err := NewTrustedProxies(defaultTrustedCIDRs...)
+	if err != nil {
+		panic(err)
+	}
+	trustedProxies.Store(tp)
+}
+
+// SetTrustedProxies 设置 SessionInfo.Parse 信任的代理，nil 表示不信任任何代理，只使用对端地址
+func SetTrustedProxies(tp *TrustedProxies) {
+	if tp == nil {
+		tp = &TrustedProxies{}
+	}
+	trustedProxies.Store(tp)
+}
+
+// GetTrustedProxies SessionInfo.Parse 当前使用的代理配置
+func GetTrustedProxies() *TrustedProxies {
+	return trustedProxies.Load()
+}
+
+// ClientAddr ...
+// @Description: 解析出的客户端地址，Port 为 0 表示来源中没有端口
+type ClientAddr struct {
+	Addr utip.Addr
+	Port uint16
+	// Source 地址的来源：RemoteAddr、Forwarded、X-Forwarded-For、X-Real-IP
+	Source string
+}
+
+// IsValid ...
+func (c ClientAddr) IsValid() bool {
+	return c.Addr.IsValid()
+}
+
+// String 有端口时为 ip:port，IPv6 为 [ip]:port
+func (c ClientAddr) String() string {
+	if !c.Addr.IsValid() {
+		return ""
+	}
+	if c.Port == 0 {
+		return c.Addr.String()
+	}
+	return net.JoinHostPort(c.Addr.String(), strconv.Itoa(int(c.Port)))
+}
+
+// TrustedProxies ...
+// @Description: 可信代理列表，构造后只读，并发安全。
+// 只有直接对端是可信代理时才读取转发头部，依次使用 Forwarded、X-Forwarded-For、X-Real-IP 中第一个存在的；
+// 转发链从右向左跳过可信代理，第一个不可信的地址即为客户端，全部可信时取最左边的地址；
+// 遇到无法解析的节点（如 unknown、混淆标识）时停止，使用它右侧的可信代理
+type TrustedProxies struct {
+	set *utip.IPSet
+}
+
+// NewTrustedProxies ...
+// @Description: cidrs 支持 utip.ParseRange 的所有写法，如 10.0.0.0/8、10.0.0.1-10.0.0.9、::1
+// @param cidrs
+// @return *TrustedProxies
+// @return error
+func NewTrustedProxies(cidrs ...string) (*TrustedProxies, error) {
+	var b utip.IPSetBuilder
+	for _, c := range cidrs {
+		r, err := utip.ParseRange(c)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", c, err)
+		}
+		b.AddRange(r)
+	}
+	return &TrustedProxies{set: b.IPSet()}, nil
+}
+
+// NewTrustedProxiesFromSet ...
+func NewTrustedProxiesFromSet(set *utip.IPSet) *TrustedProxies {
+	return &TrustedProxies{set: set}
+}
+
+// Set 可信代理的地址集合
+func (t *TrustedProxies) Set() *utip.IPSet {
+	return t.set
+}
+
+// Trusted ip 是否为可信代理，IPv4-mapped 地址按 IPv4 判断，忽略 zone
+func (t *TrustedProxies) Trusted(ip utip.Addr) bool {
+	if t == nil || !ip.IsValid() {
+		return false
+	}
+	return t.set.Contains(ip.Unmap().WithZone(""))
+}
+
+// ClientAddr ...
+// @Description: 取请求的真实客户端地址，RemoteAddr 无法解析时返回无效的 ClientAddr
+// @receiver t
+// @param req
+// @return ClientAddr
+func (t *TrustedProxies) ClientAddr(req *http.Request) ClientAddr {
+	peer, ok := parseNode(req.RemoteAddr)
+	if !ok {
+		return ClientAddr{}
+	}
+	peer.Source = "RemoteAddr"
+	if !t.Trusted(peer.Addr) {
+		return peer
+	}
+
+	var source string
+	var nodes []string
+	switch {
+	case len(req.Header.Values(HeaderForwarded)) > 0:
+		source, nodes = HeaderForwarded, forwardedFor(req.Header.Values(HeaderForwarded))
+	case len(req.Header.Values(HeaderXForwardedFor)) > 0:
+		source, nodes = HeaderXForwardedFor, splitList(req.Header.Values(HeaderXForwardedFor))
+	case req.Header.Get(HeaderXRealIP) != "":
+		source, nodes = HeaderXRealIP, []string{strings.TrimSpace(req.Header.Get(HeaderXRealIP))}
+	}
+
+	client := peer
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node, ok := parseNode(nodes[i])
+		if !ok {
+			break
+		}
+		node.Source = source
+		client = node
+		if !t.Trusted(node.Addr) {
+			break
+		}
+	}
+	return client
+}
+
+// splitList 合并多行头部并按逗号拆分
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			out = append(out, strings.TrimSpace(s))
+		}
+	}
+	return out
+}
+
+// parseNode 解析 ip、ip:port、[ipv6]、[ipv6]:port，Forwarded 的引号已去掉
+func parseNode(s string) (ClientAddr, bool) {
+	s = strings.TrimSpace(s)
+	host, portStr := s, ""
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return ClientAddr{}, false
+		}
+		host, portStr = s[1:end], s[end+1:]
+		if portStr != "" {
+			if portStr[0] != ':' {
+				return ClientAddr{}, false
+			}
+			portStr = portStr[1:]
+		}
+	} else if strings.Count(s, ":") == 1 {
+		host, portStr, _ = strings.Cut(s, ":")
+	}
+
+	ip, err := utip.ParseAddr(host)
+	if err != nil {
+		return ClientAddr{}, false
+	}
+	var port uint64
+	// Forwarded 中混淆过的端口（_ 开头）按没有端口处理
+	if portStr != "" && portStr[0] != '_' {
+		if port, err = strconv.ParseUint(portStr, 10, 16); err != nil {
+			return ClientAddr{}, false
+		}
+	}
+	return ClientAddr{Addr: ip.Unmap(), Port: uint16(port)}, true
+}
+
+// forwardedFor 按顺序取出 Forwarded 头部各节点的 for 参数，缺少 for 的节点记为空串
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, elem := range splitQuoted(v, ',') {
+			node := ""
+			for _, pair := range splitQuoted(elem, ';') {
+				key, value, ok := strings.Cut(pair, "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+					node = unquote(strings.TrimSpace(value))
+				}
+			}
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// splitQuoted 按 sep 拆分，忽略引号内的 sep
+func splitQuoted(s string, sep byte) []string {
+	var out []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// unquote 去掉 quoted-string 的引号和转义
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
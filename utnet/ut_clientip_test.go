@@ -0,0 +1,43 @@
+package utnet
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTrustedProxiesDefault 默认只信任本机代理，内网对端的转发头部不被采信
+func TestTrustedProxiesDefault(t *testing.T) {
+	tests := []struct {
+		remote, xff, want string
+	}{
+		{"127.0.0.1:1234", "203.0.113.7", "203.0.113.7"},
+		{"[::1]:1234", "203.0.113.7", "203.0.113.7"},
+		{"10.0.0.5:1234", "203.0.113.7", "10.0.0.5:1234"},
+		{"192.168.1.5:1234", "203.0.113.7", "192.168.1.5:1234"},
+		{"[fd00::5]:1234", "203.0.113.7", "[fd00::5]:1234"},
+		{"198.51.100.1:1234", "203.0.113.7", "198.51.100.1:1234"},
+	}
+	tp := GetTrustedProxies()
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		req.Header.Set(HeaderXForwardedFor, tt.xff)
+		if got := tp.ClientAddr(req).String(); got != tt.want {
+			t.Errorf("ClientAddr(remote %s, xff %s) = %s, want %s", tt.remote, tt.xff, got, tt.want)
+		}
+	}
+}
+
+// TestTrustedProxiesOptIn 显式配置私网代理后采信其转发头部
+func TestTrustedProxiesOptIn(t *testing.T) {
+	tp, err := NewTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	req.Header.Set(HeaderXForwardedFor, "198.51.100.9, 10.0.0.7")
+	if got := tp.ClientAddr(req).String(); got != "198.51.100.9" {
+		t.Errorf("ClientAddr = %s, want 198.51.100.9", got)
+	}
+}
@@ -0,0 +1,391 @@
+package utnet
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// EncodingGzip ...
+	EncodingGzip = "gzip"
+	// EncodingDeflate zlib 格式（RFC 1950），解码时兼容不带 zlib 头的原始 deflate
+	EncodingDeflate = "deflate"
+	// EncodingZstd 需要通过 RegisterCodec 注册实现
+	EncodingZstd = "zstd"
+	// EncodingBrotli 需要通过 RegisterCodec 注册实现
+	EncodingBrotli = "br"
+	// EncodingIdentity 不压缩
+	EncodingIdentity = "identity"
+)
+
+// ErrUnsupportedEncoding 内容编码没有注册，SessionMiddleware 返回 415
+var ErrUnsupportedEncoding = errors.New("utnet: unsupported content encoding")
+
+// Codec ...
+// @Description: 内容编码，NewWriter、NewReader 返回的对象 Close 后不能再使用，实现可以在 Close 时归还到池中；
+// Writer 实现 Flush() error 时，SessionMiddleware 的 Flush 会先刷出编码缓冲
+type Codec interface {
+	Name() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// CodecFuncs ...
+// @Description: 用函数实现 Codec，便于接入 zstd、brotli 等第三方库，例如
+// RegisterCodec(CodecFuncs{EncodingName: EncodingZstd, Writer: ..., Reader: ...})
+type CodecFuncs struct {
+	EncodingName string
+	Writer       func(w io.Writer) (io.WriteCloser, error)
+	Reader       func(r io.Reader) (io.ReadCloser, error)
+}
+
+// Name ...
+func (c CodecFuncs) Name() string { return c.EncodingName }
+
+// NewWriter ...
+func (c CodecFuncs) NewWriter(w io.Writer) (io.WriteCloser, error) { return c.Writer(w) }
+
+// NewReader ...
+func (c CodecFuncs) NewReader(r io.Reader) (io.ReadCloser, error) { return c.Reader(r) }
+
+// gzipCodec 使用 zippers、unZippers 池
+type gzipCodec struct{}
+
+// Name ...
+func (gzipCodec) Name() string { return EncodingGzip }
+
+// NewWriter ...
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return NewCompressWriter(w), nil
+}
+
+// NewReader ...
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return NewCompressReader(r)
+}
+
+// deflateWriters zlib writer 池
+var deflateWriters = sync.Pool{New: func() interface{} {
+	return zlib.NewWriter(nil)
+}}
+
+// deflateReaders、flateReaders 复用的解码器，池为空时新建
+var deflateReaders, flateReaders sync.Pool
+
+// deflateBufReaders 判断格式用的 bufio.Reader
+var deflateBufReaders = sync.Pool{New: func() interface{} {
+	return bufio.NewReader(nil)
+}}
+
+// putBufReader 归还 bufio.Reader，不再引用底层 reader
+func putBufReader(br *bufio.Reader) {
+	br.Reset(nil)
+	deflateBufReaders.Put(br)
+}
+
+// deflateWriter Close 时归还 zlib writer
+type deflateWriter struct {
+	*zlib.Writer
+}
+
+// Close ...
+func (w *deflateWriter) Close() error {
+	if w.Writer == nil {
+		return errors.New("deflate writer already closed")
+	}
+	err := w.Writer.Close()
+	deflateWriters.Put(w.Writer)
+	w.Writer = nil
+	return err
+}
+
+// deflateReader Close 时归还解码器和 bufio.Reader
+type deflateReader struct {
+	io.ReadCloser
+	pool *sync.Pool
+	br   *bufio.Reader
+}
+
+// Close ...
+func (r *deflateReader) Close() error {
+	if r.ReadCloser == nil {
+		return errors.New("deflate reader already closed")
+	}
+	err := r.ReadCloser.Close()
+	r.pool.Put(r.ReadCloser)
+	putBufReader(r.br)
+	r.ReadCloser, r.br = nil, nil
+	return err
+}
+
+// deflateCodec ...
+type deflateCodec struct{}
+
+// Name ...
+func (deflateCodec) Name() string { return EncodingDeflate }
+
+// NewWriter ...
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	zw := deflateWriters.Get().(*zlib.Writer)
+	zw.Reset(w)
+	return &deflateWriter{zw}, nil
+}
+
+// NewReader 根据前两个字节判断是 zlib 还是原始 deflate，空输入返回空数据
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := deflateBufReaders.Get().(*bufio.Reader)
+	br.Reset(r)
+	hdr, err := br.Peek(2)
+	if len(hdr) == 0 && err == io.EOF {
+		putBufReader(br)
+		return io.NopCloser(eofReader{}), nil
+	}
+	if len(hdr) == 2 && hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		if zr, ok := deflateReaders.Get().(io.ReadCloser); ok {
+			if err := zr.(zlib.Resetter).Reset(br, nil); err != nil {
+				deflateReaders.Put(zr)
+				putBufReader(br)
+				return nil, err
+			}
+			return &deflateReader{zr, &deflateReaders, br}, nil
+		}
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			putBufReader(br)
+			return nil, err
+		}
+		return &deflateReader{zr, &deflateReaders, br}, nil
+	}
+	if fr, ok := flateReaders.Get().(io.ReadCloser); ok {
+		if err := fr.(flate.Resetter).Reset(br, nil); err != nil {
+			flateReaders.Put(fr)
+			putBufReader(br)
+			return nil, err
+		}
+		return &deflateReader{fr, &flateReaders, br}, nil
+	}
+	return &deflateReader{flate.NewReader(br), &flateReaders, br}, nil
+}
+
+// GzipCodec 标准库 gzip，带池
+var GzipCodec Codec = gzipCodec{}
+
+// DeflateCodec 标准库 zlib，带池
+var DeflateCodec Codec = deflateCodec{}
+
+// CodecRegistry ...
+// @Description: 按名字查找内容编码，协商时按注册的优先级在客户端接受的编码中选择，并发安全
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs []Codec // 优先级从高到低
+}
+
+// NewCodecRegistry codecs 按优先级从高到低排列
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	r := &CodecRegistry{}
+	for i := len(codecs) - 1; i >= 0; i-- {
+		r.Register(codecs[i])
+	}
+	return r
+}
+
+// DefaultCodecs SessionInfo.Parse、SessionMiddleware 和 Do 使用的编码，默认 gzip 优先于 deflate
+var DefaultCodecs = NewCodecRegistry(GzipCodec, DeflateCodec)
+
+// RegisterCodec 在 DefaultCodecs 中注册 c，优先级最高
+func RegisterCodec(c Codec) {
+	DefaultCodecs.Register(c)
+}
+
+// Register 注册 c 并放在最高优先级，替换同名的编码
+func (r *CodecRegistry) Register(c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := strings.ToLower(c.Name())
+	r.codecs = slices.DeleteFunc(r.codecs, func(o Codec) bool { return strings.ToLower(o.Name()) == name })
+	r.codecs = append([]Codec{c}, r.codecs...)
+}
+
+// Unregister ...
+func (r *CodecRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = normalizeEncoding(name)
+	r.codecs = slices.DeleteFunc(r.codecs, func(o Codec) bool { return strings.ToLower(o.Name()) == name })
+}
+
+// Names 已注册的编码，按优先级从高到低
+func (r *CodecRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.codecs))
+	for i, c := range r.codecs {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Lookup 按名字查找，不区分大小写，x-gzip 等同于 gzip
+func (r *CodecRegistry) Lookup(name string) (Codec, bool) {
+	name = normalizeEncoding(name)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.codecs {
+		if strings.ToLower(c.Name()) == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// AcceptEncoding 可作为 Accept-EncodingEx 的值，按优先级列出所有已注册的编码，不带 q 值以兼容只认 gzip 的旧服务端
+func (r *CodecRegistry) AcceptEncoding() string {
+	return strings.Join(r.Names(), ", ")
+}
+
+// Negotiate ...
+// @Description: 按 Accept-Encoding 的语义选择编码：q 值最高的胜出，q 值相同时按注册的优先级；
+// q=0 表示不接受，* 匹配没有单独列出的编码；没有可用的编码时返回 false，表示不压缩
+// @receiver r
+// @param accept 头部的全部值，多行时依次传入
+// @return Codec
+// @return bool
+func (r *CodecRegistry) Negotiate(accept ...string) (Codec, bool) {
+	prefs := ParseAcceptEncoding(accept...)
+	if len(prefs) == 0 {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best Codec
+	bestQ := 0.0
+	for _, c := range r.codecs {
+		if q := prefs.Q(c.Name()); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != nil
+}
+
+// AcceptedEncoding Accept-Encoding 中的一项
+type AcceptedEncoding struct {
+	Name string // 小写
+	Q    float64
+}
+
+// AcceptEncodingList ParseAcceptEncoding 的结果
+type AcceptEncodingList []AcceptedEncoding
+
+// ParseAcceptEncoding 解析 Accept-Encoding 的值，如 "br;q=1.0, gzip;q=0.8, *;q=0.1"，忽略格式错误的项
+func ParseAcceptEncoding(values ...string) AcceptEncodingList {
+	var list AcceptEncodingList
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(item, ";")
+			name = normalizeEncoding(name)
+			if name == "" {
+				continue
+			}
+			q, ok := 1.0, true
+			for _, p := range strings.Split(params, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(p), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+					continue
+				}
+				f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || f < 0 || f > 1 {
+					ok = false
+					break
+				}
+				q = f
+			}
+			if ok {
+				list = append(list, AcceptedEncoding{Name: name, Q: q})
+			}
+		}
+	}
+	return list
+}
+
+// Q name 的 q 值，没有单独列出时使用 * 的 q 值，都没有时为 0
+func (l AcceptEncodingList) Q(name string) float64 {
+	name = normalizeEncoding(name)
+	q, wildcard := -1.0, -1.0
+	for _, e := range l {
+		switch e.Name {
+		case name:
+			q = max(q, e.Q)
+		case "*":
+			wildcard = max(wildcard, e.Q)
+		}
+	}
+	if q >= 0 {
+		return q
+	}
+	return max(wildcard, 0)
+}
+
+// Accepts name 是否可接受
+func (l AcceptEncodingList) Accepts(name string) bool {
+	return l.Q(name) > 0
+}
+
+// normalizeEncoding 小写，x-gzip 视为 gzip
+func normalizeEncoding(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "x-gzip" {
+		return EncodingGzip
+	}
+	return name
+}
+
+// contentEncodings Content-Encoding 类头部的编码列表，按施加的顺序，去掉 identity
+func contentEncodings(h http.Header, key string) []string {
+	var names []string
+	for _, v := range h.Values(key) {
+		for _, name := range strings.Split(v, ",") {
+			if name = normalizeEncoding(name); name != "" && name != EncodingIdentity {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// lookupEncodings 按头部取出编码，有未注册的编码时返回 ErrUnsupportedEncoding
+func (r *CodecRegistry) lookupEncodings(h http.Header, key string) ([]Codec, error) {
+	var codecs []Codec
+	for _, name := range contentEncodings(h, key) {
+		c, ok := r.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: %s", ErrUnsupportedEncoding, key, name)
+		}
+		codecs = append(codecs, c)
+	}
+	return codecs, nil
+}
+
+// decodeAll 按施加顺序的逆序依次解码，返回的 closers 用完后需要关闭
+func decodeAll(limits BodyLimits, r io.Reader, codecs []Codec) (io.Reader, []io.Closer, error) {
+	var closers []io.Closer
+	for i := len(codecs) - 1; i >= 0; i-- {
+		dr, closer, err := limits.Decode(codecs[i], r)
+		if err != nil {
+			for _, c := range closers {
+				_ = c.Close()
+			}
+			return nil, nil, err
+		}
+		r, closers = dr, append(closers, closer)
+	}
+	return r, closers, nil
+}
@@ -0,0 +1,80 @@
+package utnet
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDeflateReader zlib 和原始 deflate 都能解码，解码器和 bufio.Reader 复用后结果不变
+func TestDeflateReader(t *testing.T) {
+	plain := strings.Repeat("hello deflate ", 1000)
+	var zbuf, fbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	_, _ = zw.Write([]byte(plain))
+	_ = zw.Close()
+	fw, _ := flate.NewWriter(&fbuf, flate.DefaultCompression)
+	_, _ = fw.Write([]byte(plain))
+	_ = fw.Close()
+
+	for i := 0; i < 3; i++ {
+		for name, data := range map[string][]byte{"zlib": zbuf.Bytes(), "flate": fbuf.Bytes()} {
+			r, err := DeflateCodec.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != plain {
+				t.Errorf("%s: read %d bytes, err %v", name, len(got), err)
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("%s: close %v", name, err)
+			}
+			if err := r.Close(); err == nil {
+				t.Errorf("%s: second close: want error", name)
+			}
+		}
+	}
+
+	r, err := DeflateCodec.NewReader(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(r); err != nil || len(got) != 0 {
+		t.Errorf("empty input = %q, %v", got, err)
+	}
+}
+
+// TestSessionCompressNegotiation shouldCompress 只表示接受 gzip，协商出的编码由 ResponseEncoding 给出
+func TestSessionCompressNegotiation(t *testing.T) {
+	tests := []struct {
+		accept   string
+		gzip     bool
+		encoding string
+	}{
+		{"", false, ""},
+		{"gzip", true, EncodingGzip},
+		{"deflate", false, EncodingDeflate},
+		{"gzip;q=0.5, deflate", true, EncodingDeflate},
+		{"gzip;q=0, deflate;q=0", false, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+		if tt.accept != "" {
+			req.Header.Set(AcceptEncodingEx, tt.accept)
+		}
+		var si SessionInfo
+		if err := si.Parse(req); err != nil {
+			t.Fatal(err)
+		}
+		if si.GetShouldCompress() != tt.gzip || si.ResponseEncoding() != tt.encoding {
+			t.Errorf("accept %q: GetShouldCompress %v, ResponseEncoding %q, want %v, %q",
+				tt.accept, si.GetShouldCompress(), si.ResponseEncoding(), tt.gzip, tt.encoding)
+		}
+	}
+}
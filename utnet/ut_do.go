@@ -0,0 +1,342 @@
+package utnet
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rc4"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HeaderContentSeq 请求序号，参与 v1 加密的密钥
+	HeaderContentSeq = "Content-Seq"
+	// HeaderClientMid 客户端机器 id，参与 v1 加密的密钥
+	HeaderClientMid = "Client-Mid"
+	// HeaderContentEncrypt 加密方式，目前只有 v1
+	HeaderContentEncrypt = "Content-Encrypt"
+	// EncryptV1 rc4 加密
+	EncryptV1 = "v1"
+
+	// drainLimit 关闭响应前最多丢弃的剩余数据，读完才能复用连接
+	drainLimit = 64 << 10
+)
+
+// Request ...
+// @Description: Do 的请求，压缩和加密由头部决定，与 HTTPAPIRequest 的约定一致：
+// Content-EncodingEx 指定请求体的压缩方式，Accept-EncodingEx 为接受的压缩方式，响应按其 Content-EncodingEx 解压，
+// Content-Encrypt: v1 用 Client-Mid、Content-Seq 加密请求体并解密响应体，先压缩后加密
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   io.Reader // nil 表示没有请求体，任何方法都可以带请求体
+	// GetBody 返回新的请求体副本，重试时使用；为 nil 时只有 *bytes.Reader、*bytes.Buffer、*strings.Reader 类型的 Body 可以重试
+	GetBody func() (io.Reader, error)
+	// Limits 2xx 响应体的大小限制，nil 时使用 GetBodyLimits
+	Limits *BodyLimits
+}
+
+// NewRequest ...
+func NewRequest(method, url string, body io.Reader) *Request {
+	return &Request{Method: method, URL: url, Header: make(http.Header), Body: body}
+}
+
+// SetHeaders 批量设置头部，用于兼容 map[string]string 形式的头部
+func (r *Request) SetHeaders(headers map[string]string) *Request {
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	return r
+}
+
+// Response ...
+// @Description: Do 的响应，2xx 响应的 Body 已按请求头解密、解压，边读边处理；
+// 其他状态码的 Body 为原始内容，可用 CheckStatus 转为 *HTTPError；用完必须 Close，连接才能复用
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       io.ReadCloser
+	URL        string
+}
+
+// Close 丢弃未读完的数据并关闭
+func (r *Response) Close() error {
+	if r == nil || r.Body == nil {
+		return nil
+	}
+	return r.Body.Close()
+}
+
+// ReadAll 读取全部 Body 并关闭
+func (r *Response) ReadAll() ([]byte, error) {
+	defer r.Close()
+	return io.ReadAll(r.Body)
+}
+
+// readCloser 组合 Reader 和 Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseBody 解密、解压后的响应体，关闭时归还解码器，并读完原始 Body 以复用连接
+type responseBody struct {
+	io.Reader
+	decoders []io.Closer
+	raw      io.ReadCloser
+}
+
+// Close ...
+func (b *responseBody) Close() error {
+	for _, d := range b.decoders {
+		_ = d.Close()
+	}
+	b.decoders = nil
+	_, _ = io.CopyN(io.Discard, b.raw, drainLimit)
+	return b.raw.Close()
+}
+
+// protocolCipherV1 v1 加密使用的 rc4，密钥由 mid 和 seqid 生成
+func protocolCipherV1(mid string, seqid uint32) (*rc4.Cipher, error) {
+	keyData := []byte(mid + "FC149CE9B1414613AB6D6C481D95293A")
+	for i := 0; i < len(keyData); i++ {
+		keyData[i] ^= byte(seqid)
+	}
+	return rc4.NewCipher(keyData)
+}
+
+// encryptCipher 请求头要求 v1 加密时返回 rc4，每次调用都是新的密钥流
+func encryptCipher(h http.Header) (*rc4.Cipher, error) {
+	if h.Get(HeaderContentEncrypt) != EncryptV1 {
+		return nil, nil
+	}
+	seqid, _ := strconv.ParseUint(h.Get(HeaderContentSeq), 10, 0)
+	return protocolCipherV1(h.Get(HeaderClientMid), uint32(seqid))
+}
+
+// hasGzip 头部值中是否包含 gzip
+func hasGzip(h http.Header, key string) bool {
+	for _, v := range h.Values(key) {
+		for _, enc := range strings.Split(v, ",") {
+			if strings.TrimSpace(enc) == "gzip" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestCodec Content-EncodingEx 指定的请求体压缩方式，只支持一种
+func requestCodec(h http.Header) (Codec, error) {
+	codecs, err := DefaultCodecs.lookupEncodings(h, ContentEncodingEx)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(codecs) > 1:
+		return nil, fmt.Errorf("%w: %s: only one encoding is supported", ErrUnsupportedEncoding, ContentEncodingEx)
+	case len(codecs) == 1:
+		return codecs[0], nil
+	}
+	return nil, nil
+}
+
+// encodeBody 请求体依次压缩、加密，返回的 reader 由 Transport 关闭，length 为 -1 表示未知
+func encodeBody(body io.Reader, codec Codec, c *rc4.Cipher) (io.ReadCloser, int64) {
+	length := int64(-1)
+	if l, ok := body.(interface{ Len() int }); ok {
+		length = int64(l.Len())
+	}
+	rc := readCloser{Reader: body, Closer: io.NopCloser(body)}
+	if closer, ok := body.(io.Closer); ok {
+		rc.Closer = closer
+	}
+
+	if codec != nil {
+		pr, pw := io.Pipe()
+		src := rc
+		go func() {
+			zw, err := codec.NewWriter(pw)
+			if err == nil {
+				_, err = io.Copy(zw, src)
+				if cerr := zw.Close(); err == nil {
+					err = cerr
+				}
+			}
+			_ = src.Close()
+			_ = pw.CloseWithError(err)
+		}()
+		rc = readCloser{Reader: pr, Closer: pr}
+		length = -1
+	}
+	if c != nil {
+		rc.Reader = cipher.StreamReader{S: c, R: rc.Reader}
+	}
+	return rc, length
+}
+
+// Do ...
+// @Description: 带 context 的流式请求，请求体和响应体都不整体读入内存，连接可复用
+// @param ctx 取消或超时会中断请求和读取响应体
+// @param cli nil 时使用默认的 httpClient
+// @param r
+// @return *Response 调用方负责 Close
+// @return error
+func Do(ctx context.Context, cli *http.Client, r *Request) (*Response, error) {
+	if cli == nil {
+		cli = httpClient
+	}
+	header := r.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	c, err := encryptCipher(header)
+	if err != nil {
+		return nil, fmt.Errorf("init encrypt, Error : %w: %w", ErrDecrypt, err)
+	}
+	codec, err := requestCodec(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	length := int64(0)
+	if r.Body != nil {
+		body, length = encodeBody(r.Body, codec, c)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
+	if err != nil {
+		if body != nil {
+			_ = body.Close()
+		}
+		return nil, fmt.Errorf("http NewRequest , Error : %s", err.Error())
+	}
+	req.Header = header.Clone()
+	if body != nil {
+		req.ContentLength = length
+		if length == 0 {
+			// 空请求体，与 http.NewRequest 对 bytes.Reader 的处理一致
+			_ = body.Close()
+			req.Body = http.NoBody
+		}
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Get Do, Error : %w", transportError(err))
+	}
+	limits := GetBodyLimits()
+	if r.Limits != nil {
+		limits = *r.Limits
+	}
+	return decodeResponse(resp, r.Method, r.URL, header, limits)
+}
+
+// decodeResponse 2xx 响应按请求头解密，按响应的 Content-EncodingEx 解压，读取和解压时检查 limits；
+// 响应没有 Content-EncodingEx 而请求接受 gzip 时，与旧版本一样按 gzip 解压
+func decodeResponse(resp *http.Response, method string, url string, reqHeader http.Header, limits BodyLimits) (*Response, error) {
+	res := &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		URL:        url,
+	}
+	rb := &responseBody{Reader: transportReader{resp.Body}, raw: resp.Body}
+	res.Body = rb
+	if !encodedResponse(resp.StatusCode) || !bodyAllowed(method, resp.StatusCode) {
+		return res, nil
+	}
+
+	if err := limits.CheckContentLength(resp.ContentLength); err != nil {
+		_ = rb.Close()
+		return nil, err
+	}
+	rb.Reader = limits.Raw(rb.Reader)
+	// 每个请求都重新生成密钥流，与加密请求体的那个互不影响
+	c, err := encryptCipher(reqHeader)
+	if err != nil {
+		_ = rb.Close()
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	if c != nil {
+		rb.Reader = cipher.StreamReader{S: c, R: rb.Reader}
+	}
+	codecs, err := DefaultCodecs.lookupEncodings(resp.Header, ContentEncodingEx)
+	if err != nil {
+		_ = rb.Close()
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	if len(codecs) == 0 && resp.Header.Get(ContentEncodingEx) == "" &&
+		ParseAcceptEncoding(reqHeader.Values(AcceptEncodingEx)...).Accepts(EncodingGzip) {
+		codecs = []Codec{GzipCodec}
+	}
+	if len(codecs) > 0 {
+		r, decoders, err := decodeAll(limits, rb.Reader, codecs)
+		if err != nil {
+			_ = rb.Close()
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		rb.decoders = decoders
+		rb.Reader = decodeReader{r}
+	}
+	return res, nil
+}
+
+// doBytes HTTPAPIRequest 系列的公共实现，非 200 返回 *HTTPError
+func doBytes(ctx context.Context, cli *http.Client, r *Request) ([]byte, error) {
+	resp, err := Do(ctx, cli, r)
+	return readOK(r, resp, err)
+}
+
+// readOK 读取 200 响应的全部内容
+func readOK(r *Request, resp *Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(r.Method, resp)
+	}
+	body, err := resp.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read body Error : %w", err)
+	}
+	return body, nil
+}
+
+// legacyRequest 把 HTTPAPIRequest 的参数转为 Request，有数据时任何方法都带请求体
+func legacyRequest(reqMode, url string, headers map[string]string, data []byte) *Request {
+	r := NewRequest(reqMode, url, nil).SetHeaders(headers)
+	if len(data) > 0 || reqMode == http.MethodPost || reqMode == http.MethodPut || reqMode == http.MethodPatch {
+		r.Body = bytes.NewReader(data)
+		r.GetBody = func() (io.Reader, error) {
+			return bytes.NewReader(data), nil
+		}
+	}
+	return r
+}
+
+// HTTPAPIRequestContext 带 context 的 HTTPAPIRequestV1，cli 为 nil 时使用默认 client
+func HTTPAPIRequestContext(ctx context.Context, cli *http.Client, reqMode string, url string, headers map[string]string, data []byte) ([]byte, error) {
+	if ctx == nil {
+		return nil, errors.New("nil context")
+	}
+	return doBytes(ctx, cli, legacyRequestV1(reqMode, url, headers, data))
+}
+
+// legacyRequestV1 HTTPAPIRequestV1 默认带 json 的 Content-Type
+func legacyRequestV1(reqMode, url string, headers map[string]string, data []byte) *Request {
+	r := legacyRequest(reqMode, url, headers, data)
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json;charset=UTF-8")
+	}
+	return r
+}
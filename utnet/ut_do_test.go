@@ -0,0 +1,239 @@
+package utnet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countConns 统计服务端新建的连接数
+func countConns(srv *httptest.Server) *atomic.Int32 {
+	var n atomic.Int32
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			n.Add(1)
+		}
+	}
+	return &n
+}
+
+// TestDoMethodsWithBody 任何方法都可以带请求体，没有请求体时 Content-Length 为 0
+func TestDoMethodsWithBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		_, _ = io.WriteString(w, req.Method+" "+strconv.FormatInt(req.ContentLength, 10)+" "+string(body))
+	}))
+	defer srv.Close()
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		resp, err := Do(context.Background(), nil, NewRequest(method, srv.URL, strings.NewReader("data")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := method + " 4 data"
+		if body, err := resp.ReadAll(); err != nil || string(body) != want {
+			t.Errorf("Do %s = %q, %v, want %q", method, body, err, want)
+		}
+		if body, err := HTTPAPIRequest(method, srv.URL, nil, []byte("data")); err != nil || string(body) != want {
+			t.Errorf("HTTPAPIRequest %s = %q, %v, want %q", method, body, err, want)
+		}
+	}
+	resp, err := Do(context.Background(), nil, NewRequest(http.MethodGet, srv.URL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.ReadAll(); string(body) != "GET 0 " {
+		t.Errorf("Do GET = %q", body)
+	}
+	// 空请求体的 PUT 仍然发送 Content-Length: 0
+	if body, err := HTTPAPIRequest(http.MethodPut, srv.URL, nil, nil); err != nil || string(body) != "PUT 0 " {
+		t.Errorf("HTTPAPIRequest PUT without data = %q, %v", body, err)
+	}
+}
+
+// TestDoStreamRequest 请求体边读边发，服务端读到前一段后客户端才写下一段
+func TestDoStreamRequest(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf := make([]byte, 5)
+		_, _ = io.ReadFull(req.Body, buf)
+		got <- string(buf)
+		rest, _ := io.ReadAll(req.Body)
+		_, _ = io.WriteString(w, string(buf)+string(rest))
+	}))
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, "part1")
+		<-got
+		_, _ = io.WriteString(pw, "part2")
+		_ = pw.Close()
+	}()
+	resp, err := Do(context.Background(), nil, NewRequest(http.MethodPut, srv.URL, pr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := resp.ReadAll(); err != nil || string(body) != "part1part2" {
+		t.Errorf("body = %q, %v", body, err)
+	}
+}
+
+// TestDoStreamEncoded 长度未知的请求体经压缩、加密后发送，响应解密、解压后与原文一致
+func TestDoStreamEncoded(t *testing.T) {
+	srv := httptest.NewServer(SessionMiddleware(echoHandler()))
+	defer srv.Close()
+	// 随机数据，避免触发压缩比限制
+	data := make([]byte, 1<<20)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+
+	for _, enc := range []string{"", EncodingGzip, EncodingDeflate} {
+		for _, encrypt := range []bool{false, true} {
+			name := enc + "/encrypt=" + strconv.FormatBool(encrypt)
+			pr, pw := io.Pipe()
+			go func() {
+				// 分多次写入
+				for i := 0; i < len(data); i += 4096 {
+					_, _ = pw.Write(data[i : i+4096])
+				}
+				_ = pw.Close()
+			}()
+			r := NewRequest(http.MethodPost, srv.URL, pr).SetHeaders(sessionHeaders(enc, encrypt))
+			resp, err := Do(context.Background(), nil, r)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			body, err := resp.ReadAll()
+			if err != nil || !bytes.Equal(body, append([]byte("echo:"), data...)) {
+				t.Errorf("%s: %d bytes, %v", name, len(body), err)
+			}
+		}
+	}
+}
+
+// TestDoGzipFallback 响应没有 Content-EncodingEx 而请求接受 gzip 时按 gzip 解压，与旧版本一致
+func TestDoGzipFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		zw := gzip.NewWriter(w)
+		_, _ = io.WriteString(zw, "legacy")
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	r := NewRequest(http.MethodGet, srv.URL, nil).SetHeaders(map[string]string{AcceptEncodingEx: EncodingGzip})
+	resp, err := Do(context.Background(), nil, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := resp.ReadAll(); err != nil || string(body) != "legacy" {
+		t.Errorf("body = %q, %v", body, err)
+	}
+
+	// 不接受 gzip 时原样返回
+	resp, err = Do(context.Background(), nil, NewRequest(http.MethodGet, srv.URL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.ReadAll(); bytes.Equal(body, []byte("legacy")) {
+		t.Error("response decoded without Accept-EncodingEx")
+	}
+}
+
+// TestDoKeepAlive 读完或未读完就 Close 的响应都不影响连接复用
+func TestDoKeepAlive(t *testing.T) {
+	srv := httptest.NewUnstartedServer(SessionMiddleware(echoHandler()))
+	conns := countConns(srv)
+	srv.Start()
+	defer srv.Close()
+	cli, err := NewClientOptions().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		r := NewRequest(http.MethodPost, srv.URL, strings.NewReader("x")).SetHeaders(sessionHeaders(EncodingGzip, true))
+		resp, err := Do(context.Background(), cli, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			_, err = resp.ReadAll()
+		} else {
+			err = resp.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = HTTPAPIRequestContext(context.Background(), cli, http.MethodPost, srv.URL, nil, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+}
+
+// TestDoContext 等待响应头和读取响应体时都能被 context 中断
+func TestDoContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			<-release
+			return
+		}
+		_, _ = io.WriteString(w, "part1")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	// 先放行 handler，srv.Close 才不会一直等待
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Do(ctx, nil, NewRequest(http.MethodGet, srv.URL+"/slow", nil))
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline before headers: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = HTTPAPIRequestContext(ctx, nil, http.MethodGet, srv.URL+"/slow", nil, nil)
+	if !errors.Is(err, ErrTransport) || !errors.Is(err, context.Canceled) {
+		t.Errorf("cancel before headers: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	resp, err := Do(ctx, nil, NewRequest(http.MethodGet, srv.URL+"/stream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(resp.Body, buf); err != nil || string(buf) != "part1" {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+	cancel()
+	if _, err = io.ReadAll(resp.Body); !errors.Is(err, ErrTransport) || !errors.Is(err, context.Canceled) {
+		t.Errorf("cancel while reading body: %v", err)
+	}
+
+	if _, err = HTTPAPIRequestContext(nil, nil, http.MethodGet, srv.URL, nil, nil); err == nil {
+		t.Error("nil context: want error")
+	}
+}
@@ -0,0 +1,134 @@
+package utnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrTransport 连接、发送请求或读取响应时的网络错误
+	ErrTransport = errors.New("utnet: transport error")
+	// ErrTimeout 请求超时，包括 context 超时和连接超时
+	ErrTimeout = errors.New("utnet: timeout")
+	// ErrDecode 响应体解压失败
+	ErrDecode = errors.New("utnet: decode response failed")
+	// ErrDecrypt 加解密失败
+	ErrDecrypt = errors.New("utnet: decrypt failed")
+)
+
+const (
+	// maxErrorBody HTTPError 最多保留的响应体长度
+	maxErrorBody = 4 << 10
+	// maxErrorBodyInMessage Error() 中最多展示的响应体长度
+	maxErrorBodyInMessage = 256
+)
+
+// HTTPError ...
+// @Description: 状态码非 2xx 的响应，保留状态码、头部和截断后的响应体，可用 errors.As 取出
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte // 原始响应体，最多 maxErrorBody 字节
+	Truncated  bool   // 响应体是否被截断
+}
+
+// Error 保持原有的 "Request Url : %s, Error Status : %d" 格式，后面附上响应体
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("Request Url : %s, Error Status : %d", e.URL, e.StatusCode)
+	if len(e.Body) == 0 {
+		return msg
+	}
+	body := e.Body
+	if len(body) > maxErrorBodyInMessage {
+		body = body[:maxErrorBodyInMessage]
+	}
+	return fmt.Sprintf("%s, Body : %q", msg, body)
+}
+
+// newHTTPError 读取截断后的响应体并关闭响应
+func newHTTPError(method string, resp *Response) *HTTPError {
+	e := &HTTPError{
+		Method:     method,
+		URL:        resp.URL,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	if len(body) > maxErrorBody {
+		body, e.Truncated = body[:maxErrorBody], true
+	}
+	e.Body = body
+	_ = resp.Close()
+	return e
+}
+
+// CheckStatus 状态码非 2xx 时读取响应体、关闭响应并返回 *HTTPError，否则返回 nil
+func CheckStatus(method string, resp *Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	return newHTTPError(method, resp)
+}
+
+// StatusCode 错误链中 *HTTPError 的状态码，没有时返回 0
+func StatusCode(err error) int {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.StatusCode
+	}
+	return 0
+}
+
+// transportError 给网络错误加上 ErrTimeout 或 ErrTransport，已分类的错误不重复包装
+func transportError(err error) error {
+	if err == nil || isClassified(err) {
+		return err
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrTransport, err)
+}
+
+// isClassified 是否已经带有本包的错误类型
+func isClassified(err error) bool {
+	return errors.Is(err, ErrTransport) || errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrDecode) || errors.Is(err, ErrDecrypt) || errors.Is(err, ErrBodyTooLarge)
+}
+
+// transportReader 原始响应体的读取错误标记为网络错误，以便与解压错误区分
+type transportReader struct {
+	r io.Reader
+}
+
+// Read ...
+func (t transportReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = transportError(err)
+	}
+	return n, err
+}
+
+// decodeReader 解压过程中未分类的错误标记为 ErrDecode
+type decodeReader struct {
+	r io.Reader
+}
+
+// Read ...
+func (d decodeReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF && !isClassified(err) {
+		err = fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return n, err
+}
@@ -0,0 +1,158 @@
+package utnet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHTTPError 非 2xx 的状态码、头部、响应体都保留在 *HTTPError 中
+func TestHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/"))
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, errorJSON)
+	}))
+	defer srv.Close()
+
+	for _, code := range []int{http.StatusNotFound, http.StatusServiceUnavailable, http.StatusNoContent, http.StatusFound} {
+		url := srv.URL + "/" + strconv.Itoa(code)
+		_, err := HTTPAPIRequest(http.MethodDelete, url, nil, nil)
+		var he *HTTPError
+		if !errors.As(fmt.Errorf("wrapped: %w", err), &he) {
+			t.Fatalf("%d: err = %v, want *HTTPError", code, err)
+		}
+		wantBody := errorJSON
+		if code == http.StatusNoContent {
+			wantBody = ""
+		}
+		if he.StatusCode != code || he.Method != http.MethodDelete || he.URL != url || string(he.Body) != wantBody ||
+			he.Truncated || he.Header.Get("Retry-After") != "3" || StatusCode(err) != code {
+			t.Errorf("%d: HTTPError = %+v", code, he)
+		}
+		want := fmt.Sprintf("Request Url : %s, Error Status : %d", url, code)
+		if wantBody != "" {
+			want += fmt.Sprintf(", Body : %q", wantBody)
+		}
+		if err.Error() != want {
+			t.Errorf("%d: Error() = %s, want %s", code, err, want)
+		}
+		if isClassified(err) {
+			t.Errorf("%d: HTTPError classified as a transport or decode error", code)
+		}
+	}
+	if StatusCode(nil) != 0 || StatusCode(ErrTransport) != 0 {
+		t.Error("StatusCode without HTTPError != 0")
+	}
+}
+
+// TestHTTPErrorTruncated 长响应体截断为 maxErrorBody，Error() 中只展示前 maxErrorBodyInMessage 字节
+func TestHTTPErrorTruncated(t *testing.T) {
+	for _, n := range []int{maxErrorBody, maxErrorBody + 1, 1 << 20} {
+		body := bytes.Repeat([]byte("x"), n)
+		resp := &Response{
+			StatusCode: http.StatusInternalServerError,
+			Header:     http.Header{},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			URL:        "http://example.com/",
+		}
+		err := CheckStatus(http.MethodGet, resp)
+		var he *HTTPError
+		if !errors.As(err, &he) {
+			t.Fatalf("%d: CheckStatus = %v", n, err)
+		}
+		if len(he.Body) != maxErrorBody || he.Truncated != (n > maxErrorBody) {
+			t.Errorf("%d: Body %d bytes, Truncated %v", n, len(he.Body), he.Truncated)
+		}
+		if want := strconv.Quote(strings.Repeat("x", maxErrorBodyInMessage)); !strings.HasSuffix(err.Error(), "Body : "+want) {
+			t.Errorf("%d: Error() = %.100s...", n, err)
+		}
+	}
+
+	for _, code := range []int{http.StatusOK, http.StatusNoContent, 299} {
+		if err := CheckStatus(http.MethodGet, &Response{StatusCode: code}); err != nil {
+			t.Errorf("CheckStatus(%d) = %v", code, err)
+		}
+	}
+}
+
+// TestErrorKinds 网络、超时、解压、解密错误分别对应各自的 sentinel，且互不混淆
+func TestErrorKinds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/garbage":
+			w.Header().Set(ContentEncodingEx, EncodingGzip)
+			_, _ = io.WriteString(w, "not gzip data")
+		case "/truncated":
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			_, _ = io.WriteString(zw, strings.Repeat("truncated ", 100))
+			_ = zw.Close()
+			w.Header().Set(ContentEncodingEx, EncodingGzip)
+			_, _ = w.Write(buf.Bytes()[:buf.Len()/2])
+		case "/unknown":
+			w.Header().Set(ContentEncodingEx, "br")
+			_, _ = io.WriteString(w, "x")
+		}
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cli, err := NewClientOptions().WithTimeout(50 * time.Millisecond).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := []error{ErrTransport, ErrTimeout, ErrDecode, ErrDecrypt, ErrBodyTooLarge}
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		limits  *BodyLimits
+		want    error
+	}{
+		{"connection refused", closed.URL, nil, nil, ErrTransport},
+		{"client timeout", srv.URL + "/slow", nil, nil, ErrTimeout},
+		{"invalid gzip", srv.URL + "/garbage", nil, nil, ErrDecode},
+		{"truncated gzip", srv.URL + "/truncated", nil, nil, ErrDecode},
+		{"unknown encoding", srv.URL + "/unknown", nil, nil, ErrDecode},
+		{"encrypt key", srv.URL, map[string]string{HeaderContentEncrypt: EncryptV1, HeaderClientMid: strings.Repeat("m", 300)}, nil, ErrDecrypt},
+		{"body limit", srv.URL + "/truncated", nil, &BodyLimits{MaxRawBytes: 8}, ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		r := NewRequest(http.MethodGet, tt.url, nil).SetHeaders(tt.headers)
+		r.Limits = tt.limits
+		resp, err := Do(context.Background(), cli, r)
+		if err == nil {
+			_, err = resp.ReadAll()
+		}
+		for _, kind := range all {
+			if errors.Is(err, kind) != (kind == tt.want) {
+				t.Errorf("%s: err = %v, errors.Is(%v) = %v", tt.name, err, kind, errors.Is(err, kind))
+			}
+		}
+		if StatusCode(err) != 0 {
+			t.Errorf("%s: StatusCode = %d", tt.name, StatusCode(err))
+		}
+	}
+
+	// 已分类的错误不重复包装
+	if err := transportError(fmt.Errorf("%w: x", ErrDecode)); errors.Is(err, ErrTransport) {
+		t.Errorf("transportError wrapped a decode error: %v", err)
+	}
+	if err := transportError(context.DeadlineExceeded); !errors.Is(err, ErrTimeout) {
+		t.Errorf("transportError(DeadlineExceeded) = %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package utnet
 import (
 	"bytes"
 	"compress/gzip"
-	"crypto/rc4"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -97,9 +96,14 @@ func (gr *CompressReader) Close() error {
 
 // SetCompressHeader ...
 func SetCompressHeader(rspWriter http.ResponseWriter) {
-	rspWriter.Header().Set(ContentEncodingEx, "gzip")
-	rspWriter.Header().Add(vary, AcceptEncodingEx)
-	rspWriter.Header().Del(ContentLength)
+	setEncodingHeader(rspWriter.Header(), EncodingGzip)
+}
+
+// setEncodingHeader 响应体用 name 压缩时的头部
+func setEncodingHeader(h http.Header, name string) {
+	h.Set(ContentEncodingEx, name)
+	addVary(h, AcceptEncodingEx)
+	h.Del(ContentLength)
 }
 
 // ShouldUnCompressForOfficial ...
@@ -114,17 +118,9 @@ func ShouldUnCompressForOfficial(req *http.Request) bool {
 	return acceptGzip
 }
 
-// ShouldCompress ...
+// ShouldCompress Accept-EncodingEx 是否接受 gzip，支持 q 值
 func ShouldCompress(req *http.Request) bool {
-	acceptGzip := false
-	hdr := req.Header
-	for _, encoding := range strings.Split(hdr.Get(AcceptEncodingEx), ",") {
-		if "gzip" == strings.TrimSpace(encoding) {
-			acceptGzip = true
-			break
-		}
-	}
-	return acceptGzip
+	return ParseAcceptEncoding(req.Header.Values(AcceptEncodingEx)...).Accepts(EncodingGzip)
 }
 
 // ShouldUnCompress ...
@@ -171,13 +167,7 @@ func ProtocolEncryptV1(data []byte, mid string, seqid uint32) bool {
 		return true
 	}
 
-	key := mid + "FC149CE9B1414613AB6D6C481D95293A"
-	key_data := []byte(key)
-	for i := 0; i < len(key_data); i++ {
-		key_data[i] ^= byte(seqid)
-	}
-
-	cipher, err := rc4.NewCipher(key_data)
+	cipher, err := protocolCipherV1(mid, seqid)
 	if err != nil {
 		return false
 	}
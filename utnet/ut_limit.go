@@ -0,0 +1,189 @@
+package utnet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// ErrBodyTooLarge 请求体或响应体超过 BodyLimits，SessionMiddleware 返回 413
+var ErrBodyTooLarge = errors.New("utnet: body too large")
+
+// ratioCheckFloor 解压后的数据超过这个长度才检查压缩比，避免小数据误判
+const ratioCheckFloor = 1 << 20
+
+// BodyLimits ...
+// @Description: 请求体、响应体的大小限制，在读取和解压的过程中检查，超过时立即停止并返回 *BodyLimitError，
+// 零值字段表示不限制
+type BodyLimits struct {
+	MaxRawBytes     int64   // 原始 body 的最大长度
+	MaxDecodedBytes int64   // 每一层解压后的最大长度
+	MaxRatio        float64 // 解压后与解压前长度之比的上限，解压后超过 1MB 才检查
+}
+
+// DefaultBodyLimits 原始 16MB，解压后 64MB，压缩比 100
+func DefaultBodyLimits() BodyLimits {
+	return BodyLimits{
+		MaxRawBytes:     16 << 20,
+		MaxDecodedBytes: 64 << 20,
+		MaxRatio:        100,
+	}
+}
+
+// bodyLimits SessionInfo.Parse 和 Do 默认使用的限制
+var bodyLimits atomic.Pointer[BodyLimits]
+
+func init() {
+	l := DefaultBodyLimits()
+	bodyLimits.Store(&l)
+}
+
+// SetBodyLimits 设置 SessionInfo.Parse 的请求体限制和 Do 的响应体限制
+func SetBodyLimits(l BodyLimits) {
+	bodyLimits.Store(&l)
+}
+
+// GetBodyLimits ...
+func GetBodyLimits() BodyLimits {
+	return *bodyLimits.Load()
+}
+
+// BodyLimitError ...
+// @Description: 超过限制的具体项，Unwrap 为 ErrBodyTooLarge
+type BodyLimitError struct {
+	Kind  string // raw、decoded、ratio
+	Limit float64
+	Read  int64 // 出错时已读取的长度
+}
+
+// Error ...
+func (e *BodyLimitError) Error() string {
+	if e.Kind == "ratio" {
+		return fmt.Sprintf("%v: compression ratio exceeds %g", ErrBodyTooLarge, e.Limit)
+	}
+	return fmt.Sprintf("%v: %s size exceeds %d bytes", ErrBodyTooLarge, e.Kind, int64(e.Limit))
+}
+
+// Unwrap ...
+func (e *BodyLimitError) Unwrap() error {
+	return ErrBodyTooLarge
+}
+
+// CheckContentLength 已知长度超过 MaxRawBytes 时不必读取，直接返回错误，length 为 -1 表示未知
+func (l BodyLimits) CheckContentLength(length int64) error {
+	if l.MaxRawBytes > 0 && length > l.MaxRawBytes {
+		return &BodyLimitError{Kind: "raw", Limit: float64(l.MaxRawBytes)}
+	}
+	return nil
+}
+
+// Raw 限制原始 body 的长度
+func (l BodyLimits) Raw(r io.Reader) io.Reader {
+	if l.MaxRawBytes <= 0 {
+		return r
+	}
+	return &limitReader{r: r, max: l.MaxRawBytes, kind: "raw"}
+}
+
+// Decode ...
+// @Description: 用 c 边读边解码 r，限制解码后的长度和压缩比；返回的 Closer 用完后需要关闭，以归还解码器
+// @receiver l
+// @param c
+// @param r
+// @return io.Reader 解码后的数据
+// @return io.Closer
+// @return error
+func (l BodyLimits) Decode(c Codec, r io.Reader) (io.Reader, io.Closer, error) {
+	in := &countReader{r: r}
+	dr, err := c.NewReader(in)
+	if err == io.EOF {
+		// 空输入
+		return eofReader{}, io.NopCloser(nil), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return l.decoded(dr, in), dr, nil
+}
+
+// decoded 限制解压后的数据，in 统计解压前读取的长度
+func (l BodyLimits) decoded(r io.Reader, in *countReader) io.Reader {
+	if l.MaxDecodedBytes <= 0 && l.MaxRatio <= 0 {
+		return r
+	}
+	return &ratioReader{r: r, in: in, limits: l}
+}
+
+// limitReader 超过 max 时返回 *BodyLimitError
+type limitReader struct {
+	r    io.Reader
+	n    int64
+	max  int64
+	kind string
+}
+
+// Read 最多多读 1 字节用于判断是否超限
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n > l.max {
+		return 0, &BodyLimitError{Kind: l.kind, Limit: float64(l.max), Read: l.n}
+	}
+	if rest := l.max - l.n + 1; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n - int(l.n-l.max), &BodyLimitError{Kind: l.kind, Limit: float64(l.max), Read: l.n}
+	}
+	return n, err
+}
+
+// eofReader 空数据
+type eofReader struct{}
+
+// Read ...
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// countReader 统计读取的长度
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read ...
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader 检查解压后的长度和压缩比
+type ratioReader struct {
+	r      io.Reader
+	in     *countReader
+	out    int64
+	limits BodyLimits
+}
+
+// Read ...
+func (r *ratioReader) Read(p []byte) (int, error) {
+	l := r.limits
+	if l.MaxDecodedBytes > 0 {
+		if r.out > l.MaxDecodedBytes {
+			return 0, &BodyLimitError{Kind: "decoded", Limit: float64(l.MaxDecodedBytes), Read: r.out}
+		}
+		if rest := l.MaxDecodedBytes - r.out + 1; int64(len(p)) > rest {
+			p = p[:rest]
+		}
+	}
+	n, err := r.r.Read(p)
+	r.out += int64(n)
+	if l.MaxDecodedBytes > 0 && r.out > l.MaxDecodedBytes {
+		return n - int(r.out-l.MaxDecodedBytes), &BodyLimitError{Kind: "decoded", Limit: float64(l.MaxDecodedBytes), Read: r.out}
+	}
+	if l.MaxRatio > 0 && r.out > ratioCheckFloor && float64(r.out) > l.MaxRatio*float64(max(r.in.n, 1)) {
+		return n, &BodyLimitError{Kind: "ratio", Limit: l.MaxRatio, Read: r.out}
+	}
+	return n, err
+}
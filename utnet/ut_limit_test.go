@@ -0,0 +1,224 @@
+package utnet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// gzipZeros n 个 0 压缩后的数据，压缩比约 1000
+func gzipZeros(t testing.TB, n int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	chunk := make([]byte, 64<<10)
+	for n > 0 {
+		m := min(n, len(chunk))
+		if _, err := zw.Write(chunk[:m]); err != nil {
+			t.Fatal(err)
+		}
+		n -= m
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// limitKind 错误链中 *BodyLimitError 的 Kind，没有时返回空
+func limitKind(err error) string {
+	var le *BodyLimitError
+	if errors.As(err, &le) {
+		return le.Kind
+	}
+	return ""
+}
+
+// TestBodyLimitsRaw 原始长度恰好等于上限时通过，多 1 字节即报错
+func TestBodyLimitsRaw(t *testing.T) {
+	tests := []struct {
+		max, n int64
+		kind   string
+	}{
+		{0, 1 << 20, ""},
+		{10, 9, ""},
+		{10, 10, ""},
+		{10, 11, "raw"},
+		{10, 1 << 20, "raw"},
+	}
+	for _, tt := range tests {
+		l := BodyLimits{MaxRawBytes: tt.max}
+		data, err := io.ReadAll(l.Raw(bytes.NewReader(make([]byte, tt.n))))
+		if limitKind(err) != tt.kind || !errors.Is(err, ErrBodyTooLarge) != (tt.kind == "") {
+			t.Errorf("max %d, %d bytes: err = %v, want kind %q", tt.max, tt.n, err, tt.kind)
+		}
+		if tt.kind != "" && int64(len(data)) != tt.max {
+			t.Errorf("max %d, %d bytes: read %d bytes before the error", tt.max, tt.n, len(data))
+		}
+		if err = l.CheckContentLength(tt.n); limitKind(err) != tt.kind {
+			t.Errorf("max %d: CheckContentLength(%d) = %v", tt.max, tt.n, err)
+		}
+	}
+	if err := (BodyLimits{MaxRawBytes: 10}).CheckContentLength(-1); err != nil {
+		t.Errorf("CheckContentLength(unknown) = %v", err)
+	}
+}
+
+// TestBodyLimitsDecode 解压后的长度和压缩比限制，gzip 炸弹在读完之前就被拦截
+func TestBodyLimitsDecode(t *testing.T) {
+	bomb := gzipZeros(t, 64<<20)
+	small := gzipZeros(t, 1000)
+	tests := []struct {
+		name   string
+		limits BodyLimits
+		data   []byte
+		kind   string
+	}{
+		{"unlimited", BodyLimits{}, small, ""},
+		{"decoded below limit", BodyLimits{MaxDecodedBytes: 1000}, small, ""},
+		{"decoded over limit", BodyLimits{MaxDecodedBytes: 999}, small, "decoded"},
+		// 1MB 以下不检查压缩比
+		{"small ratio ignored", BodyLimits{MaxRatio: 2}, small, ""},
+		{"bomb ratio", BodyLimits{MaxRatio: 100}, bomb, "ratio"},
+		{"bomb decoded", BodyLimits{MaxDecodedBytes: 4 << 20}, bomb, "decoded"},
+		{"bomb default", DefaultBodyLimits(), bomb, "ratio"},
+		{"bomb high ratio", BodyLimits{MaxRatio: 10000}, bomb, ""},
+	}
+	for _, tt := range tests {
+		r, closer, err := tt.limits.Decode(GzipCodec, bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		n, err := io.Copy(io.Discard, r)
+		_ = closer.Close()
+		if limitKind(err) != tt.kind {
+			t.Errorf("%s: err = %v, want kind %q", tt.name, err, tt.kind)
+		}
+		var le *BodyLimitError
+		if errors.As(err, &le) && (le.Read > 8<<20 || n > 8<<20) {
+			t.Errorf("%s: decoded %d bytes before the error", tt.name, n)
+		}
+	}
+
+	r, closer, err := DefaultBodyLimits().Decode(GzipCodec, bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(r); err != nil || len(data) != 0 || closer.Close() != nil {
+		t.Errorf("empty input: %q, %v", data, err)
+	}
+}
+
+// TestSessionMiddlewareBodyLimits 请求体超过任何一项限制时中间件返回 413，handler 不会被调用
+func TestSessionMiddlewareBodyLimits(t *testing.T) {
+	old := GetBodyLimits()
+	defer SetBodyLimits(old)
+	SetBodyLimits(BodyLimits{MaxRawBytes: 1 << 20, MaxDecodedBytes: 8 << 20, MaxRatio: 100})
+
+	tests := []struct {
+		name    string
+		body    []byte
+		headers map[string]string
+		code    int
+	}{
+		{"plain", []byte("hello"), nil, http.StatusOK},
+		{"gzip", gzipZeros(t, 1000), map[string]string{ContentEncodingEx: EncodingGzip}, http.StatusOK},
+		{"raw too large", make([]byte, 1<<20+1), nil, http.StatusRequestEntityTooLarge},
+		{"bomb", gzipZeros(t, 64<<20), map[string]string{ContentEncodingEx: EncodingGzip}, http.StatusRequestEntityTooLarge},
+		{"nginx bomb", gzipZeros(t, 64<<20), map[string]string{ContentEncoding: EncodingGzip}, http.StatusRequestEntityTooLarge},
+		{"invalid gzip", []byte("not gzip"), map[string]string{ContentEncodingEx: EncodingGzip}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		called := false
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+		})
+		for _, chunked := range []bool{false, true} {
+			var body io.Reader = bytes.NewReader(tt.body)
+			if chunked {
+				// 长度未知，只能边读边检查
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", body)
+			if chunked {
+				req.ContentLength = -1
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			called = false
+			rec := httptest.NewRecorder()
+			SessionMiddleware(h).ServeHTTP(rec, req)
+			if rec.Code != tt.code || called != (tt.code == http.StatusOK) {
+				t.Errorf("%s chunked=%v: status %d, handler called %v, want %d", tt.name, chunked, rec.Code, called, tt.code)
+			}
+		}
+	}
+}
+
+// TestResponseBodyLimits HTTPAPIRequest 和 Do 的响应体同样受限制，Request.Limits 优先于全局设置
+func TestResponseBodyLimits(t *testing.T) {
+	bomb := gzipZeros(t, 64<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/bomb":
+			w.Header().Set(ContentEncodingEx, EncodingGzip)
+			_, _ = w.Write(bomb)
+		case "/large":
+			w.Header().Set(ContentLength, strconv.Itoa(2<<20))
+			_, _ = w.Write(make([]byte, 2<<20))
+		case "/chunked":
+			for i := 0; i < 32; i++ {
+				_, _ = w.Write(make([]byte, 64<<10))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+
+	old := GetBodyLimits()
+	defer SetBodyLimits(old)
+	SetBodyLimits(BodyLimits{MaxRawBytes: 1 << 20, MaxDecodedBytes: 32 << 20, MaxRatio: 100})
+
+	accept := map[string]string{AcceptEncodingEx: EncodingGzip}
+	for _, tt := range []struct {
+		path    string
+		headers map[string]string
+		kind    string
+	}{
+		{"/bomb", accept, "ratio"},
+		{"/large", nil, "raw"},
+		{"/chunked", nil, "raw"},
+	} {
+		_, err := HTTPAPIRequest(http.MethodGet, srv.URL+tt.path, tt.headers, nil)
+		if limitKind(err) != tt.kind || errors.Is(err, ErrDecode) || errors.Is(err, ErrTransport) {
+			t.Errorf("HTTPAPIRequest %s: err = %v, want kind %q", tt.path, err, tt.kind)
+		}
+	}
+
+	// 单个请求放宽限制
+	r := NewRequest(http.MethodGet, srv.URL+"/large", nil)
+	r.Limits = &BodyLimits{MaxRawBytes: 4 << 20}
+	resp, err := Do(context.Background(), nil, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := resp.ReadAll(); err != nil || len(body) != 2<<20 {
+		t.Errorf("Do with Request.Limits: %d bytes, %v", len(body), err)
+	}
+	r = NewRequest(http.MethodGet, srv.URL+"/bomb", nil).SetHeaders(accept)
+	r.Limits = &BodyLimits{MaxDecodedBytes: 1 << 20}
+	if _, err = doBytes(context.Background(), nil, r); limitKind(err) != "decoded" {
+		t.Errorf("Do bomb with Request.Limits: %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "decoded size exceeds 1048576 bytes") {
+		t.Errorf("Error() = %s", err)
+	}
+}
@@ -0,0 +1,224 @@
+package utnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/smallnest/rpcx/log"
+)
+
+// sessionKey SessionInfo 在 context 中的 key
+type sessionKey struct{}
+
+// WithSession 把 SessionInfo 放入 context
+func WithSession(ctx context.Context, si *SessionInfo) context.Context {
+	return context.WithValue(ctx, sessionKey{}, si)
+}
+
+// SessionFromContext 取出 SessionMiddleware 解析的 SessionInfo
+func SessionFromContext(ctx context.Context) (*SessionInfo, bool) {
+	si, ok := ctx.Value(sessionKey{}).(*SessionInfo)
+	return si, ok
+}
+
+// SessionMiddleware ...
+// @Description: 服务端中间件，请求用 SessionInfo.Parse 解密、解压后放入 context，
+// req.Body 替换为解码后的数据；2xx 响应按 Accept-EncodingEx 和 Content-Encrypt 自动先压缩后加密，
+// 错误响应不编码；handler 只需写明文，不要再调用 SetCompressHeader、GetCompressData、ProtocolEncryptV1
+// @param next
+// @return http.Handler
+func SessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		si := &SessionInfo{}
+		if err := si.Parse(req); err != nil {
+			log.Errorf("[Session] parse %s error: %v", req.URL.Path, err)
+			code := parseErrorStatus(err)
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		req = req.WithContext(WithSession(req.Context(), si))
+		req.Body = io.NopCloser(bytes.NewReader(si.DataByte))
+		req.ContentLength = int64(len(si.DataByte))
+
+		sw := newSessionResponseWriter(w, req, si)
+		defer func() {
+			if err := sw.close(); err != nil {
+				log.Errorf("[Session] close response writer error: %v", err)
+			}
+		}()
+		next.ServeHTTP(sw, req)
+	})
+}
+
+// parseErrorStatus SessionInfo.Parse 的错误对应的状态码
+func parseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrReplay):
+		return http.StatusConflict
+	case errors.Is(err, ErrClockSkew):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// sessionResponseWriter ...
+// @Description: 按会话协议编码响应体，写头部时决定是否压缩、加密
+type sessionResponseWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	si          *SessionInfo
+	wroteHeader bool
+	out         io.Writer      // 响应体写入的位置
+	enc         io.WriteCloser // 压缩时不为 nil
+}
+
+// newSessionResponseWriter ...
+func newSessionResponseWriter(w http.ResponseWriter, req *http.Request, si *SessionInfo) *sessionResponseWriter {
+	return &sessionResponseWriter{ResponseWriter: w, req: req, si: si, out: w}
+}
+
+// addVary 加入 Vary，已有时不重复
+func addVary(h http.Header, values ...string) {
+	existing := strings.Join(h.Values(vary), ",")
+	for _, v := range values {
+		found := false
+		for _, e := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(e), v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Add(vary, v)
+			existing += "," + v
+		}
+	}
+}
+
+// bodyAllowed 状态码和方法是否允许响应体
+func bodyAllowed(method string, code int) bool {
+	return method != http.MethodHead && code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// encodedResponse 只有 2xx 响应按会话协议压缩、加密；错误响应保持明文，
+// 与 decodeResponse 对应，客户端把它原样放入 HTTPError.Body
+func encodedResponse(code int) bool {
+	return code >= 200 && code <= 299
+}
+
+// WriteHeader 第一次写头部时建立编码链：handler -> 压缩 -> rc4 -> 原始 ResponseWriter
+func (w *sessionResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 && code != http.StatusSwitchingProtocols {
+		// 1xx 信息响应可以有多个，不影响后续编码
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	// 响应内容随客户端是否接受压缩而不同
+	addVary(h, AcceptEncodingEx)
+	encrypt := w.si.ContentEncrypt == EncryptV1
+	if encrypt {
+		addVary(h, HeaderContentEncrypt, HeaderClientMid, HeaderContentSeq)
+	}
+
+	if !encodedResponse(code) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.si.respCodec != nil && w.req.Method == http.MethodHead {
+		// handler 给出的是明文长度，与 GET 的压缩结果不一致
+		setEncodingHeader(h, w.si.respCodec.Name())
+	}
+	if bodyAllowed(w.req.Method, code) {
+		if encrypt {
+			c, err := protocolCipherV1(w.si.ClientMid, w.si.ContentSeq)
+			if err != nil {
+				log.Errorf("[Session] init encrypt error: %v", err)
+				h.Del(ContentLength)
+				w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+				w.out = io.Discard
+				return
+			}
+			// rc4 不改变长度，handler 设置的 Content-Length 仍然有效
+			w.out = cipher.StreamWriter{S: c, W: w.out}
+		}
+		if w.si.respCodec != nil {
+			enc, err := w.si.respCodec.NewWriter(w.out)
+			if err != nil {
+				log.Errorf("[Session] init %s encoder error: %v", w.si.respCodec.Name(), err)
+				h.Del(ContentLength)
+				w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+				w.out = io.Discard
+				return
+			}
+			setEncodingHeader(h, w.si.respCodec.Name())
+			w.enc = enc
+			w.out = enc
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write ...
+func (w *sessionResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.out.Write(p)
+}
+
+// Flush 先刷出压缩缓冲的数据
+func (w *sessionResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 接管连接后不再编码
+func (w *sessionResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijack")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap 供 http.ResponseController 使用
+func (w *sessionResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close 写完压缩尾部并归还 writer，handler 没有写任何内容时也按协议输出空的编码响应
+func (w *sessionResponseWriter) close() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc = nil
+	return err
+}
@@ -0,0 +1,192 @@
+package utnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const errorJSON = `{"code":1001,"message":"bad request"}`
+
+// echoHandler /fail 返回 400 和明文 JSON，其余路径回显请求体并设置明文长度的 Content-Length
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/fail" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, errorJSON)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		out := "echo:" + string(body)
+		w.Header().Set(ContentLength, strconv.Itoa(len(out)))
+		_, _ = io.WriteString(w, out)
+	})
+}
+
+// sessionHeaders 请求体按 enc 压缩、接受 enc 压缩的响应，encrypt 时使用 v1 加密
+func sessionHeaders(enc string, encrypt bool) map[string]string {
+	h := map[string]string{}
+	if enc != "" {
+		h[ContentEncodingEx] = enc
+		h[AcceptEncodingEx] = enc
+	}
+	if encrypt {
+		h[HeaderContentEncrypt] = EncryptV1
+		h[HeaderClientMid] = "test-mid"
+		h[HeaderContentSeq] = strconv.FormatUint(uint64(NextContentSeq()), 10)
+	}
+	return h
+}
+
+// TestSessionMiddlewareRoundTrip 经过中间件的 200 响应按协议解码，4xx 的错误 JSON 保持明文
+func TestSessionMiddlewareRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(SessionMiddleware(echoHandler()))
+	defer srv.Close()
+
+	for _, enc := range []string{"", EncodingGzip, EncodingDeflate} {
+		for _, encrypt := range []bool{false, true} {
+			name := enc + "/encrypt=" + strconv.FormatBool(encrypt)
+
+			r := NewRequest(http.MethodPost, srv.URL+"/echo", strings.NewReader("hello"))
+			r.SetHeaders(sessionHeaders(enc, encrypt))
+			resp, err := Do(context.Background(), nil, r)
+			if err != nil {
+				t.Fatalf("%s: Do: %v", name, err)
+			}
+			if got := resp.Header.Get(ContentEncodingEx); got != enc {
+				t.Errorf("%s: Content-EncodingEx = %q, want %q", name, got, enc)
+			}
+			body, err := resp.ReadAll()
+			if err != nil || string(body) != "echo:hello" {
+				t.Errorf("%s: Do body = %q, %v", name, body, err)
+			}
+
+			body, err = HTTPAPIRequestV1(nil, http.MethodPost, srv.URL+"/echo", sessionHeaders(enc, encrypt), []byte("v1"))
+			if err != nil || string(body) != "echo:v1" {
+				t.Errorf("%s: HTTPAPIRequestV1 = %q, %v", name, body, err)
+			}
+
+			_, err = HTTPAPIRequestV1(nil, http.MethodPost, srv.URL+"/fail", sessionHeaders(enc, encrypt), []byte("v1"))
+			var he *HTTPError
+			if !errors.As(err, &he) {
+				t.Fatalf("%s: /fail err = %v, want *HTTPError", name, err)
+			}
+			if he.StatusCode != http.StatusBadRequest || string(he.Body) != errorJSON {
+				t.Errorf("%s: /fail = %d %q, want 400 %q", name, he.StatusCode, he.Body, errorJSON)
+			}
+			if got := he.Header.Get(ContentEncodingEx); got != "" {
+				t.Errorf("%s: /fail Content-EncodingEx = %q, want none", name, got)
+			}
+		}
+	}
+}
+
+// serveSession 用 ResponseRecorder 直接检查中间件写出的头部，请求体为未压缩的明文
+func serveSession(h http.Handler, method string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest(method, "/echo", strings.NewReader("hello"))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Del(ContentEncodingEx)
+	rec := httptest.NewRecorder()
+	SessionMiddleware(h).ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+// TestSessionMiddlewareHeaders 压缩时去掉明文长度的 Content-Length，Vary 覆盖影响编码的请求头
+func TestSessionMiddlewareHeaders(t *testing.T) {
+	resp := serveSession(echoHandler(), http.MethodPost, sessionHeaders(EncodingGzip, true))
+	if resp.Header.Get(ContentLength) != "" {
+		t.Errorf("compressed Content-Length = %q, want none", resp.Header.Get(ContentLength))
+	}
+	vary := strings.Join(resp.Header.Values(vary), ",")
+	for _, v := range []string{AcceptEncodingEx, HeaderContentEncrypt, HeaderClientMid, HeaderContentSeq} {
+		if !strings.Contains(vary, v) {
+			t.Errorf("Vary = %q, missing %s", vary, v)
+		}
+	}
+	if strings.Count(vary, AcceptEncodingEx) != 1 {
+		t.Errorf("Vary = %q, %s repeated", vary, AcceptEncodingEx)
+	}
+
+	// 只加密不压缩时 rc4 不改变长度，Content-Length 保留
+	resp = serveSession(echoHandler(), http.MethodPost, sessionHeaders("", true))
+	if got := resp.Header.Get(ContentLength); got != "10" {
+		t.Errorf("encrypted Content-Length = %q, want 10", got)
+	}
+
+	// HEAD 的头部与 GET 的压缩结果一致，响应体由 net/http 丢弃
+	resp = serveSession(echoHandler(), http.MethodHead, sessionHeaders(EncodingGzip, false))
+	if resp.Header.Get(ContentEncodingEx) != EncodingGzip || resp.Header.Get(ContentLength) != "" {
+		t.Errorf("HEAD: Content-EncodingEx %q, Content-Length %q",
+			resp.Header.Get(ContentEncodingEx), resp.Header.Get(ContentLength))
+	}
+
+	// 错误响应不编码
+	req := httptest.NewRequest(http.MethodPost, "/fail", strings.NewReader("x"))
+	req.Header.Set(AcceptEncodingEx, EncodingGzip)
+	rec := httptest.NewRecorder()
+	SessionMiddleware(echoHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != errorJSON || rec.Header().Get(ContentEncodingEx) != "" {
+		t.Errorf("/fail: %d %q %q", rec.Code, rec.Body.String(), rec.Header().Get(ContentEncodingEx))
+	}
+}
+
+// TestSessionMiddlewareFlush Flush 后客户端能立即解码已写出的部分
+func TestSessionMiddlewareFlush(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "part1")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, "part2")
+	})))
+	defer srv.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	for _, enc := range []string{EncodingGzip, EncodingDeflate} {
+		release = make(chan struct{})
+		r := NewRequest(http.MethodPost, srv.URL, strings.NewReader("x"))
+		r.SetHeaders(sessionHeaders(enc, true))
+		resp, err := Do(context.Background(), nil, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "part1" {
+			t.Fatalf("%s: before release read %q, %v", enc, buf, err)
+		}
+		close(release)
+		rest, err := io.ReadAll(resp.Body)
+		if err != nil || string(rest) != "part2" {
+			t.Errorf("%s: rest = %q, %v", enc, rest, err)
+		}
+		_ = resp.Close()
+	}
+}
+
+// TestSessionMiddlewareParseError 请求体超限时返回 413，handler 不会被调用
+func TestSessionMiddlewareParseError(t *testing.T) {
+	old := GetBodyLimits()
+	defer SetBodyLimits(old)
+	SetBodyLimits(BodyLimits{MaxRawBytes: 4})
+
+	called := false
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })
+	resp := serveSession(h, http.MethodPost, nil)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || called {
+		t.Errorf("status %d, handler called %v, want 413 without handler", resp.StatusCode, called)
+	}
+}
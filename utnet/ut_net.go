@@ -2,7 +2,6 @@ package utnet
 
 import (
 	"fmt"
-	"math/big"
 	"net"
 	"strconv"
 	"strings"
@@ -145,54 +144,26 @@ func GetIntranetAddress() (ips []string, err error) {
 	return ips, nil
 }
 
-// IsIntranetAddress 判断是否私网ip地址
+// IsIntranetAddress ...
+// @Description: 判断是否内网ip地址：RFC 1918 私网、100.64.0.0/10 共享地址、IPv6 唯一本地地址 fc00::/7，
+// IPv4 映射的 IPv6 地址按 IPv4 判断。
+// 与旧版本的行为差异：旧版本只按字符串前缀匹配 10.、172.16-31.、192.168.，且末尾误写为 return true，
+// 实际上对任何输入（公网地址、回环地址、无法解析的字符串）都返回 true；
+// 现在公网、回环、链路本地等地址以及解析失败都返回 false，100.64.0.0/10 和 fc00::/7 新增返回 true。
+// 依赖旧行为的调用方需要自行补充判断
+// @param ipStr 不带端口和前缀长度的地址，带 zone 的 IPv6 地址按去掉 zone 后判断
+// @return bool
 func IsIntranetAddress(ipStr string) bool {
-	if strings.HasPrefix(ipStr, "10.") || strings.HasPrefix(ipStr, "192.168.") {
-		return true
-	}
-
-	if strings.HasPrefix(ipStr, "172.") {
-		// 172.16.0.0-172.31.255.255
-		arr := strings.Split(ipStr, ".")
-		if len(arr) != 4 {
-			return false
-		}
-
-		second, err := strconv.ParseInt(arr[1], 10, 64)
-		if err != nil {
-			return false
-		}
-
-		if second >= 16 && second <= 31 {
-			return true
-		}
+	ip, err := utip.ParseAddr(ipStr)
+	if err != nil {
+		return false
 	}
-	return true
+	return utip.Classify(ip).IsIntranet()
 }
 
-// IsPublicAddress 判断是否公网ip地址
+// IsPublicAddress 判断是否公网ip地址，按 IANA 特殊用途地址注册表判断全局可达的单播地址
 func IsPublicAddress(IP net.IP) bool {
-	if IP == nil {
-		return false
-	}
-	if IP.IsLoopback() || IP.IsLinkLocalMulticast() || IP.IsLinkLocalUnicast() {
-		return false
-	}
-	if ip4 := IP.To4(); ip4 != nil {
-		switch true {
-		case ip4[0] <= 10:
-			return false
-		case ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
-			return false
-		case ip4[0] == 171:
-			return false
-		case ip4[0] == 192 && ip4[1] == 168:
-			return false
-		default:
-			return true
-		}
-	}
-	return false
+	return utip.ClassifyIP(IP).IsPublic()
 }
 
 // getLocalPublicAddress 获取公网ip地址
@@ -306,19 +277,12 @@ func IPv6OnesToMask(s string) net.IPMask {
 
 // IPv4ToInt ipv4 to int64
 func IPv4ToInt(ip net.IP) int64 {
-	ipv4 := ip.To4()
-	if ipv4 == nil {
-		return 0
-	}
-	ret := big.NewInt(0)
-	ret.SetBytes(ipv4)
-	return ret.Int64()
+	return utip.IPToInt64(ip)
 }
 
 // IntToIPv4 int64 to ipv4
 func IntToIPv4(ip int64) net.IP {
-	newIPStr := fmt.Sprintf("%d.%d.%d.%d", byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip))
-	return net.ParseIP(newIPStr)
+	return utip.Int64ToIP(ip)
 }
 
 //func FromNullableTimestampToTime(nt *pb.NullableTimestamp) time.Time {
@@ -0,0 +1,80 @@
+package utnet
+
+import (
+	"net"
+	"testing"
+)
+
+// TestIsPublicAddress 按注册表分类后的回归用例
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},   // 首段不大于 10 的地址也可能是公网地址
+		{"171.8.1.1", true}, // 171/8 不是保留网段
+		{"10.1.1.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"127.0.0.1", false},
+		{"169.254.1.1", false},
+		{"192.0.2.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		// 全局单播 IPv6 地址是公网地址
+		{"2606:4700::1111", true},
+		{"2400:cb00::1", true},
+		{"2001:db8::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"::1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddress(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicAddress(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	if IsPublicAddress(nil) {
+		t.Error("IsPublicAddress(nil) = true")
+	}
+}
+
+// TestIsIntranetAddress ...
+func TestIsIntranetAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"100.127.255.255", true},
+		{"100.63.255.255", false},
+		{"100.128.0.1", false},
+		{"fd00::1", true},
+		{"fc00::1", true},
+		{"fd00::1%eth0", true},
+		{"fbff::1", false},
+		{"fe00::1", false},
+		{"::ffff:10.0.0.1", true},
+		{"8.8.8.8", false},
+		{"127.0.0.1", false},
+		{"fe80::1", false},
+		{"2001:db8::1", false},
+		{"10.0.0", false},
+		{"10.0.0.1/8", false},
+		{"10.0.0.1:80", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsIntranetAddress(tt.ip); got != tt.want {
+			t.Errorf("IsIntranetAddress(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
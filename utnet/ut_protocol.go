@@ -0,0 +1,333 @@
+package utnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// HeaderProtoVer 协议版本
+	HeaderProtoVer = "Proto-Ver"
+	// HeaderClientVersion 客户端版本，如 1.2.3.4
+	HeaderClientVersion = "Client-Version"
+	// HeaderClientMachine 客户端机器信息
+	HeaderClientMachine = "Client-Machine"
+	// HeaderContentTime 请求发出时的 Unix 秒，供重放检查校验时间偏差
+	HeaderContentTime = "Content-Time"
+)
+
+var (
+	// ErrHeaderMissing 当前协议版本要求的头部缺失
+	ErrHeaderMissing = errors.New("protocol header missing")
+	// ErrHeaderInvalid 头部格式错误
+	ErrHeaderInvalid = errors.New("protocol header invalid")
+)
+
+// ProtoRequiredHeaders ...
+// @Description: 各协议版本必须携带的头部，未列出的版本使用不大于它的最大版本的配置；
+// 协议本身没有规定必需的头部，默认都不要求，服务按自己的协议版本在初始化时配置，如
+// ProtoRequiredHeaders[3] = []string{HeaderClientMid, HeaderContentSeq}
+var ProtoRequiredHeaders = map[uint32][]string{
+	0: {},
+}
+
+// strictProtocolHeaders SessionInfo.Parse 是否拒绝协议头部有误的请求
+var strictProtocolHeaders atomic.Bool
+
+// SetStrictProtocolHeaders ...
+// @Description: 开启后 SessionInfo.Parse 对头部格式错误、缺少 ProtoRequiredHeaders 的请求返回错误；
+// 默认关闭，与原有行为一致：格式错误的字段按 0 处理，不检查必需头部
+// @param strict
+func SetStrictProtocolHeaders(strict bool) {
+	strictProtocolHeaders.Store(strict)
+}
+
+// StrictProtocolHeaders ...
+func StrictProtocolHeaders() bool {
+	return strictProtocolHeaders.Load()
+}
+
+// RequiredHeaders 协议版本 ver 必须携带的头部
+func RequiredHeaders(ver uint32) []string {
+	vers := make([]uint32, 0, len(ProtoRequiredHeaders))
+	for v := range ProtoRequiredHeaders {
+		if v <= ver {
+			vers = append(vers, v)
+		}
+	}
+	if len(vers) == 0 {
+		return nil
+	}
+	sort.Slice(vers, func(i, j int) bool { return vers[i] < vers[j] })
+	return ProtoRequiredHeaders[vers[len(vers)-1]]
+}
+
+// HeaderError ...
+// @Description: 协议头部错误，Err 为 ErrHeaderMissing 或 ErrHeaderInvalid
+type HeaderError struct {
+	Header string
+	Value  string
+	Err    error
+	Reason string
+}
+
+// Error ...
+func (e *HeaderError) Error() string {
+	if errors.Is(e.Err, ErrHeaderMissing) {
+		return fmt.Sprintf("%v: %s", e.Err, e.Header)
+	}
+	return fmt.Sprintf("%v: %s=%q: %s", e.Err, e.Header, e.Value, e.Reason)
+}
+
+// Unwrap ...
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// ClientVersion ...
+// @Description: 四段式客户端版本号 a.b.c.d，每段 16 位，打包为 uint64 后可直接比较大小，
+// 与 SessionInfo.ClientVersion 的编码一致
+type ClientVersion uint64
+
+// NewClientVersion ...
+func NewClientVersion(major, minor, patch, build uint16) ClientVersion {
+	return ClientVersion(uint64(major)<<48 | uint64(minor)<<32 | uint64(patch)<<16 | uint64(build))
+}
+
+// ParseClientVersion 解析 1 到 4 段的十进制版本号，缺少的段为 0，如 "3.2" 等于 "3.2.0.0"
+func ParseClientVersion(s string) (ClientVersion, error) {
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 4 {
+		return 0, fmt.Errorf("client version %q: want 1 to 4 dot-separated numbers", s)
+	}
+	var v uint64
+	for i := 0; i < 4; i++ {
+		var n uint64
+		if i < len(parts) {
+			var err error
+			// 不接受 +1、-1 这类写法
+			if parts[i] == "" || parts[i][0] < '0' || parts[i][0] > '9' {
+				return 0, fmt.Errorf("client version %q: invalid number %q", s, parts[i])
+			}
+			n, err = strconv.ParseUint(parts[i], 10, 16)
+			if err != nil {
+				return 0, fmt.Errorf("client version %q: invalid number %q", s, parts[i])
+			}
+		}
+		v = v<<16 | n
+	}
+	return ClientVersion(v), nil
+}
+
+// Parts 四段版本号
+func (v ClientVersion) Parts() [4]uint16 {
+	return [4]uint16{uint16(v >> 48), uint16(v >> 32), uint16(v >> 16), uint16(v)}
+}
+
+// String 如 1.2.3.4，与 ParseClientVersion 互逆
+func (v ClientVersion) String() string {
+	p := v.Parts()
+	return fmt.Sprintf("%d.%d.%d.%d", p[0], p[1], p[2], p[3])
+}
+
+// Compare 返回 -1、0、1
+func (v ClientVersion) Compare(o ClientVersion) int {
+	switch {
+	case v < o:
+		return -1
+	case v > o:
+		return 1
+	}
+	return 0
+}
+
+// Less ...
+func (v ClientVersion) Less(o ClientVersion) bool {
+	return v < o
+}
+
+// AtLeast v >= o
+func (v ClientVersion) AtLeast(o ClientVersion) bool {
+	return v >= o
+}
+
+// MarshalText ...
+func (v ClientVersion) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText ...
+func (v *ClientVersion) UnmarshalText(text []byte) error {
+	nv, err := ParseClientVersion(string(text))
+	if err != nil {
+		return err
+	}
+	*v = nv
+	return nil
+}
+
+// ProtocolHeaders ...
+// @Description: Client-Mid / Content-Seq 会话协议的头部，服务端用 ParseProtocolHeaders 解析校验，
+// 客户端用 Apply 生成请求头部
+type ProtocolHeaders struct {
+	ProtoVer       uint32
+	ContentSeq     uint32
+	ContentTime    int64 // Unix 秒，0 表示没有
+	ClientMid      string
+	ClientVersion  ClientVersion
+	ClientMachine  string
+	ContentEncrypt string // "" 或 v1
+	Compress       bool   // 请求体 gzip 压缩，Content-EncodingEx
+	AcceptCompress bool   // 接受压缩的响应，Apply 时 Accept-EncodingEx 为 DefaultCodecs 的全部编码
+	Encoding       string // 请求体的压缩方式，不为空时代替 Compress
+	Accept         string // Accept-EncodingEx 的值，可带 q 值，不为空时代替 AcceptCompress
+}
+
+// ParseProtocolHeaders ...
+// @Description: 解析并校验协议头部，格式错误和缺少必需头部都会报错，多个错误用 errors.Join 合并，
+// 每个错误都是 *HeaderError；出错时仍返回已解析的部分，格式错误的字段为 0；
+// Content-Encrypt 不是 v1 时不报错，与原有行为一致按明文处理
+// @param h
+// @return ProtocolHeaders
+// @return error
+func ParseProtocolHeaders(h http.Header) (ProtocolHeaders, error) {
+	var p ProtocolHeaders
+	var errs []error
+	invalid := func(name, value, reason string) {
+		errs = append(errs, &HeaderError{Header: name, Value: value, Err: ErrHeaderInvalid, Reason: reason})
+	}
+	parseUint32 := func(name string) uint32 {
+		s := h.Get(name)
+		if s == "" {
+			return 0
+		}
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			invalid(name, s, "want decimal uint32")
+			return 0
+		}
+		return uint32(v)
+	}
+
+	p.ProtoVer = parseUint32(HeaderProtoVer)
+	p.ContentSeq = parseUint32(HeaderContentSeq)
+	if s := h.Get(HeaderContentTime); s != "" {
+		t, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || t <= 0 {
+			invalid(HeaderContentTime, s, "want positive unix seconds")
+			t = 0
+		}
+		p.ContentTime = t
+	}
+	p.ClientMid = h.Get(HeaderClientMid)
+	p.ClientMachine = h.Get(HeaderClientMachine)
+	if s := h.Get(HeaderClientVersion); s != "" {
+		v, err := ParseClientVersion(s)
+		if err != nil {
+			invalid(HeaderClientVersion, s, "want version like 1.2.3.4")
+		}
+		p.ClientVersion = v
+	}
+	p.ContentEncrypt = h.Get(HeaderContentEncrypt)
+	p.Encoding = strings.Join(h.Values(ContentEncodingEx), ", ")
+	p.Accept = strings.Join(h.Values(AcceptEncodingEx), ", ")
+	p.Compress = hasGzip(h, ContentEncodingEx)
+	_, p.AcceptCompress = DefaultCodecs.Negotiate(p.Accept)
+
+	for _, name := range RequiredHeaders(p.ProtoVer) {
+		if h.Get(name) == "" {
+			errs = append(errs, &HeaderError{Header: name, Err: ErrHeaderMissing})
+		}
+	}
+	return p, errors.Join(errs...)
+}
+
+// Validate 客户端发送前检查必需头部
+func (p ProtocolHeaders) Validate() error {
+	h := make(http.Header)
+	p.Apply(h)
+	_, err := ParseProtocolHeaders(h)
+	return err
+}
+
+// Next 换用新的 Content-Seq 和当前时间，每个加密请求都应使用新的序号
+func (p ProtocolHeaders) Next() ProtocolHeaders {
+	p.ContentSeq = NextContentSeq()
+	p.ContentTime = time.Now().Unix()
+	return p
+}
+
+// Apply 写入头部，零值字段不写
+func (p ProtocolHeaders) Apply(h http.Header) {
+	if p.ProtoVer != 0 {
+		h.Set(HeaderProtoVer, strconv.FormatUint(uint64(p.ProtoVer), 10))
+	}
+	if p.ContentSeq != 0 {
+		h.Set(HeaderContentSeq, strconv.FormatUint(uint64(p.ContentSeq), 10))
+	}
+	if p.ContentTime != 0 {
+		h.Set(HeaderContentTime, strconv.FormatInt(p.ContentTime, 10))
+	}
+	if p.ClientMid != "" {
+		h.Set(HeaderClientMid, p.ClientMid)
+	}
+	if p.ClientVersion != 0 {
+		h.Set(HeaderClientVersion, p.ClientVersion.String())
+	}
+	if p.ClientMachine != "" {
+		h.Set(HeaderClientMachine, p.ClientMachine)
+	}
+	if p.ContentEncrypt != "" {
+		h.Set(HeaderContentEncrypt, p.ContentEncrypt)
+	}
+	switch {
+	case p.Encoding != "":
+		h.Set(ContentEncodingEx, p.Encoding)
+	case p.Compress:
+		h.Set(ContentEncodingEx, EncodingGzip)
+	}
+	switch {
+	case p.Accept != "":
+		h.Set(AcceptEncodingEx, p.Accept)
+	case p.AcceptCompress:
+		h.Set(AcceptEncodingEx, DefaultCodecs.AcceptEncoding())
+	}
+}
+
+// SetProtocol 按协议头部设置请求
+func (r *Request) SetProtocol(p ProtocolHeaders) *Request {
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	p.Apply(r.Header)
+	return r
+}
+
+// HTTPAPIRequestProtocol ...
+// @Description: 与 HTTPAPIRequestContext 相同，协议头部由 ProtocolHeaders 生成并在发送前校验，
+// headers 只放协议之外的头部，可以为 nil
+// @param ctx
+// @param cli nil 时使用默认 client
+// @param reqMode
+// @param url
+// @param p
+// @param headers
+// @param data
+// @return []byte
+// @return error
+func HTTPAPIRequestProtocol(ctx context.Context, cli *http.Client, reqMode string, url string, p ProtocolHeaders, headers map[string]string, data []byte) ([]byte, error) {
+	if ctx == nil {
+		return nil, errors.New("nil context")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return doBytes(ctx, cli, legacyRequestV1(reqMode, url, headers, data).SetProtocol(p))
+}
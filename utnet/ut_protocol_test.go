@@ -0,0 +1,63 @@
+package utnet
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestParseProtocolHeaders 格式错误的字段为 0，未知的 Content-Encrypt 不报错
+func TestParseProtocolHeaders(t *testing.T) {
+	h := make(http.Header)
+	h.Set(HeaderProtoVer, "3")
+	h.Set(HeaderContentSeq, "x1")
+	h.Set(HeaderClientVersion, "1.2")
+	h.Set(HeaderContentEncrypt, "v2")
+	p, err := ParseProtocolHeaders(h)
+	if !errors.Is(err, ErrHeaderInvalid) {
+		t.Fatalf("err = %v, want ErrHeaderInvalid", err)
+	}
+	var he *HeaderError
+	if !errors.As(err, &he) || he.Header != HeaderContentSeq {
+		t.Errorf("err = %v, want only %s invalid", err, HeaderContentSeq)
+	}
+	if p.ProtoVer != 3 || p.ContentSeq != 0 || p.ClientVersion != NewClientVersion(1, 2, 0, 0) || p.ContentEncrypt != "v2" {
+		t.Errorf("ParseProtocolHeaders = %+v", p)
+	}
+
+	// 默认不要求任何头部
+	if _, err := ParseProtocolHeaders(http.Header{HeaderProtoVer: {"3"}}); err != nil {
+		t.Errorf("no required headers by default, got %v", err)
+	}
+	ProtoRequiredHeaders[3] = []string{HeaderClientMid}
+	defer delete(ProtoRequiredHeaders, 3)
+	if _, err := ParseProtocolHeaders(http.Header{HeaderProtoVer: {"4"}}); !errors.Is(err, ErrHeaderMissing) {
+		t.Errorf("err = %v, want ErrHeaderMissing", err)
+	}
+}
+
+// TestSessionParseStrict 默认宽松解析，开启严格模式后拒绝头部有误的请求
+func TestSessionParseStrict(t *testing.T) {
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api?cmd=1", bytes.NewReader([]byte("hello")))
+		req.Header.Set(HeaderContentSeq, "bad")
+		req.Header.Set(HeaderContentEncrypt, "v2")
+		return req
+	}
+
+	var si SessionInfo
+	if err := si.Parse(newReq()); err != nil {
+		t.Fatalf("lenient Parse: %v", err)
+	}
+	if si.ContentSeq != 0 || si.Data != "hello" {
+		t.Errorf("lenient Parse: seq %d, data %q", si.ContentSeq, si.Data)
+	}
+
+	SetStrictProtocolHeaders(true)
+	defer SetStrictProtocolHeaders(false)
+	if err := new(SessionInfo).Parse(newReq()); !errors.Is(err, ErrHeaderInvalid) {
+		t.Errorf("strict Parse err = %v, want ErrHeaderInvalid", err)
+	}
+}
@@ -0,0 +1,216 @@
+package utnet
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrReplay Content-Seq 已经使用过、已落后于滑动窗口，或一次前进超过 MaxForward
+	ErrReplay = errors.New("utnet: replayed request")
+	// ErrClockSkew Content-Time 超出允许的时间偏差，或要求 Content-Time 时缺失
+	ErrClockSkew = errors.New("utnet: request time out of tolerance")
+)
+
+const (
+	// DefaultReplayWindow 默认滑动窗口位数，允许的最大乱序距离
+	DefaultReplayWindow = 1024
+	// DefaultReplayMaxClients 默认最多跟踪的 Client-Mid 数
+	DefaultReplayMaxClients = 100000
+)
+
+// replayGuard SessionInfo.Parse 使用的重放检查，nil 表示不检查
+var replayGuard atomic.Pointer[ReplayGuard]
+
+// SetReplayGuard 设置 SessionInfo.Parse 的重放检查，nil 表示关闭（默认）
+func SetReplayGuard(g *ReplayGuard) {
+	replayGuard.Store(g)
+}
+
+// GetReplayGuard ...
+func GetReplayGuard() *ReplayGuard {
+	return replayGuard.Load()
+}
+
+// replayClient 单个 Client-Mid 的窗口，bits 是以 seq % window 为下标的环形位图
+type replayClient struct {
+	mid      string
+	max      uint32
+	bits     []uint64
+	lastSeen time.Time
+}
+
+// ReplayGuard ...
+// @Description: 按 Client-Mid 检查 Content-Seq 是否重复，每个客户端保留最近 Window 个序号的位图，
+// 允许窗口内乱序，落后于窗口的序号视为重放；序号按 RFC 1982 比较，回绕后仍可使用。
+// 最多跟踪 MaxClients 个客户端，超出时淘汰最久未访问的，空闲超过 IdleTimeout 的也会被淘汰；
+// 被淘汰的客户端重新出现时从新的序号开始记录，因此应配合 MaxSkew 限制请求的有效期。
+// 序号一次最多前进 MaxForward，否则一个伪造的大序号就能把窗口推走，使该客户端之后的正常请求都被拒绝；
+// 客户端重启等原因导致序号跳跃过大时，要等它空闲超过 IdleTimeout 被淘汰后才能重新开始。
+// 构造后不要修改配置字段，并发安全
+type ReplayGuard struct {
+	Window      uint32        // 滑动窗口位数，2 的幂且不小于 64，序号回绕时环形位图仍然连续
+	MaxForward  uint32        // 序号相对已见最大序号一次最多前进多少，0 表示不限制
+	MaxClients  int           // 最多跟踪的客户端数
+	IdleTimeout time.Duration // 客户端空闲多久后淘汰，0 表示只按容量淘汰
+	MaxSkew     time.Duration // Content-Time 与本机时间允许的偏差，0 表示不校验
+	RequireTime bool          // 是否要求请求带 Content-Time
+
+	mu      sync.Mutex
+	lru     *list.List // 队首最近访问
+	clients map[string]*list.Element
+	now     func() time.Time
+}
+
+// NewReplayGuard ...
+// @Description: window、maxClients 为 0 时使用默认值，MaxForward 为 Window，IdleTimeout 为 2*maxSkew，
+// maxSkew > 0 时要求请求带 Content-Time，否则去掉该头部即可绕过时间校验
+// @param window
+// @param maxClients
+// @param maxSkew
+// @return *ReplayGuard
+func NewReplayGuard(window uint32, maxClients int, maxSkew time.Duration) *ReplayGuard {
+	if window == 0 {
+		window = DefaultReplayWindow
+	}
+	window = max(64, uint32(1)<<bits.Len32(min(window, 1<<24)-1))
+	if maxClients <= 0 {
+		maxClients = DefaultReplayMaxClients
+	}
+	return &ReplayGuard{
+		Window:      window,
+		MaxForward:  window,
+		MaxClients:  maxClients,
+		IdleTimeout: 2 * maxSkew,
+		MaxSkew:     maxSkew,
+		RequireTime: maxSkew > 0,
+		lru:         list.New(),
+		clients:     make(map[string]*list.Element),
+		now:         time.Now,
+	}
+}
+
+// Len 当前跟踪的客户端数
+func (g *ReplayGuard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len()
+}
+
+// Check ...
+// @Description: 检查并记录一次请求，通过返回 nil，否则返回 ErrReplay 或 ErrClockSkew
+// @receiver g
+// @param mid Client-Mid
+// @param seq Content-Seq
+// @param unix Content-Time，0 表示没有
+// @return error
+func (g *ReplayGuard) Check(mid string, seq uint32, unix int64) error {
+	now := g.now()
+	if err := g.checkTime(now, unix); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.evictIdle(now)
+	if e, ok := g.clients[mid]; ok {
+		c := e.Value.(*replayClient)
+		if err := c.record(seq, g.Window, g.MaxForward); err != nil {
+			return fmt.Errorf("%w: %s seq %d", err, mid, seq)
+		}
+		c.lastSeen = now
+		g.lru.MoveToFront(e)
+		return nil
+	}
+
+	for g.lru.Len() >= g.MaxClients {
+		g.remove(g.lru.Back())
+	}
+	c := &replayClient{mid: mid, max: seq, bits: make([]uint64, g.Window/64), lastSeen: now}
+	c.set(seq, g.Window)
+	g.clients[mid] = g.lru.PushFront(c)
+	return nil
+}
+
+// checkTime 校验 Content-Time
+func (g *ReplayGuard) checkTime(now time.Time, unix int64) error {
+	if unix == 0 {
+		if g.RequireTime {
+			return fmt.Errorf("%w: missing %s", ErrClockSkew, HeaderContentTime)
+		}
+		return nil
+	}
+	if g.MaxSkew <= 0 {
+		return nil
+	}
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > g.MaxSkew || skew < -g.MaxSkew {
+		return fmt.Errorf("%w: skew %v", ErrClockSkew, skew)
+	}
+	return nil
+}
+
+// evictIdle 从队尾淘汰空闲超时的客户端，调用方需持有 g.mu
+func (g *ReplayGuard) evictIdle(now time.Time) {
+	if g.IdleTimeout <= 0 {
+		return
+	}
+	for e := g.lru.Back(); e != nil; e = g.lru.Back() {
+		if now.Sub(e.Value.(*replayClient).lastSeen) < g.IdleTimeout {
+			return
+		}
+		g.remove(e)
+	}
+}
+
+// remove 调用方需持有 g.mu
+func (g *ReplayGuard) remove(e *list.Element) {
+	g.lru.Remove(e)
+	delete(g.clients, e.Value.(*replayClient).mid)
+}
+
+// bit seq 在位图中的位置
+func (c *replayClient) bit(seq uint32, window uint32) (int, uint64) {
+	i := seq % window
+	return int(i / 64), 1 << (i % 64)
+}
+
+// set ...
+func (c *replayClient) set(seq uint32, window uint32) {
+	w, m := c.bit(seq, window)
+	c.bits[w] |= m
+}
+
+// record 按滑动窗口记录 seq，前进超过 maxForward 的序号不记录并返回 ErrReplay
+func (c *replayClient) record(seq uint32, window uint32, maxForward uint32) error {
+	diff := int32(seq - c.max)
+	switch {
+	case diff > 0 && maxForward > 0 && uint32(diff) > maxForward:
+		return fmt.Errorf("%w: seq jumps %d ahead", ErrReplay, diff)
+	case diff > 0:
+		// 窗口前移，清掉移出窗口的位
+		if uint32(diff) >= window {
+			clear(c.bits)
+		} else {
+			for s := c.max + 1; s != seq; s++ {
+				w, m := c.bit(s, window)
+				c.bits[w] &^= m
+			}
+		}
+		c.max = seq
+	case uint32(-diff) >= window:
+		return ErrReplay
+	default:
+		w, m := c.bit(seq, window)
+		if c.bits[w]&m != 0 {
+			return ErrReplay
+		}
+	}
+	c.set(seq, window)
+	return nil
+}
@@ -0,0 +1,85 @@
+package utnet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestReplayGuardWindow 窗口内乱序、重复和落后于窗口的序号
+func TestReplayGuardWindow(t *testing.T) {
+	g := NewReplayGuard(64, 0, 0)
+	steps := []struct {
+		seq  uint32
+		want error
+	}{
+		{100, nil},
+		{102, nil},
+		{101, nil},
+		{101, ErrReplay},
+		{166, nil},       // 前进 64，等于 MaxForward
+		{102, ErrReplay}, // 落后于窗口
+		{130, nil},
+		{231, ErrReplay}, // 前进 65，超过 MaxForward
+		{167, nil},       // 超限的序号没有移动窗口
+	}
+	for i, s := range steps {
+		if err := g.Check("mid", s.seq, 0); !errors.Is(err, s.want) {
+			t.Errorf("step %d: Check(%d) = %v, want %v", i, s.seq, err, s.want)
+		}
+	}
+}
+
+// TestReplayGuardWrap 序号回绕
+func TestReplayGuardWrap(t *testing.T) {
+	g := NewReplayGuard(64, 0, 0)
+	for _, seq := range []uint32{1<<32 - 2, 1<<32 - 1, 0, 1} {
+		if err := g.Check("mid", seq, 0); err != nil {
+			t.Fatalf("Check(%d) = %v", seq, err)
+		}
+	}
+	if err := g.Check("mid", 1<<32-1, 0); !errors.Is(err, ErrReplay) {
+		t.Errorf("replay across wrap = %v, want ErrReplay", err)
+	}
+}
+
+// TestReplayGuardTime maxSkew > 0 时要求 Content-Time
+func TestReplayGuardTime(t *testing.T) {
+	g := NewReplayGuard(0, 0, time.Minute)
+	if !g.RequireTime {
+		t.Fatal("RequireTime = false with maxSkew > 0")
+	}
+	now := time.Now().Unix()
+	if err := g.Check("mid", 1, 0); !errors.Is(err, ErrClockSkew) {
+		t.Errorf("missing time = %v, want ErrClockSkew", err)
+	}
+	if err := g.Check("mid", 1, now-120); !errors.Is(err, ErrClockSkew) {
+		t.Errorf("stale time = %v, want ErrClockSkew", err)
+	}
+	if err := g.Check("mid", 1, now); err != nil {
+		t.Errorf("current time = %v", err)
+	}
+	if NewReplayGuard(0, 0, 0).RequireTime {
+		t.Error("RequireTime = true without maxSkew")
+	}
+}
+
+// TestReplayGuardEvict 超过 MaxClients 时淘汰最久未访问的客户端
+func TestReplayGuardEvict(t *testing.T) {
+	g := NewReplayGuard(0, 2, 0)
+	for i, mid := range []string{"a", "b", "a", "c"} {
+		if err := g.Check(mid, uint32(i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", g.Len())
+	}
+	// b 被淘汰，重新出现时从新的序号开始
+	if err := g.Check("b", 1, 0); err != nil {
+		t.Errorf("evicted client = %v", err)
+	}
+	if err := g.Check("c", 3, 0); !errors.Is(err, ErrReplay) {
+		t.Errorf("tracked client = %v, want ErrReplay", err)
+	}
+}
@@ -1,11 +1,11 @@
 package utnet
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
+	"crypto/cipher"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,107 +16,20 @@ import (
 var httpClient *http.Client
 
 func init() {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		MaxIdleConnsPerHost:   32,
-		MaxConnsPerHost:       128,
-		IdleConnTimeout:       30 * time.Second,
-		TLSHandshakeTimeout:   3 * time.Second,
-		ExpectContinueTimeout: 3 * time.Second,
-	}
-	httpClient = &http.Client{
-		Transport: tr,
-		Timeout:   10 * time.Second,
+	var err error
+	httpClient, err = legacyClientOptions().
+		WithInsecureSkipVerify().
+		WithTLSHandshakeTimeout(3 * time.Second).
+		WithExpectContinueTimeout(3 * time.Second).
+		Build()
+	if err != nil {
+		panic(err)
 	}
 }
 
 // HTTPAPIRequest HTTP请求和解析响应，支持头部信息，支持加解密，压缩解压缩
 func HTTPAPIRequest(reqMode string, url string, headers map[string]string, data []byte) ([]byte, error) {
-	var resp *http.Response
-	var req *http.Request
-	var err error
-	seqid := uint32(0)
-	// 处理头信息
-	if val, ok := headers["Content-Seq"]; ok {
-		value, _ := strconv.ParseUint(val, 10, 0)
-		seqid = uint32(value)
-	}
-	mid := ""
-	if val, ok := headers["Client-Mid"]; ok {
-		mid = val
-	}
-	if val, ok := headers[ContentEncodingEx]; ok {
-		if val == "gzip" {
-			// 发送内容压缩
-			data = GetCompressData(data)
-		}
-	}
-	if val, ok := headers["Content-Encrypt"]; ok {
-		if val == "v1" {
-			// 发送内容加密
-			ProtocolEncryptV1(data, mid, seqid)
-		}
-	}
-
-	// 根据method生成请求
-	if reqMode == "POST" {
-		reader := bytes.NewReader(data)
-		req, err = http.NewRequest(reqMode, url, reader)
-	} else {
-		req, err = http.NewRequest(reqMode, url, nil)
-	}
-	if err != nil {
-		err = fmt.Errorf("http NewRequest , Error : %s", err.Error())
-		return nil, err
-	}
-	// 主动关闭请求
-	req.Close = true
-
-	if len(headers) > 0 {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-
-	// 执行请求
-	resp, err = httpClient.Do(req)
-	if err != nil {
-		err = fmt.Errorf("Get Do, Error : %s", err.Error())
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// 解析响应
-	if resp == nil {
-		err = fmt.Errorf("Request Url : %s, resp == nil", url)
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		_, err = ioutil.ReadAll(resp.Body)
-		err = fmt.Errorf("Request Url : %s, Error Status : %d", url, resp.StatusCode)
-		return nil, err
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		err = fmt.Errorf("read body Error : %s", err.Error())
-		return nil, err
-	}
-
-	// 响应解密
-	if val, ok := headers["Content-Encrypt"]; ok {
-		if val == "v1" {
-			ProtocolDecryptV1(body, mid, seqid)
-		}
-	}
-	// 响应解压缩
-	if val, ok := headers[AcceptEncodingEx]; ok {
-		if val == "gzip" {
-			body = GetUnCompressData(body)
-		}
-	}
-	return body, nil
+	return doBytes(context.Background(), httpClient, legacyRequest(reqMode, url, headers, data))
 }
 
 // SessionInfo ...
@@ -125,6 +38,7 @@ type SessionInfo struct {
 	//AccountInfo         *AccountInfo
 	//MidPermit           bool
 	ClientIPPort        string
+	Client              ClientAddr // 经可信代理校验后的客户端地址，见 SetTrustedProxies
 	ClientMid           string
 	ClientGuid          string
 	ClientVersionString string
@@ -134,21 +48,31 @@ type SessionInfo struct {
 	ProtoCmd            uint32
 	ContentEncrypt      string
 	ContentSeq          uint32
+	Protocol            ProtocolHeaders // 解析后的协议头部，上面的同名字段由它填充
 
 	ContentType string
 	Host        string
 	Data        string
 	DataByte    []byte
 
-	shouldCompress   bool //是否压缩
-	shouldUnCompress bool //是否解压
+	shouldCompress   bool  //是否接受 gzip 压缩的响应，与 GetCompressData 配合使用
+	shouldUnCompress bool  //是否解压
+	respCodec        Codec //响应的压缩方式
 }
 
-// GetShouldCompress ...
+// GetShouldCompress 客户端是否接受 gzip 压缩的响应，为 true 时可用 GetCompressData 压缩
 func (si *SessionInfo) GetShouldCompress() bool {
 	return si.shouldCompress
 }
 
+// ResponseEncoding 响应协商出的压缩方式，可能是 gzip 以外的编码，不压缩时为空；SessionMiddleware 按它压缩响应
+func (si *SessionInfo) ResponseEncoding() string {
+	if si.respCodec == nil {
+		return ""
+	}
+	return si.respCodec.Name()
+}
+
 // GetShouldUnCompress ...
 func (si *SessionInfo) GetShouldUnCompress() bool {
 	return si.shouldUnCompress
@@ -160,26 +84,20 @@ func (si *SessionInfo) GetShouldUnCompress() bool {
 // @param req
 // @return error
 func (si *SessionInfo) Parse(req *http.Request) error {
-	si.ClientIPPort = req.Header.Get("X-Real-IP")
+	si.Client = GetTrustedProxies().ClientAddr(req)
+	si.ClientIPPort = si.Client.String()
 	if si.ClientIPPort == "" {
 		si.ClientIPPort = req.RemoteAddr
 	}
-	xForwardedFor := req.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		xFwd := strings.Split(xForwardedFor, ",")
-		if len(xFwd) != 0 {
-			xip := strings.TrimSpace(xFwd[0])
-			if xip != "" {
-				si.ClientIPPort = xip
-			}
-		}
-	}
 
-	si.ContentSeq = getStringUint32(req.Header.Get("Content-Seq"))
-	//if si.ContentSeq == 0 {
-	//	return errors.New("The Content-Seq is missing!")
-	//}
-	si.ProtoVer = getStringUint32(req.Header.Get("Proto-Ver"))
+	// 默认宽松解析，SetStrictProtocolHeaders(true) 后才拒绝头部有误的请求
+	proto, err := ParseProtocolHeaders(req.Header)
+	if err != nil && StrictProtocolHeaders() {
+		return fmt.Errorf("[Session] invalid protocol headers: %w", err)
+	}
+	si.Protocol = proto
+	si.ContentSeq = proto.ContentSeq
+	si.ProtoVer = proto.ProtoVer
 
 	uri, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
@@ -187,11 +105,12 @@ func (si *SessionInfo) Parse(req *http.Request) error {
 	}
 	si.ProtoCmd = getStringUint32(uri.Get("cmd"))
 	//si.ClientMid = uri.Get("mid")
-	si.ClientMid = req.Header.Get("Client-Mid")
+	si.ClientMid = proto.ClientMid
 	si.ClientGuid = uri.Get("guid")
-	si.ClientVersionString = req.Header.Get("Client-Version")
-	si.ClientMachine = req.Header.Get("Client-Machine")
-	si.ContentEncrypt = req.Header.Get("Content-Encrypt")
+	si.ClientVersionString = req.Header.Get(HeaderClientVersion)
+	si.ClientVersion = uint64(proto.ClientVersion)
+	si.ClientMachine = proto.ClientMachine
+	si.ContentEncrypt = proto.ContentEncrypt
 	si.ContentType = req.Header.Get("Content-Type")
 
 	if ip := strings.Split(req.Host, ":"); len(ip) > 0 {
@@ -200,46 +119,65 @@ func (si *SessionInfo) Parse(req *http.Request) error {
 		si.Host = req.Host
 	}
 
-	var ver1, ver2, ver3, ver4 uint64
-	fmt.Sscanf(si.ClientVersionString, "%d.%d.%d.%d", &ver1, &ver2, &ver3, &ver4)
-	si.ClientVersion = (ver1 << 48) + (ver2 << 32) + (ver3 << 16) + ver4
-
-	data, err := ioutil.ReadAll(req.Body)
-	// pretty print http request
-	//reqDump, _ := httputil.DumpRequest(req, false)
-	//log.Debugf("[Session] req header\n%s", reqDump)
-
 	/**
 	双端与后台对齐 ：同步修改为：先压缩再加密  ==>  对应取数据先解密再解压
 	兼容性处理 ：	  采用ProtoVer作为区分，新版本号选用3
 	*/
-	// 先看是否被nginx压缩过
-	if ShouldUnCompressForOfficial(req) {
-		// 如果有，先解压
-		data = GetUnCompressData(data)
+	// 边读边解码，每一层都受 BodyLimits 限制
+	limits := GetBodyLimits()
+	if err := limits.CheckContentLength(req.ContentLength); err != nil {
+		return fmt.Errorf("[Session] %w", err)
+	}
+	var body io.Reader = limits.Raw(req.Body)
+	var closers []io.Closer
+	defer func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}()
+	decode := func(key string) error {
+		codecs, err := DefaultCodecs.lookupEncodings(req.Header, key)
+		if err != nil {
+			return err
+		}
+		r, cs, err := decodeAll(limits, body, codecs)
+		if err != nil {
+			return err
+		}
+		body, closers = r, append(closers, cs...)
+		return nil
+	}
+	// 先看是否被nginx压缩过，如果有，先解压
+	if err := decode(ContentEncoding); err != nil {
+		return fmt.Errorf("[Session] uncompress error: %w", err)
 	}
 	//是否启用解密
-	if si.ContentEncrypt == "v1" {
-		if ProtocolDecryptV1(data, si.ClientMid, si.ContentSeq) == false {
+	if si.ContentEncrypt == EncryptV1 {
+		c, err := protocolCipherV1(si.ClientMid, si.ContentSeq)
+		if err != nil {
 			return errors.New("[Session] The request is invalid!")
 		}
+		body = cipher.StreamReader{S: c, R: body}
 	}
 	//当前请求数据是否启用解压
-	if ShouldUnCompress(req) {
-		//log.Debugf("[Session] Request data should uncompress")
-		si.shouldUnCompress = true
-		data = GetUnCompressData(data)
-		//log.Debugf("[Session] after GetUnCompressData %+v", data)
-	} else {
-		//log.Debugf("[Session] X Request data should not uncompress")
+	si.shouldUnCompress = len(contentEncodings(req.Header, ContentEncodingEx)) > 0
+	if err := decode(ContentEncodingEx); err != nil {
+		return fmt.Errorf("[Session] uncompress error: %w", err)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("[Session] read body error: %w", err)
 	}
 
-	//往后的响应是否启用压缩
-	if ShouldCompress(req) {
-		//log.Debugf("[Session] resp data should compress")
-		si.shouldCompress = true
-	} else {
-		//log.Debugf("[Session] X resp data should not compress")
+	//往后的响应按 Accept-EncodingEx 协商压缩方式
+	si.respCodec, _ = DefaultCodecs.Negotiate(req.Header.Values(AcceptEncodingEx)...)
+	si.shouldCompress = ShouldCompress(req)
+	// 解密、解压成功后再记录序号；v1 的密钥由 Client-Mid 和 Content-Seq 推导，不能防止伪造请求，
+	// 只能拦截原样重放的请求，伪造的大序号由 ReplayGuard.MaxForward 限制
+	if g := GetReplayGuard(); g != nil && si.ContentEncrypt == EncryptV1 {
+		if err := g.Check(si.ClientMid, si.ContentSeq, proto.ContentTime); err != nil {
+			return fmt.Errorf("[Session] %w", err)
+		}
 	}
 	si.Data = Byte2String(data)
 	//log.Debugf("[Session] %+v", si)
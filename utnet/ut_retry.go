@@ -0,0 +1,421 @@
+package utnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen 目标 host 的熔断器处于打开状态，请求未发出
+var ErrCircuitOpen = errors.New("utnet: circuit breaker open")
+
+// contentSeq NextContentSeq 的计数器，以启动时间为起点，避免重启后序号回退
+var contentSeq atomic.Uint32
+
+func init() {
+	contentSeq.Store(uint32(time.Now().Unix()))
+}
+
+// NextContentSeq 进程内单调递增的 Content-Seq
+func NextContentSeq() uint32 {
+	return contentSeq.Add(1)
+}
+
+// RetryPolicy ...
+// @Description: 重试策略，默认只重试幂等方法，退避时间按指数增长并加随机抖动，
+// 响应带 Retry-After 时按其等待
+type RetryPolicy struct {
+	MaxAttempts        int           // 总尝试次数（含第一次），<= 1 表示不重试
+	BaseDelay          time.Duration // 第一次重试前的等待
+	MaxDelay           time.Duration // 单次等待上限，<= 0 表示不限制
+	Multiplier         float64       // 每次等待的增长倍数
+	Jitter             float64       // 随机抖动比例 [0, 1]，实际等待为 d*(1-Jitter*rand)
+	RetryStatus        []int         // 需要重试的状态码
+	RetryNonIdempotent bool          // 是否重试 POST、PATCH 等非幂等方法
+	MaxRetryAfter      time.Duration // Retry-After 超过这个值时不再重试，0 表示不读取 Retry-After
+}
+
+// DefaultRetryPolicy 3 次尝试，200ms 起指数退避，最长 5s，重试 429、502、503、504
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:   3,
+		BaseDelay:     200 * time.Millisecond,
+		MaxDelay:      5 * time.Second,
+		Multiplier:    2,
+		Jitter:        0.5,
+		RetryStatus:   []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		MaxRetryAfter: 30 * time.Second,
+	}
+}
+
+// Backoff 第 retry 次重试（从 1 开始）前的等待时间
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := float64(p.BaseDelay)
+	for i := 1; i < retry && (p.MaxDelay <= 0 || d < float64(p.MaxDelay)); i++ {
+		d *= mult
+	}
+	if d > math.MaxInt64 {
+		d = math.MaxInt64
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d *= 1 - j*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// retryableMethod 方法是否允许重试
+func (p *RetryPolicy) retryableMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return p.RetryNonIdempotent
+}
+
+// retryAfter 解析 Retry-After，支持秒数和 HTTP 日期
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	// BreakerClosed 正常放行
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 拒绝请求
+	BreakerOpen
+	// BreakerHalfOpen 冷却结束，只放行一个探测请求
+	BreakerHalfOpen
+)
+
+// String ...
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// breakerHost 单个 host 的熔断状态
+type breakerHost struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitBreaker ...
+// @Description: 按 host 熔断，连续失败 Threshold 次后打开，Cooldown 后半开并放行一个探测请求，
+// 探测成功则关闭，失败则重新打开；网络错误和 5xx 计为失败，并发安全
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breakerHost
+	now   func() time.Time
+}
+
+// NewCircuitBreaker ...
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     make(map[string]*breakerHost),
+		now:       time.Now,
+	}
+}
+
+// host 取 host 的状态，调用方需持有 b.mu
+func (b *CircuitBreaker) host(host string) *breakerHost {
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &breakerHost{}
+		b.hosts[host] = h
+	}
+	return h
+}
+
+// State host 当前的状态
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.hosts[host]
+	if !ok {
+		return BreakerClosed
+	}
+	if h.state == BreakerOpen && b.now().Sub(h.openedAt) >= b.Cooldown {
+		return BreakerHalfOpen
+	}
+	return h.state
+}
+
+// Allow 是否放行发往 host 的请求，放行后必须调用 Record
+func (b *CircuitBreaker) Allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.host(host)
+	switch h.state {
+	case BreakerOpen:
+		if b.now().Sub(h.openedAt) < b.Cooldown {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		h.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if h.probing {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		h.probing = true
+	}
+	return nil
+}
+
+// Record 记录请求结果
+func (b *CircuitBreaker) Record(host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.host(host)
+	h.probing = false
+	if success {
+		h.state, h.failures = BreakerClosed, 0
+		return
+	}
+	h.failures++
+	if h.state == BreakerHalfOpen || h.failures >= b.Threshold {
+		h.state, h.openedAt = BreakerOpen, b.now()
+	}
+}
+
+// release 放弃本次放行的结果，不计入成功或失败
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.host(host).probing = false
+}
+
+// RetryClient ...
+// @Description: 带重试和熔断的 Do，每次尝试都重新压缩、加密请求体；
+// 加密请求重试时换用新的 Content-Seq，避免重复的密钥流和服务端的重放检查
+type RetryClient struct {
+	Client  *http.Client    // nil 时使用默认 client
+	Policy  *RetryPolicy    // nil 时不重试
+	Breaker *CircuitBreaker // nil 时不熔断
+	NextSeq func() uint32   // nil 时使用 NextContentSeq
+}
+
+// Do ...
+// @Description: 与 Do 相同，失败时按策略重试；最后一次仍是可重试状态码时原样返回响应
+// @receiver c
+// @param ctx
+// @param r
+// @return *Response
+// @return error
+func (c *RetryClient) Do(ctx context.Context, r *Request) (*Response, error) {
+	host := ""
+	if u, err := url.Parse(r.URL); err == nil {
+		host = u.Host
+	}
+	r = withGetBody(r)
+	attempts := 1
+	if c.Policy != nil && c.Policy.MaxAttempts > 1 && c.Policy.retryableMethod(r.Method) {
+		attempts = c.Policy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if c.Breaker != nil {
+			if err := c.Breaker.Allow(host); err != nil {
+				return nil, err
+			}
+		}
+
+		try, err := c.prepare(r, attempt)
+		if err != nil {
+			if c.Breaker != nil {
+				c.Breaker.release(host)
+			}
+			return nil, err
+		}
+		resp, err := Do(ctx, c.Client, try)
+		if c.Breaker != nil {
+			if errors.Is(err, context.Canceled) {
+				// 调用方取消，不代表对端故障
+				c.Breaker.release(host)
+			} else {
+				c.Breaker.Record(host, err == nil && resp.StatusCode < 500)
+			}
+		}
+
+		last := attempt >= attempts || !replayable(r)
+		delay, retry := c.retryDelay(attempt, resp, err)
+		if last || !retry || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			_ = resp.Close()
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, transportError(err)
+		}
+	}
+}
+
+// Request 带重试的 HTTPAPIRequestV1
+func (c *RetryClient) Request(ctx context.Context, reqMode string, url string, headers map[string]string, data []byte) ([]byte, error) {
+	r := legacyRequestV1(reqMode, url, headers, data)
+	resp, err := c.Do(ctx, r)
+	return readOK(r, resp, err)
+}
+
+// replayable 请求体能否重新发送
+func replayable(r *Request) bool {
+	return r.Body == nil || r.GetBody != nil
+}
+
+// withGetBody ...
+// @Description: 没有 GetBody 时，为内存中的请求体生成 GetBody，与 http.NewRequest 的处理一致；
+// 不对其它 io.Seeker 调用 Seek，上一次尝试的压缩 goroutine 可能还在读取，*os.File 也已被 Transport 关闭
+// @param r
+// @return *Request
+func withGetBody(r *Request) *Request {
+	if r.Body == nil || r.GetBody != nil {
+		return r
+	}
+	var getBody func() (io.Reader, error)
+	switch v := r.Body.(type) {
+	case *bytes.Buffer:
+		buf := v.Bytes()
+		getBody = func() (io.Reader, error) { return bytes.NewReader(buf), nil }
+	case *bytes.Reader:
+		snapshot := *v
+		getBody = func() (io.Reader, error) {
+			r := snapshot
+			return &r, nil
+		}
+	case *strings.Reader:
+		snapshot := *v
+		getBody = func() (io.Reader, error) {
+			r := snapshot
+			return &r, nil
+		}
+	default:
+		return r
+	}
+	nr := *r
+	nr.GetBody = getBody
+	return &nr
+}
+
+// prepare 生成第 attempt 次尝试的请求：用 GetBody 重新取请求体，加密请求换新的 Content-Seq 和 Content-Time
+func (c *RetryClient) prepare(r *Request, attempt int) (*Request, error) {
+	if attempt == 1 {
+		return r, nil
+	}
+	try := *r
+	try.Header = r.Header.Clone()
+	if r.Body != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		try.Body = body
+	}
+	if try.Header.Get(HeaderContentEncrypt) != "" {
+		next := c.NextSeq
+		if next == nil {
+			next = NextContentSeq
+		}
+		try.Header.Set(HeaderContentSeq, strconv.FormatUint(uint64(next()), 10))
+		if try.Header.Get(HeaderContentTime) != "" {
+			try.Header.Set(HeaderContentTime, strconv.FormatInt(time.Now().Unix(), 10))
+		}
+	}
+	return &try, nil
+}
+
+// retryDelay 本次结果是否需要重试，以及等待时间
+func (c *RetryClient) retryDelay(attempt int, resp *Response, err error) (time.Duration, bool) {
+	p := c.Policy
+	if p == nil {
+		return 0, false
+	}
+	if err != nil {
+		if errors.Is(err, ErrTransport) || errors.Is(err, ErrTimeout) {
+			return p.Backoff(attempt), true
+		}
+		return 0, false
+	}
+	if !slices.Contains(p.RetryStatus, resp.StatusCode) {
+		return 0, false
+	}
+	delay := p.Backoff(attempt)
+	if p.MaxRetryAfter > 0 {
+		if d, ok := retryAfter(resp.Header, time.Now()); ok {
+			if d > p.MaxRetryAfter {
+				return 0, false
+			}
+			delay = d
+		}
+	}
+	return delay, true
+}
+
+// sleepContext 等待 d，context 结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package utnet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBackoffNoMaxDelay MaxDelay 为 0 时不限制，等待时间照常增长
+func TestBackoffNoMaxDelay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2}
+	for retry, want := range []time.Duration{100, 100, 200, 400, 800} {
+		if retry == 0 {
+			continue
+		}
+		if got := p.Backoff(retry); got != want*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", retry, got, want*time.Millisecond)
+		}
+	}
+	p.MaxDelay = 300 * time.Millisecond
+	if got := p.Backoff(4); got != p.MaxDelay {
+		t.Errorf("Backoff(4) with MaxDelay = %v, want %v", got, p.MaxDelay)
+	}
+}
+
+// TestRetryBody 内存中的请求体每次重试都完整发送，其它 io.Seeker 不重试
+func TestRetryBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// 原样返回压缩后的请求体，由客户端按 Content-EncodingEx 解压
+		w.Header().Set(ContentEncodingEx, r.Header.Get(ContentEncodingEx))
+		_, _ = w.Write(b)
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay, policy.Jitter = time.Millisecond, 0
+	c := &RetryClient{Policy: policy}
+
+	bodies := map[string]func() io.Reader{
+		"bytes.Reader":   func() io.Reader { return bytes.NewReader([]byte("payload")) },
+		"bytes.Buffer":   func() io.Reader { return bytes.NewBufferString("payload") },
+		"strings.Reader": func() io.Reader { return strings.NewReader("payload") },
+	}
+	for name, body := range bodies {
+		calls.Store(0)
+		r := NewRequest(http.MethodPut, srv.URL, body())
+		r.Header.Set(ContentEncodingEx, EncodingGzip)
+		resp, err := c.Do(context.Background(), r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := resp.ReadAll()
+		if err != nil || string(got) != "payload" || calls.Load() != 3 {
+			t.Errorf("%s: body %q, err %v, calls %d", name, got, err, calls.Load())
+		}
+	}
+
+	// *os.File 会被 Transport 关闭，不能 Seek 后重发
+	name := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(name, []byte("payload"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls.Store(0)
+	resp, err := c.Do(context.Background(), NewRequest(http.MethodPut, srv.URL, f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("os.File body: status %d, calls %d, want 503 after 1 call", resp.StatusCode, calls.Load())
+	}
+}